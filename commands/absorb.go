@@ -0,0 +1,637 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/output"
+	"github.com/roasbeef/hunk/patch"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// absorbOutput is the JSON output for absorb.
+type absorbOutput struct {
+	SchemaVersion int              `json:"schema_version"`
+	Success       bool             `json:"success"`
+	Message       string           `json:"message"`
+	DryRun        bool             `json:"dry_run,omitempty"`
+	Fixups        []absorbFixup    `json:"fixups"`
+	Unabsorbed    []absorbedBlock  `json:"unabsorbed"`
+	Rebase        *absorbRebaseRun `json:"rebase,omitempty"`
+}
+
+// absorbFixup is a fixup commit made, or planned, for one target commit.
+type absorbFixup struct {
+	Target        string          `json:"target"`
+	TargetSubject string          `json:"target_subject"`
+	Commit        string          `json:"commit,omitempty"`
+	Blocks        []absorbedBlock `json:"blocks"`
+}
+
+// absorbedBlock is a staged change block. For a block that wasn't absorbed,
+// Reason says why and Candidates lists the commits it could belong to.
+type absorbedBlock struct {
+	Path       string   `json:"path"`
+	Block      string   `json:"block,omitempty"`
+	Lines      string   `json:"lines,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// absorbRebaseRun reports the autosquash rebase run after absorbing.
+type absorbRebaseRun struct {
+	Ran         bool   `json:"ran"`
+	Message     string `json:"message"`
+	InProgress  bool   `json:"in_progress,omitempty"`
+	HasConflict bool   `json:"has_conflict,omitempty"`
+}
+
+// absorbOptions holds the flags for the absorb command.
+type absorbOptions struct {
+	onto      string
+	dryRun    bool
+	andRebase bool
+}
+
+// NewAbsorbCmd creates the absorb command.
+func NewAbsorbCmd() *cobra.Command {
+	var opts absorbOptions
+
+	cmd := &cobra.Command{
+		Use:   "absorb",
+		Short: "Turn staged changes into fixup commits for earlier commits",
+		Long: `Create fixup commits for staged changes to code from earlier commits.
+
+Each staged change block is matched to the commit on the branch (the
+commits after --onto) that last changed the lines it touches, using
+blame. A block that adds lines without removing any is matched by the
+lines around it. When all of those lines come from one commit, the block
+goes into a "fixup!" commit for it. The fixups are committed in branch
+order, one per target commit.
+
+Blocks that touch lines from several commits, or lines older than
+--onto, are reported and left staged rather than guessed at. So are new,
+renamed and binary files and mode changes.
+
+With --and-rebase, 'hunk rebase autosquash' then folds the fixups into
+their targets. The rebase is skipped if any changes are left in the
+working tree.`,
+		Example: `  # See where the staged changes would go
+  hunk absorb --onto main --dry-run
+
+  # Create the fixup commits
+  hunk absorb --onto main
+
+  # Create them and squash them into their targets
+  hunk absorb --onto main --and-rebase`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runAbsorb(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.onto, "onto", "",
+		"base of the branch; only commits after it are targets (required)",
+	)
+	cmd.Flags().BoolVar(
+		&opts.dryRun, "dry-run", false,
+		"show the fixups that would be created without committing",
+	)
+	cmd.Flags().BoolVar(
+		&opts.andRebase, "and-rebase", false,
+		"run 'hunk rebase autosquash' after creating the fixups",
+	)
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "and-rebase")
+
+	_ = cmd.MarkFlagRequired("onto")
+
+	return cmd
+}
+
+// absorbTarget is the plan for one target commit: the staged blocks to
+// fold into it.
+type absorbTarget struct {
+	commit git.CommitInfo
+	blocks []absorbCandidate
+}
+
+// absorbCandidate is a staged change block of a file.
+type absorbCandidate struct {
+	file  *diff.FileDiff
+	block diff.ChangeBlock
+}
+
+func runAbsorb(ctx context.Context, w io.Writer, opts absorbOptions) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	commits, err := executor.RebaseList(ctx, opts.onto)
+	if err != nil {
+		return err
+	}
+
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits after %s to absorb into", opts.onto)
+	}
+
+	diffText, err := executor.DiffCached(ctx)
+	if err != nil {
+		return err
+	}
+
+	if diffText == "" {
+		return fmt.Errorf("nothing staged to absorb")
+	}
+
+	parsed, err := diff.Parse(diffText)
+	if err != nil {
+		return err
+	}
+
+	targets, unabsorbed, err := planAbsorb(
+		ctx, executor, opts.onto, commits, parsed,
+	)
+	if err != nil {
+		return err
+	}
+
+	out := absorbOutput{
+		SchemaVersion: schema.Version,
+		Success:       true,
+		DryRun:        opts.dryRun,
+		Fixups:        make([]absorbFixup, 0, len(targets)),
+		Unabsorbed:    unabsorbed,
+	}
+
+	for _, target := range targets {
+		fixup := absorbFixup{
+			Target:        target.commit.Hash,
+			TargetSubject: target.commit.Subject,
+		}
+		for _, c := range target.blocks {
+			fixup.Blocks = append(fixup.Blocks, newAbsorbedBlock(c, ""))
+		}
+
+		out.Fixups = append(out.Fixups, fixup)
+	}
+
+	if !opts.dryRun && len(targets) > 0 {
+		hashes, err := commitFixups(ctx, cfg, parsed, targets)
+		if err != nil {
+			return err
+		}
+
+		for i, hash := range hashes {
+			out.Fixups[i].Commit = hash
+		}
+
+		if opts.andRebase {
+			out.Rebase, err = absorbRebase(ctx, executor, opts.onto)
+			if err != nil {
+				return err
+			}
+
+			out.Success = !out.Rebase.InProgress
+		}
+	}
+
+	out.Message = absorbMessage(out)
+
+	if cfg.JSONOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	resolver, err := newPathResolver(ctx, cfg, executor)
+	if err != nil {
+		return err
+	}
+
+	return formatAbsorbText(w, out, resolver.prefix())
+}
+
+// planAbsorb matches each staged change block to the branch commit it
+// fixes, returning the targets in branch order and the blocks left over.
+func planAbsorb(
+	ctx context.Context, executor git.Executor, onto string,
+	commits []git.CommitInfo, parsed *diff.ParsedDiff,
+) ([]*absorbTarget, []absorbedBlock, error) {
+	onBranch := make(map[string]bool, len(commits))
+	for _, c := range commits {
+		onBranch[c.Hash] = true
+	}
+
+	byCommit := make(map[string]*absorbTarget)
+	unabsorbed := make([]absorbedBlock, 0)
+
+	for file := range parsed.Files() {
+		if reason := absorbUnsupported(file); reason != "" {
+			unabsorbed = append(unabsorbed, absorbedBlock{
+				Path:   file.Path(),
+				Reason: reason,
+			})
+
+			continue
+		}
+
+		if file.ModeChanged() {
+			unabsorbed = append(unabsorbed, absorbedBlock{
+				Path:   file.Path(),
+				Reason: "mode change",
+			})
+		}
+
+		if len(file.Hunks) == 0 {
+			continue
+		}
+
+		blame, err := executor.Blame(ctx, onto, "HEAD", file.OldName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var blocks []plannedBlock
+		for block := range file.Blocks() {
+			hunk := file.Hunks[block.Ref.Hunk-1]
+			target, candidates, reason := blockTarget(
+				hunk, block, blame, onBranch, onto,
+			)
+
+			blocks = append(blocks, plannedBlock{
+				absorbCandidate: absorbCandidate{file: file, block: block},
+				target:          target,
+				candidates:      candidates,
+				reason:          reason,
+			})
+		}
+
+		for _, p := range separateOverlaps(blocks) {
+			if p.target == "" {
+				entry := newAbsorbedBlock(p.absorbCandidate, p.reason)
+				entry.Candidates = p.candidates
+				unabsorbed = append(unabsorbed, entry)
+
+				continue
+			}
+
+			if byCommit[p.target] == nil {
+				byCommit[p.target] = &absorbTarget{}
+			}
+			byCommit[p.target].blocks = append(
+				byCommit[p.target].blocks, p.absorbCandidate,
+			)
+		}
+	}
+
+	var targets []*absorbTarget
+	for _, c := range commits {
+		if target, ok := byCommit[c.Hash]; ok {
+			target.commit = c
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, unabsorbed, nil
+}
+
+// plannedBlock is a change block with the commit it's to be absorbed into,
+// or the reason it won't be.
+type plannedBlock struct {
+	absorbCandidate
+
+	target     string
+	candidates []string
+	reason     string
+}
+
+// separateOverlaps leaves out of absorbing any block of a file that shares
+// a selection line number with a block going elsewhere. Deletions are
+// selected by old line number and additions by new, so such blocks can't
+// be selected apart.
+func separateOverlaps(blocks []plannedBlock) []plannedBlock {
+	for changed := true; changed; {
+		changed = false
+
+		owners := make(map[int]map[string]bool)
+		for _, b := range blocks {
+			for _, line := range b.block.Lines {
+				n := line.EffectiveLineNum()
+				if owners[n] == nil {
+					owners[n] = make(map[string]bool)
+				}
+				owners[n][b.target] = true
+			}
+		}
+
+		for i := range blocks {
+			if blocks[i].target == "" {
+				continue
+			}
+
+			for _, line := range blocks[i].block.Lines {
+				if len(owners[line.EffectiveLineNum()]) > 1 {
+					blocks[i].candidates = []string{blocks[i].target}
+					blocks[i].target = ""
+					blocks[i].reason = "shares line numbers with " +
+						"another change"
+					changed = true
+
+					break
+				}
+			}
+		}
+	}
+
+	return blocks
+}
+
+// absorbUnsupported returns why a file's changes can't be absorbed at all,
+// or "" if its change blocks can be.
+func absorbUnsupported(file *diff.FileDiff) string {
+	switch {
+	case file.IsBinary:
+		return "binary file"
+	case file.IsNew:
+		return "new file"
+	case file.IsRenamed:
+		return "renamed file"
+	case file.IsCopied:
+		return "copied file"
+	case file.IsSymlink():
+		return "symlink"
+	}
+
+	return ""
+}
+
+// blockTarget returns the branch commit a change block fixes: the one that
+// last changed every line the block deletes, or for a pure addition, the
+// lines on either side of it. Otherwise it returns the reason there's no
+// single target, and the branch commits involved.
+func blockTarget(
+	hunk *diff.Hunk, block diff.ChangeBlock, blame []git.BlameLine,
+	onBranch map[string]bool, onto string,
+) (string, []string, string) {
+	var oldLines []int
+	for _, line := range block.Lines {
+		if line.Op == diff.OpDelete {
+			oldLines = append(oldLines, line.OldLineNum)
+		}
+	}
+
+	// An addition belongs with its neighbours, which are context lines
+	// since blocks are separated by context.
+	insertion := len(oldLines) == 0
+	if insertion {
+		if block.Start > 0 {
+			oldLines = append(oldLines, hunk.Lines[block.Start-1].OldLineNum)
+		}
+
+		end := block.Start + len(block.Lines)
+		if end < len(hunk.Lines) {
+			oldLines = append(oldLines, hunk.Lines[end].OldLineNum)
+		}
+
+		if len(oldLines) == 0 {
+			return "", nil, "no surrounding lines to blame"
+		}
+	}
+
+	var commits []string
+	old := false
+	for _, n := range oldLines {
+		if n < 1 || n > len(blame) {
+			return "", nil, fmt.Sprintf("line %d is not in HEAD", n)
+		}
+
+		line := blame[n-1]
+		if line.Boundary || !onBranch[line.Commit] {
+			old = true
+
+			continue
+		}
+
+		if !slices.Contains(commits, line.Commit) {
+			commits = append(commits, line.Commit)
+		}
+	}
+
+	switch {
+	case old && insertion:
+		return "", commits, "next to lines from before " + onto
+
+	case old:
+		return "", commits, "changes lines from before " + onto
+
+	case len(commits) > 1:
+		return "", commits, fmt.Sprintf(
+			"touches lines from %d commits", len(commits),
+		)
+	}
+
+	return commits[0], nil, ""
+}
+
+// newAbsorbedBlock describes a candidate block.
+func newAbsorbedBlock(c absorbCandidate, reason string) absorbedBlock {
+	return absorbedBlock{
+		Path:   c.file.Path(),
+		Block:  c.block.Ref.String(),
+		Lines:  c.file.BlockSpec(c.block),
+		Reason: reason,
+	}
+}
+
+// commitFixups creates a fixup commit for each target in turn, returning
+// their hashes. Each is built in a temporary index from the original HEAD
+// plus the blocks of its own and all earlier targets, so it holds exactly
+// its own blocks on top of the previous fixup. The real index is left as
+// it is: the absorbed blocks are now in HEAD, so only the others still
+// show as staged.
+func commitFixups(
+	ctx context.Context, cfg Config, parsed *diff.ParsedDiff,
+	targets []*absorbTarget,
+) ([]string, error) {
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	head, err := tmp.RevParse(ctx, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		absorbed []absorbCandidate
+		hashes   []string
+	)
+
+	for _, target := range targets {
+		absorbed = append(absorbed, target.blocks...)
+
+		// Generation merges selections for the same file in place, so
+		// each patch gets fresh ones.
+		selections := make([]*diff.FileSelection, 0, len(absorbed))
+		for _, c := range absorbed {
+			selections = append(selections,
+				c.block.Selection(c.file.Path()))
+		}
+
+		patchBytes, err := patch.GenerateWithOptions(
+			parsed, selections, patch.Options{ContentOnly: true},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tmp.ReadTree(ctx, head); err != nil {
+			return nil, err
+		}
+
+		if err := applyVerified(ctx, tmp, patchBytes); err != nil {
+			return nil, fmt.Errorf("failed to build fixup for %s: %w",
+				target.commit.ShortHash, err)
+		}
+
+		result, err := tmp.Commit(ctx, git.CommitOptions{
+			Fixup: target.commit.Hash,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit fixup for %s: %w",
+				target.commit.ShortHash, err)
+		}
+
+		hashes = append(hashes, result.Hash)
+	}
+
+	return hashes, nil
+}
+
+// absorbRebase squashes the fixups into their targets, unless changes are
+// left in the working tree that would stop the rebase.
+func absorbRebase(
+	ctx context.Context, executor *git.ShellExecutor, onto string,
+) (*absorbRebaseRun, error) {
+	status, err := executor.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(status.StagedFiles) > 0 || len(status.UnstagedFiles) > 0 {
+		return &absorbRebaseRun{
+			Message: "Rebase skipped: the working tree has changes; " +
+				"once it's clean, run 'hunk rebase autosquash --onto " +
+				onto + "'",
+		}, nil
+	}
+
+	commits, err := executor.RebaseList(ctx, onto)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, _ := buildAutosquashPlan(commits)
+
+	state, err := startSpecRebase(ctx, executor, onto, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &absorbRebaseRun{
+		Ran:         true,
+		InProgress:  state.InProgress,
+		HasConflict: state.State == git.RebaseStateConflict,
+		Message:     "Fixups squashed into their targets",
+	}
+
+	switch {
+	case run.HasConflict:
+		run.Message = "Rebase paused due to conflicts"
+	case run.InProgress:
+		run.Message = "Rebase in progress"
+	}
+
+	return run, nil
+}
+
+// absorbMessage summarizes the outcome of absorb.
+func absorbMessage(out absorbOutput) string {
+	verb := "Created"
+	if out.DryRun {
+		verb = "Would create"
+	}
+
+	msg := fmt.Sprintf("%s %d fixup commit(s)", verb, len(out.Fixups))
+	if len(out.Unabsorbed) > 0 {
+		msg += fmt.Sprintf(", %d change(s) not absorbed",
+			len(out.Unabsorbed))
+	}
+
+	return msg
+}
+
+// formatAbsorbText writes the outcome of absorb, with selectors relative to
+// the working directory prefix.
+func formatAbsorbText(w io.Writer, out absorbOutput, prefix string) error {
+	selector := func(b absorbedBlock) string {
+		path := diff.QuoteSelectionPath(output.RelativePath(prefix, b.Path))
+		if b.Lines == "" {
+			return path
+		}
+
+		return path + ":" + b.Lines
+	}
+
+	fmt.Fprintf(w, "%s.\n", out.Message)
+
+	for _, fixup := range out.Fixups {
+		hash := fixup.Commit
+		if hash == "" {
+			hash = fixup.Target
+		}
+
+		fmt.Fprintf(w, "\n  %s fixup! %s\n", hash[:7], fixup.TargetSubject)
+		for _, b := range fixup.Blocks {
+			fmt.Fprintf(w, "    %s\n", selector(b))
+		}
+	}
+
+	if len(out.Unabsorbed) > 0 {
+		fmt.Fprintln(w, "\nNot absorbed, left staged:")
+
+		for _, b := range out.Unabsorbed {
+			reason := b.Reason
+			if len(b.Candidates) > 0 {
+				var short []string
+				for _, c := range b.Candidates {
+					short = append(short, c[:7])
+				}
+				reason += " (" + strings.Join(short, ", ") + ")"
+			}
+
+			fmt.Fprintf(w, "  %s  %s\n", selector(b), reason)
+		}
+	}
+
+	if out.Rebase != nil {
+		fmt.Fprintf(w, "\n%s.\n", out.Rebase.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// absorbHistory is a branch of two commits off main: "Add alpha" creates
+// alpha.txt, and "Add beta" creates beta.txt and appends a line to
+// alpha.txt.
+var absorbHistory = []testutil.FileCommit{
+	{
+		Message: "Add alpha",
+		Files:   map[string]string{"alpha.txt": "a1\na2\na3\na4\na5\n"},
+	},
+	{
+		Message: "Add beta",
+		Files: map[string]string{
+			"beta.txt":  "b1\nb2\nb3\nb4\nb5\n",
+			"alpha.txt": "a1\na2\na3\na4\na5\na6\n",
+		},
+	},
+}
+
+func TestAbsorb(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, absorbHistory...)
+
+	// One block for each commit, one spanning both, one touching a line
+	// from before the branch, and a new file.
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\na4\nA5\nA6\n")
+	repo.WriteFile("beta.txt", "b1\nb2\nb3\nb4\nB5\n")
+	repo.WriteFile("base.txt", "Y1\nx2\nx3\n")
+	repo.WriteFile("new.txt", "new\n")
+	repo.Git("add", "-A")
+
+	alpha := strings.TrimSpace(repo.Git("rev-parse", "HEAD~1"))
+	beta := strings.TrimSpace(repo.Git("rev-parse", "HEAD"))
+
+	out := runJSON(t, repo.Dir, "absorb", "--onto", "main")
+	require.NoError(t, schema.Validate("absorb", out), string(out))
+
+	var result absorbOutput
+	require.NoError(t, json.Unmarshal(out, &result))
+
+	require.Len(t, result.Fixups, 2)
+	require.Equal(t, alpha, result.Fixups[0].Target)
+	require.Equal(t, []absorbedBlock{
+		{Path: "alpha.txt", Block: "@1.1", Lines: "2"},
+	}, result.Fixups[0].Blocks)
+	require.Equal(t, beta, result.Fixups[1].Target)
+	require.Equal(t, []absorbedBlock{
+		{Path: "beta.txt", Block: "@1.1", Lines: "5"},
+	}, result.Fixups[1].Blocks)
+
+	require.Equal(t, []absorbedBlock{
+		{
+			Path:       "alpha.txt",
+			Block:      "@1.2",
+			Lines:      "5-6",
+			Reason:     "touches lines from 2 commits",
+			Candidates: []string{alpha, beta},
+		},
+		{
+			Path:   "base.txt",
+			Block:  "@1.1",
+			Lines:  "1",
+			Reason: "changes lines from before main",
+		},
+		{Path: "new.txt", Reason: "new file"},
+	}, result.Unabsorbed)
+
+	// Each fixup holds just its own blocks.
+	require.Equal(t, "fixup! Add beta\nfixup! Add alpha\nAdd beta\n",
+		repo.Git("log", "--format=%s", "-3"))
+	require.Equal(t, "alpha.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD~1"))
+	require.Equal(t, "beta.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD"))
+	require.Equal(t, result.Fixups[1].Commit,
+		strings.TrimSpace(repo.Git("rev-parse", "HEAD")))
+
+	// The rest is still staged.
+	require.Equal(t, "alpha.txt\nbase.txt\nnew.txt\n",
+		repo.Git("diff", "--cached", "--name-only"))
+	require.Contains(t, repo.DiffCached(), "+A5\n+A6\n")
+	require.NotContains(t, repo.DiffCached(), "+A2")
+}
+
+func TestAbsorbDryRun(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, absorbHistory...)
+
+	repo.WriteFile("beta.txt", "b1\nB2\nb3\nb4\nb5\n")
+	repo.Git("add", "-A")
+	head := repo.GetFullHash()
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "absorb", "--onto", "main", "--dry-run",
+	)
+	require.NoError(t, err, out)
+	require.Contains(t, out, "Would create 1 fixup commit(s).")
+	require.Contains(t, out, "fixup! Add beta\n    beta.txt:2\n")
+
+	require.Equal(t, head, repo.GetFullHash())
+	require.Contains(t, repo.DiffCached(), "+B2")
+}
+
+func TestAbsorbAndRebase(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, absorbHistory...)
+
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\na4\na5\na6\n")
+	repo.WriteFile("beta.txt", "b1\nB2\nb3\nb4\nb5\n")
+	repo.Git("add", "-A")
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "absorb", "--onto", "main", "--and-rebase",
+	)
+	require.NoError(t, err, out)
+
+	var result absorbOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.True(t, result.Success)
+	require.NotNil(t, result.Rebase)
+	require.True(t, result.Rebase.Ran)
+
+	require.Equal(t, "Add beta\nAdd alpha\n",
+		repo.Git("log", "--format=%s", "main..HEAD"))
+	require.Equal(t, "a1\nA2\na3\na4\na5\n",
+		repo.Git("show", "HEAD~1:alpha.txt"))
+	require.Equal(t, "b1\nB2\nb3\nb4\nb5\n",
+		repo.Git("show", "HEAD:beta.txt"))
+	require.Empty(t, repo.DiffCached())
+}
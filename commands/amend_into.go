@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/patch"
+	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// amendIntoOutput is the JSON output for amend-into.
+type amendIntoOutput struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Success       bool                 `json:"success"`
+	Message       string               `json:"message"`
+	Target        string               `json:"target"`
+	TargetSubject string               `json:"target_subject"`
+	Fixup         string               `json:"fixup"`
+	InProgress    bool                 `json:"in_progress,omitempty"`
+	HasConflict   bool                 `json:"has_conflict,omitempty"`
+	Conflicts     []conflictInfoOutput `json:"conflicts,omitempty"`
+	Instructions  []string             `json:"instructions,omitempty"`
+}
+
+// amendIntoOptions holds the flags for the amend-into command.
+type amendIntoOptions struct {
+	onto  string
+	patch patch.Options
+}
+
+// NewAmendIntoCmd creates the amend-into command.
+func NewAmendIntoCmd() *cobra.Command {
+	var opts amendIntoOptions
+
+	cmd := &cobra.Command{
+		Use:   "amend-into <rev> FILE:LINES...",
+		Short: "Fold selected unstaged lines into an earlier commit",
+		Long: `Move selected unstaged lines into an earlier commit on the branch.
+
+The selected lines, as numbered by 'hunk diff', are committed as a
+"fixup!" commit for <rev>. A non-interactive rebase onto --onto then
+folds that fixup into <rev>, leaving every other commit, including any
+other fixups, as it was. <rev> must be one of the commits after --onto.
+
+The fixup is built in a temporary index seeded from HEAD, so the files
+the selections touch must not have staged changes. Any other local
+changes are stashed for the rebase and restored after it.
+
+If folding the fixup in conflicts with a later commit, the rebase stops
+and the conflicting files are reported; resolve them and run 'hunk
+rebase continue', or 'hunk rebase abort' to leave the fixup as the last
+commit instead.`,
+		Example: `  # This change belongs in the commit two back
+  hunk amend-into HEAD~2 main.go:10-20 --onto main
+
+  # Change blocks work too
+  hunk amend-into abc123 main.go:@2.1 utils.go:5 --onto main`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Later failures aren't usage errors.
+			cmd.SilenceUsage = true
+
+			return runAmendInto(
+				cmd.Context(), cmd.OutOrStdout(), args[0], args[1:],
+				opts,
+			)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.onto, "onto", "",
+		"base of the branch; <rev> must come after it (required)",
+	)
+
+	_ = cmd.MarkFlagRequired("onto")
+
+	return cmd
+}
+
+func runAmendInto(
+	ctx context.Context, w io.Writer, rev string, args []string,
+	opts amendIntoOptions,
+) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	state, err := executor.RebaseStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	if state.InProgress {
+		return fmt.Errorf("a rebase is already in progress")
+	}
+
+	target, err := executor.RevParse(ctx, rev)
+	if err != nil {
+		return err
+	}
+
+	commits, err := executor.RebaseList(ctx, opts.onto)
+	if err != nil {
+		return err
+	}
+
+	var info git.CommitInfo
+	for _, c := range commits {
+		if c.Hash == target {
+			info = c
+		}
+	}
+
+	if info.Hash == "" {
+		return fmt.Errorf("commit %s is not on the current branch "+
+			"after %s", rev, opts.onto)
+	}
+
+	selections, err := parseSelectionArgs(args, &opts.patch)
+	if err != nil {
+		return err
+	}
+
+	_, patchBytes, err := buildPatch(
+		ctx, cfg, executor, selections, opts.patch,
+	)
+	if err != nil {
+		return err
+	}
+
+	fixup, err := commitAmendFixup(ctx, cfg, executor, patchBytes, info)
+	if err != nil {
+		return err
+	}
+
+	// The committed lines are staged too, so the stash taken for the
+	// rebase holds only what is left.
+	err = executor.ApplyPatch(ctx, bytes.NewReader(patchBytes))
+	if err != nil {
+		return fmt.Errorf("failed to stage the committed lines: %w", err)
+	}
+
+	// Pick every commit as it is, with the new fixup moved up behind its
+	// target. Other fixups on the branch are left alone.
+	plan := &rebase.Spec{}
+	for _, c := range commits {
+		plan.Actions = append(plan.Actions, rebase.Action{
+			Action: rebase.ActionPick,
+			Commit: c.Hash,
+		})
+
+		if c.Hash == target {
+			plan.Actions = append(plan.Actions, rebase.Action{
+				Action: rebase.ActionFixup,
+				Commit: fixup,
+			})
+		}
+	}
+
+	executor.Autostash = true
+
+	state, err = startSpecRebase(ctx, executor, opts.onto, plan)
+	if err != nil {
+		return err
+	}
+
+	out := amendIntoOutput{
+		SchemaVersion: schema.Version,
+		Success:       !state.InProgress,
+		Target:        target,
+		TargetSubject: info.Subject,
+		Fixup:         fixup,
+		InProgress:    state.InProgress,
+		HasConflict:   state.State == git.RebaseStateConflict,
+	}
+
+	switch {
+	case out.HasConflict:
+		out.Message = "Fold-in paused due to conflicts"
+
+		for _, c := range state.Conflicts {
+			out.Conflicts = append(out.Conflicts, conflictInfoOutput{
+				File:         c.Path,
+				ConflictType: c.ConflictType,
+			})
+		}
+
+		out.Instructions = []string{
+			"Resolve conflicts in the listed files",
+			"Stage resolved files with 'git add <file>'",
+			"Continue with 'hunk rebase continue'",
+			"Or abort with 'hunk rebase abort'",
+		}
+
+	case out.InProgress:
+		out.Message = "Fold-in in progress"
+
+	default:
+		out.Message = fmt.Sprintf("Folded the selected lines into %s",
+			info.ShortHash)
+	}
+
+	if cfg.JSONOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	return formatAmendIntoText(w, out, info)
+}
+
+// commitAmendFixup commits the lines of patchBytes, a patch against the
+// index, as a fixup for info through a temporary index seeded from HEAD,
+// and returns its hash.
+func commitAmendFixup(
+	ctx context.Context, cfg Config, executor git.Executor,
+	patchBytes []byte, info git.CommitInfo,
+) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	if err := tmp.ReadTree(ctx, "HEAD"); err != nil {
+		return "", err
+	}
+
+	// The patch was taken against the index, so it only fits HEAD where
+	// the two agree.
+	parsed, err := diff.Parse(string(patchBytes))
+	if err != nil {
+		return "", err
+	}
+
+	if paths := patch.PreimagePaths(parsed); len(paths) > 0 {
+		staged, err := executor.IndexBlobs(ctx, paths...)
+		if err != nil {
+			return "", err
+		}
+
+		head, err := tmp.IndexBlobs(ctx, paths...)
+		if err != nil {
+			return "", err
+		}
+
+		for _, path := range paths {
+			if staged[path] != head[path] {
+				return "", fmt.Errorf("%s has staged changes; "+
+					"commit or unstage them first", path)
+			}
+		}
+	}
+
+	if err := applyVerified(ctx, tmp, patchBytes); err != nil {
+		return "", fmt.Errorf("failed to build fixup: %w", err)
+	}
+
+	result, err := tmp.Commit(ctx, git.CommitOptions{Fixup: info.Hash})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit fixup: %w", err)
+	}
+
+	return result.Hash, nil
+}
+
+// formatAmendIntoText writes the outcome of amend-into.
+func formatAmendIntoText(
+	w io.Writer, out amendIntoOutput, info git.CommitInfo,
+) error {
+	if !out.InProgress {
+		fmt.Fprintf(w, "Folded the selected lines into %s %s.\n",
+			info.ShortHash, info.Subject)
+
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s.\n", out.Message)
+
+	if out.HasConflict {
+		fmt.Fprintln(w, "")
+
+		for _, c := range out.Conflicts {
+			fmt.Fprintf(w, "  Conflict: %s\n", c.File)
+		}
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Resolve conflicts, then:")
+		fmt.Fprintln(w, "  hunk rebase continue  # to continue")
+		fmt.Fprintln(w, "  hunk rebase abort     # to abort")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// amendIntoHistory is a branch off main with "Add alpha", which creates
+// alpha.txt, then "Add beta", which creates beta.txt.
+var amendIntoHistory = []testutil.FileCommit{
+	{
+		Message: "Add alpha",
+		Files:   map[string]string{"alpha.txt": "a1\na2\na3\na4\na5\n"},
+	},
+	{
+		Message: "Add beta",
+		Files:   map[string]string{"beta.txt": "b1\nb2\nb3\n"},
+	},
+}
+
+func TestAmendInto(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, amendIntoHistory...)
+
+	// A fixup already on the branch stays where it is.
+	repo.WriteFile("beta.txt", "b1\nb2\nB3\n")
+	repo.CommitAll("fixup! Add beta")
+
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\na4\nA5\n")
+	repo.WriteFile("beta.txt", "B1\nb2\nB3\n")
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "amend-into", "HEAD~2", "alpha.txt:2",
+		"--onto", "main",
+	)
+	require.NoError(t, err, out)
+	require.NoError(t, schema.Validate("amend-into", []byte(out)), out)
+
+	var result amendIntoOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.True(t, result.Success)
+	require.Equal(t, "Add alpha", result.TargetSubject)
+	require.NotEmpty(t, result.Fixup)
+
+	require.Equal(t, "fixup! Add beta\nAdd beta\nAdd alpha\n",
+		repo.Git("log", "--format=%s", "main..HEAD"))
+	require.Equal(t, "a1\nA2\na3\na4\na5\n",
+		repo.Git("show", "HEAD~2:alpha.txt"))
+
+	// The lines not moved are still local changes.
+	require.Equal(t, "alpha.txt\nbeta.txt\n",
+		repo.Git("diff", "--name-only"))
+	require.Contains(t, repo.Git("diff"), "+A5")
+	require.NotContains(t, repo.Git("diff"), "+A2")
+	require.Empty(t, repo.DiffCached())
+}
+
+func TestAmendIntoConflict(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, amendIntoHistory...)
+
+	repo.WriteFile("alpha.txt", "a1\nX2\na3\na4\na5\n")
+	repo.CommitAll("Edit alpha")
+
+	repo.WriteFile("alpha.txt", "a1\nY2\na3\na4\na5\n")
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "amend-into", "HEAD~2", "alpha.txt:2",
+		"--onto", "main",
+	)
+	require.NoError(t, err, out)
+	require.NoError(t, schema.Validate("amend-into", []byte(out)), out)
+
+	var result amendIntoOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.False(t, result.Success)
+	require.True(t, result.InProgress)
+	require.True(t, result.HasConflict)
+	require.Equal(t, "alpha.txt", result.Conflicts[0].File)
+	require.NotEmpty(t, result.Instructions)
+
+	// Aborting leaves the fixup as the last commit.
+	abortOut, err := runHunkCommand(t, repo.Dir, "rebase", "abort")
+	require.NoError(t, err, abortOut)
+	require.Equal(t, "fixup! Add alpha\n",
+		repo.Git("log", "-1", "--format=%s"))
+}
+
+func TestAmendIntoRefuses(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, amendIntoHistory...)
+	head := repo.GetFullHash()
+
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\na4\na5\n")
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "amend-into", "main", "alpha.txt:2", "--onto", "main",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "is not on the current branch after main")
+
+	repo.StageFile("alpha.txt")
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\na4\nA5\n")
+
+	out, err = runHunkCommand(
+		t, repo.Dir, "amend-into", "HEAD~1", "alpha.txt:5",
+		"--onto", "main",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "alpha.txt has staged changes")
+
+	require.Equal(t, head, repo.GetFullHash())
+}
@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/roasbeef/hunk/diff"
 	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/patch"
 	"github.com/spf13/cobra"
 )
 
@@ -18,7 +21,10 @@ func NewApplyPatchCmd() *cobra.Command {
 		Long: `Apply a unified diff patch to the staging area.
 
 If no file is specified, reads from stdin.
-This is equivalent to 'git apply --cached'.`,
+This is equivalent to 'git apply --cached', except that patches carrying
+git index lines are first checked against the index: if a file's staged
+content no longer matches the patch's preimage blob, the patch is rejected
+as stale instead of being applied at a shifted offset.`,
 		Example: `  # Apply patch from file
   hunk apply-patch changes.patch
 
@@ -50,9 +56,14 @@ func runApplyPatch(ctx context.Context, w io.Writer, args []string) error {
 		input = f
 	}
 
+	patchBytes, err := io.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("failed to read patch: %w", err)
+	}
+
 	executor := git.NewShellExecutor(cfg.WorkDir)
 
-	if err := executor.ApplyPatch(ctx, input); err != nil {
+	if err := applyVerified(ctx, executor, patchBytes); err != nil {
 		return err
 	}
 
@@ -60,3 +71,27 @@ func runApplyPatch(ctx context.Context, w io.Writer, args []string) error {
 
 	return nil
 }
+
+// applyVerified applies a patch to the staging area after checking that the
+// index still holds the preimage blob of every file the patch touches.
+func applyVerified(
+	ctx context.Context, executor git.Executor, patchBytes []byte,
+) error {
+	parsed, err := diff.Parse(string(patchBytes))
+	if err != nil {
+		return err
+	}
+
+	if paths := patch.PreimagePaths(parsed); len(paths) > 0 {
+		index, err := executor.IndexBlobs(ctx, paths...)
+		if err != nil {
+			return err
+		}
+
+		if err := patch.VerifyPreimage(parsed, index); err != nil {
+			return err
+		}
+	}
+
+	return executor.ApplyPatch(ctx, bytes.NewReader(patchBytes))
+}
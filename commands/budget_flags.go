@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/roasbeef/hunk/output"
+	"github.com/spf13/cobra"
+)
+
+// budgetFlags holds the flags that limit how much of a diff is shown, so
+// large diffs can be read a page at a time.
+type budgetFlags struct {
+	maxLines   int
+	maxFiles   int
+	offset     int
+	hunkOffset int
+}
+
+// register adds the budget flags to cmd.
+func (f *budgetFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(
+		&f.maxLines, "max-lines", 0,
+		"show at most this many diff lines, summarizing the rest",
+	)
+	cmd.Flags().IntVar(
+		&f.maxFiles, "max-files", 0,
+		"show at most this many files, summarizing the rest",
+	)
+	cmd.Flags().IntVar(
+		&f.offset, "offset", 0,
+		"skip this many files, to page through a budgeted diff",
+	)
+	cmd.Flags().IntVar(
+		&f.hunkOffset, "hunk-offset", 0,
+		"skip this many hunks of the first file, to page within it",
+	)
+}
+
+// budget validates the flags and returns the budget they describe.
+func (f *budgetFlags) budget() (output.Budget, error) {
+	if f.maxLines < 0 || f.maxFiles < 0 || f.offset < 0 ||
+		f.hunkOffset < 0 {
+
+		return output.Budget{}, fmt.Errorf("--max-lines, --max-files, " +
+			"--offset and --hunk-offset can't be negative")
+	}
+
+	return output.Budget{
+		MaxLines:   f.maxLines,
+		MaxFiles:   f.maxFiles,
+		Offset:     f.offset,
+		HunkOffset: f.hunkOffset,
+	}, nil
+}
+
+// checkStream rejects budgets for --json-stream, whose records are written
+// before the size of the diff is known.
+func (f *budgetFlags) checkStream() error {
+	if f.maxLines != 0 || f.maxFiles != 0 || f.offset != 0 ||
+		f.hunkOffset != 0 {
+
+		return fmt.Errorf("--json-stream can't be combined with " +
+			"--max-lines, --max-files, --offset or --hunk-offset")
+	}
+
+	return nil
+}
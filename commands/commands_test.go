@@ -2,9 +2,12 @@ package commands_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/roasbeef/hunk/commands"
@@ -562,3 +565,1037 @@ func main() {}
 	require.NotContains(t, cached, "-func b1()")
 	require.NotContains(t, cached, "+func newB()")
 }
+
+// TestStageDryRunWarnsOnSplitMove verifies that a dry run warns when the
+// selection stages only one side of a block moved between files.
+func TestStageDryRunWarnsOnSplitMove(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	helper := "func helper(value int) int {\n" +
+		"\treturn value * multiplier\n" +
+		"}\n"
+
+	writeFile(t, dir, "a.go", "package a\n\n"+helper)
+	writeFile(t, dir, "b.go", "package a\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	// Move the helper from a.go to b.go.
+	writeFile(t, dir, "a.go", "package a\n")
+	writeFile(t, dir, "b.go", "package a\n\n"+helper)
+
+	tests := []struct {
+		args []string
+		warn bool
+	}{
+		{args: []string{"b.go:2-5"}, warn: true},
+		{args: []string{"b.go"}, warn: true},
+		{args: []string{"--", "a.go"}, warn: true},
+
+		// Selecting both sides produces no warning, whether by lines
+		// or by whole files.
+		{args: []string{"a.go:2-5", "b.go:2-5"}},
+		{args: []string{"a.go:2-5", "b.go"}},
+		{args: []string{"a.go", "--", "b.go"}},
+	}
+
+	for _, tt := range tests {
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append(
+			[]string{"--dir", dir, "stage", "--dry-run"}, tt.args...,
+		))
+
+		var stdout, stderr bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		rootCmd.SetErr(&stderr)
+
+		require.NoError(t, rootCmd.Execute(), tt.args)
+		require.NotEmpty(t, stdout.String(), tt.args)
+
+		if tt.warn {
+			require.Contains(t, stderr.String(),
+				"only one side of moved block 1", tt.args)
+		} else {
+			require.NotContains(t, stderr.String(), "warning",
+				tt.args)
+		}
+	}
+}
+
+// TestDiffCommandColor verifies that --color controls ANSI output and that
+// auto mode stays plain when writing to a non-terminal.
+func TestDiffCommandColor(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+	writeFile(t, dir, "main.go", "package main\n// changed\n")
+
+	run := func(args ...string) (string, error) {
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir, "diff"}, args...))
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+
+		err := rootCmd.Execute()
+
+		return stdout.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	require.NotContains(t, out, "\033[")
+	require.Contains(t, out, "+// changed")
+
+	out, err = run("--color=always")
+	require.NoError(t, err)
+	require.Contains(t, out, "\033[32m+// changed")
+
+	out, err = run("--no-context", "--old-line-numbers=false")
+	require.NoError(t, err)
+	require.NotContains(t, out, " package main")
+	require.Contains(t, out, "   2 +// changed")
+
+	_, err = run("--color=rainbow")
+	require.Error(t, err)
+}
+
+func TestPreviewCommandSideBySide(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n// old\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "package main\n// new\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "preview", "--format=side-by-side", "--width=60",
+	})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+	require.Contains(t, stdout.String(), "   2 - // old")
+	require.Contains(t, stdout.String(), "|    2 + // new")
+
+	// Unknown formats are rejected.
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "preview", "--format=fancy"})
+	rootCmd.SetOut(&stdout)
+
+	require.Error(t, rootCmd.Execute())
+}
+
+func TestDiffCommandJSONStream(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.go", "package a\n")
+	writeFile(t, dir, "b.go", "package b\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "a.go", "package a\n// a\n")
+	writeFile(t, dir, "b.go", "package b\n// b\n")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "diff", "--json-stream"})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 5)
+	require.Contains(t, lines[0], `"type":"file"`)
+	require.Contains(t, lines[1], `"type":"hunk"`)
+	require.Contains(t, lines[2], `"type":"file"`)
+	require.Contains(t, lines[3], `"type":"hunk"`)
+	require.Contains(t, lines[4], `"type":"summary"`)
+	require.Contains(t, lines[4], `"files":2`)
+}
+
+// TestStageRenamedFile verifies that staging lines of a renamed file moves
+// the file in the index and stages only the selected edits.
+func TestStageRenamedFile(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	writeFile(t, dir, "old.txt", content)
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	// Rename in the working tree with two separate edits. Intent-to-add
+	// makes the new path visible to the unstaged diff.
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.txt")))
+	edited := strings.Replace(content, "line 3\n", "line three\n", 1)
+	edited = strings.Replace(edited, "line 17\n", "line seventeen\n", 1)
+	writeFile(t, dir, "new.txt", edited)
+	gitCmd(t, dir, "add", "-N", "new.txt")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "--json", "diff"})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), `"status": "renamed"`)
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "new.txt:3"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	status := gitCmd(t, dir, "status", "--porcelain")
+	require.Contains(t, status, "old.txt -> new.txt")
+
+	staged := gitCmd(t, dir, "diff", "--cached", "-M")
+	require.Contains(t, staged, "rename from old.txt")
+	require.Contains(t, staged, "+line three")
+	require.NotContains(t, staged, "+line seventeen")
+
+	// With rename detection off the same change is a delete plus an add.
+	gitCmd(t, dir, "reset", "-q")
+	gitCmd(t, dir, "add", "-N", "new.txt")
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "--no-renames", "--json", "diff"})
+
+	stdout.Reset()
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.NotContains(t, stdout.String(), `"status": "renamed"`)
+	require.Contains(t, stdout.String(), `"status": "deleted"`)
+}
+
+// TestApplyPatchRejectsStalePatch verifies that a patch whose index line no
+// longer matches the staged content fails instead of applying at an offset.
+func TestApplyPatchRejectsStalePatch(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "package main\n\n// Added.\nfunc main() {}\n")
+	patchFile := filepath.Join(t.TempDir(), "change.patch")
+	require.NoError(t, os.WriteFile(
+		patchFile, []byte(gitCmd(t, dir, "diff")), 0644,
+	))
+
+	// Move the index on by staging an unrelated edit at the top.
+	writeFile(t, dir, "main.go", "// Header.\npackage main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "apply-patch", patchFile})
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	err := rootCmd.Execute()
+	require.ErrorContains(t, err, "stale patch")
+	require.ErrorContains(t, err, "main.go: index has")
+
+	// The staged content is untouched.
+	staged := gitCmd(t, dir, "diff", "--cached")
+	require.NotContains(t, staged, "+// Added.")
+}
+
+// TestStageNewFilePartially verifies that lines of an intent-to-add file can
+// be staged on their own.
+func TestStageNewFilePartially(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "new.go", "package main\n\nfunc a() {}\n\nfunc b() {}\n")
+	gitCmd(t, dir, "add", "-N", "new.go")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "new.go:1-3"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	staged := gitCmd(t, dir, "show", ":new.go")
+	require.Equal(t, "package main\n\nfunc a() {}\n", staged)
+}
+
+// TestStageModeChange verifies that a mode change can be staged with or
+// without the file's content changes.
+func TestStageModeChange(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "run.sh", "#!/bin/sh\necho one\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "run.sh", "#!/bin/sh\necho one\necho two\n")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "run.sh"), 0755))
+
+	stage := func(args ...string) {
+		t.Helper()
+
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir, "stage"}, args...))
+		rootCmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, rootCmd.Execute())
+	}
+
+	// Only the mode.
+	stage("--mode", "run.sh")
+	staged := gitCmd(t, dir, "diff", "--cached")
+	require.Contains(t, staged, "new mode 100755")
+	require.NotContains(t, staged, "+echo two")
+
+	// Only the content.
+	gitCmd(t, dir, "reset", "-q")
+	stage("--content-only", "run.sh:3")
+	staged = gitCmd(t, dir, "diff", "--cached")
+	require.NotContains(t, staged, "new mode")
+	require.Contains(t, staged, "+echo two")
+
+	// Both by default.
+	gitCmd(t, dir, "reset", "-q")
+	stage("run.sh:3")
+	staged = gitCmd(t, dir, "diff", "--cached")
+	require.Contains(t, staged, "new mode 100755")
+	require.Contains(t, staged, "+echo two")
+}
+
+// TestStageSymlink verifies that a retargeted symlink is staged as a whole.
+func TestStageSymlink(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink("old-target", link))
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	require.NoError(t, os.Remove(link))
+	require.NoError(t, os.Symlink("new-target", link))
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "diff"})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), "link (symlink)")
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "link:1"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	require.Contains(t, gitCmd(t, dir, "ls-files", "-s", "link"), "120000")
+	require.Equal(t, "new-target", gitCmd(t, dir, "cat-file", "-p", ":link"))
+}
+
+// TestStageBinaryFile verifies that a binary file is staged whole by naming
+// it without a line spec, and that selecting its lines fails.
+func TestStageBinaryFile(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "logo.bin", "abc\x00def")
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "logo.bin", "abc\x00defg")
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "diff"})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), "(7 -> 8 bytes)")
+
+	// Sizes are looked up rather than read from a binary payload, which
+	// shown diffs leave out.
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "--json", "diff", "logo.bin"})
+
+	stdout.Reset()
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), `"old_size": 7`)
+	require.Contains(t, stdout.String(), `"new_size": 8`)
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "logo.bin:1"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	require.ErrorContains(t, rootCmd.Execute(), "logo.bin is a binary file")
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "logo.bin"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	require.Equal(t, "8\n", gitCmd(t, dir, "cat-file", "-s", ":logo.bin"))
+
+	// The text file is left unstaged.
+	require.Equal(t, "logo.bin\n", gitCmd(t, dir, "diff", "--cached",
+		"--name-only"))
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "diff", "--staged"})
+
+	stdout.Reset()
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), "(7 -> 8 bytes)")
+}
+
+// TestStageSubstantiveOnly verifies that reformatting is left unstaged while
+// the real edits in the same hunk are staged.
+func TestStageSubstantiveOnly(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc a() {\nx := 1\n"+
+		"return\n}\n\nfunc b() {\n\ty := 2\nz  :=  3\n}\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc a() {\n\tx := 1\n"+
+		"\n\treturn\n}\n\nfunc b() {\n\ty := 3\n\tz := 3\n}\n")
+
+	// The whitespace-insensitive view shows only the real edit.
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "diff", "--ignore-whitespace", "--ignore-blank-lines",
+	})
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	require.NoError(t, rootCmd.Execute())
+	require.Contains(t, stdout.String(), "+\ty := 3")
+	require.NotContains(t, stdout.String(), "x := 1")
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "stage", "--substantive-only", "main.go",
+	})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	staged := gitCmd(t, dir, "diff", "--cached")
+	require.Contains(t, staged, "+\ty := 3")
+	require.NotContains(t, staged, "+\tx := 1")
+
+	// Only whitespace changes are left unstaged.
+	require.Empty(t, gitCmd(t, dir, "diff", "--ignore-all-space",
+		"--ignore-blank-lines"))
+	require.Contains(t, gitCmd(t, dir, "show", ":main.go"),
+		"\ty := 3\nz  :=  3\n")
+}
+
+// TestStageUnusualPaths verifies staging files whose names contain spaces,
+// UTF-8 and colons, using plain, quoted and -- separated paths.
+func TestStageUnusualPaths(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	paths := []string{"my file.go", "café.go", "a:b.go", "x:1"}
+	for _, path := range paths {
+		writeFile(t, dir, path, "one\ntwo\n")
+	}
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	for _, path := range paths {
+		writeFile(t, dir, path, "one\nnew\ntwo\nlast\n")
+	}
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "stage",
+		"my file.go:2", `"caf\303\251.go":2`, `"a:b.go":4`, "--", "x:1",
+	})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	for path, want := range map[string]string{
+		"my file.go": "one\nnew\ntwo\n",
+		"café.go":    "one\nnew\ntwo\n",
+		"a:b.go":     "one\ntwo\nlast\n",
+		"x:1":        "one\nnew\ntwo\nlast\n",
+	} {
+		require.Equal(t, want, gitCmd(t, dir, "show", ":"+path), path)
+	}
+}
+
+// TestStageFromSubdirectory verifies that selection paths are taken relative
+// to the working directory, including paths outside of it.
+func TestStageFromSubdirectory(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "pkg"), 0755))
+	writeFile(t, dir, "top.go", "one\ntwo\n")
+	writeFile(t, dir, "pkg/foo.go", "one\ntwo\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "top.go", "one\nnew\ntwo\nlast\n")
+	writeFile(t, dir, "pkg/foo.go", "one\nnew\ntwo\nlast\n")
+
+	pkg := filepath.Join(dir, "pkg")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", pkg, "stage", "./foo.go:2", "../top.go:4",
+	})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	require.Equal(t, "one\nnew\ntwo\n", gitCmd(t, dir, "show", ":pkg/foo.go"))
+	require.Equal(t, "one\ntwo\nlast\n", gitCmd(t, dir, "show", ":top.go"))
+
+	// Paths must stay inside the repository.
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", pkg, "stage", "../../x.go:1"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	err := rootCmd.Execute()
+	require.ErrorContains(t, err, "outside the repository")
+}
+
+// TestDiffFromSubdirectory verifies that hints are relative to the working
+// directory, --relative-paths applies to headers and JSON reports both
+// forms of each path.
+func TestDiffFromSubdirectory(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "pkg"), 0755))
+	writeFile(t, dir, "top.go", "one\n")
+	writeFile(t, dir, "pkg/foo.go", "one\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "top.go", "one\ntwo\n")
+	writeFile(t, dir, "pkg/foo.go", "one\ntwo\n")
+
+	pkg := filepath.Join(dir, "pkg")
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", pkg}, args...))
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		return stdout.String()
+	}
+
+	require.Equal(t,
+		"hunk stage foo.go:@1.1  # +1 -0: two\n"+
+			"hunk stage ../top.go:@1.1  # +1 -0: two\n",
+		run("diff", "--stage-hints"),
+	)
+
+	text := run("diff", "--color=never", "--relative-paths")
+	require.Contains(t, text, "../top.go")
+	require.Contains(t, text, "foo.go")
+	require.NotContains(t, text, "pkg/foo.go")
+
+	jsonOut := run("--json", "diff")
+	require.Contains(t, jsonOut, `"path": "pkg/foo.go"`)
+	require.Contains(t, jsonOut, `"relative_path": "foo.go"`)
+	require.Contains(t, jsonOut, `"relative_path": "../top.go"`)
+}
+
+// TestDiffCommandBudget verifies that --max-files summarizes the files past
+// the budget and that --offset pages on from there.
+func TestDiffCommandBudget(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.go", "package a\n")
+	writeFile(t, dir, "b.go", "package b\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "a.go", "package a\n// a\n")
+	writeFile(t, dir, "b.go", "package b\n// b\n")
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir}, args...))
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		return stdout.String()
+	}
+
+	first := run("diff", "--color=never", "--max-files", "1")
+	require.Contains(t, first, "+// a")
+	require.NotContains(t, first, "+// b")
+	require.Contains(t, first, "  b.go: 1 hunk(s), +1 -0, lines 2\n")
+	require.Contains(t, first, "[next page: --offset 1]")
+	require.Contains(t, first,
+		"1 insertions(+), 0 deletions(-) in the lines shown")
+
+	second := run("diff", "--color=never", "--max-files", "1", "--offset", "1")
+	require.NotContains(t, second, "+// a")
+	require.Contains(t, second, "+// b")
+	require.NotContains(t, second, "next page")
+
+	jsonOut := run("--json", "diff", "--max-files", "1")
+	require.Contains(t, jsonOut, `"next_offset": 1`)
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "diff", "--json-stream", "--max-lines", "5",
+	})
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	require.Error(t, rootCmd.Execute())
+}
+
+// TestDiffCommandBudgetHunkPaging verifies that paging reaches every hunk of
+// a file too long for one page, instead of moving on to the next file.
+func TestDiffCommandBudgetHunkPaging(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	var base, work strings.Builder
+	for i := 1; i <= 30; i++ {
+		line := "line " + strconv.Itoa(i) + "\n"
+		base.WriteString(line)
+		if i%10 == 5 {
+			line = "changed " + strconv.Itoa(i) + "\n"
+		}
+		work.WriteString(line)
+	}
+
+	writeFile(t, dir, "a.txt", base.String())
+	writeFile(t, dir, "b.txt", "b\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "a.txt", work.String())
+	writeFile(t, dir, "b.txt", "b\nmore b\n")
+
+	var pages []string
+	args := []string{"diff", "--color=never", "--max-lines", "10"}
+	for {
+		require.Less(t, len(pages), 10, "paging didn't end")
+
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir}, args...))
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		text := stdout.String()
+		pages = append(pages, text)
+
+		_, next, ok := strings.Cut(text, "[next page: ")
+		if !ok {
+			break
+		}
+
+		next, _, _ = strings.Cut(next, "]")
+		args = append(args[:4], strings.Fields(next)...)
+	}
+
+	// Each added line turns up on exactly one page.
+	all := strings.Join(pages, "")
+	for _, added := range []string{
+		"+changed 5", "+changed 15", "+changed 25", "+more b",
+	} {
+		require.Equal(t, 1, strings.Count(all, added), added)
+	}
+
+	require.Contains(t, pages[0], "[next page: --offset 0 --hunk-offset")
+}
+
+// TestStagePartialContext verifies that a selection surrounded by
+// unselected changes is staged with the context the index actually has.
+// Such a selection used to get a hunk with no context at all, which git
+// apply either rejected or anchored to the end of the file.
+func TestStagePartialContext(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		work string
+		sel  string
+
+		// was is the hunk that used to be generated, and want the
+		// one generated now.
+		was  string
+		want string
+
+		// wantIndex is the file in the index after staging.
+		wantIndex string
+	}{{
+		name: "deletion between unselected deletions",
+		base: "package main\n// del1.\n// del2.\n// del3.\n" +
+			"// del4.\nfunc main() {}\n",
+		work: "package main\nfunc main() {}\n",
+		sel:  "main.go:3",
+		was:  "@@ -3,1 +2,0 @@\n-// del2.\n",
+		want: "@@ -1,6 +1,5 @@\n package main\n // del1.\n" +
+			"-// del2.\n // del3.\n // del4.\n func main() {}\n",
+		wantIndex: "package main\n// del1.\n// del3.\n// del4.\n" +
+			"func main() {}\n",
+	}, {
+		name: "addition between unselected additions",
+		base: "package main\nfunc main() {}\n",
+		work: "package main\n// line A.\n// line B.\n// line C.\n" +
+			"func main() {}\n",
+		sel: "main.go:3",
+		was: "@@ -2,0 +3,1 @@\n+// line B.\n",
+		want: "@@ -1,2 +1,3 @@\n package main\n+// line B.\n" +
+			" func main() {}\n",
+		wantIndex: "package main\n// line B.\nfunc main() {}\n",
+	}, {
+		name:      "part of a deleted file",
+		base:      "package main\nfunc main() {}\n",
+		sel:       "main.go:2",
+		was:       "@@ -2,1 +0,0 @@\n-func main() {}\n",
+		want:      "@@ -1,2 +1,1 @@\n package main\n-func main() {}\n",
+		wantIndex: "package main\n",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, cleanup := setupTestRepo(t)
+			defer cleanup()
+
+			writeFile(t, dir, "main.go", tc.base)
+			gitCmd(t, dir, "add", "-A")
+			gitCmd(t, dir, "commit", "-m", "initial")
+
+			// No new content deletes the file.
+			if tc.work == "" {
+				require.NoError(t, os.Remove(
+					filepath.Join(dir, "main.go"),
+				))
+			} else {
+				writeFile(t, dir, "main.go", tc.work)
+			}
+
+			var stdout bytes.Buffer
+			rootCmd := commands.NewRootCmd()
+			rootCmd.SetArgs([]string{
+				"--dir", dir, "stage", "--dry-run", tc.sel,
+			})
+			rootCmd.SetOut(&stdout)
+			require.NoError(t, rootCmd.Execute())
+
+			require.NotContains(t, stdout.String(), tc.was)
+			require.Contains(t, stdout.String(), tc.want)
+
+			rootCmd = commands.NewRootCmd()
+			rootCmd.SetArgs([]string{"--dir", dir, "stage", tc.sel})
+			rootCmd.SetOut(&bytes.Buffer{})
+			require.NoError(t, rootCmd.Execute())
+
+			require.Equal(t, tc.wantIndex,
+				gitCmd(t, dir, "show", ":main.go"))
+		})
+	}
+}
+
+// TestStageBlockSelector verifies that a change block selector stages just
+// that block.
+func TestStageBlockSelector(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "one\ntwo\nthree\nfour\nfive\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "one\nTWO\nthree\nfour\nFIVE\n")
+
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "main.go:@1.2"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	require.Equal(t, "one\ntwo\nthree\nfour\nFIVE\n",
+		gitCmd(t, dir, "show", ":main.go"))
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "main.go:@1.2"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetErr(&bytes.Buffer{})
+	require.ErrorContains(t, rootCmd.Execute(), "no change block @1.2")
+}
+
+// TestStageBlockCrossedNumbers verifies that a block whose deletion has
+// the same number as another block's addition stages only its own lines,
+// both by reference and by the selector stage hints give for it.
+func TestStageBlockCrossedNumbers(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "f.txt", "1\n2\n3\n4\n5\n6\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	// Insert a, b and c after line 2 and delete line 5.
+	writeFile(t, dir, "f.txt", "1\n2\na\nb\nc\n3\n4\n6\n")
+
+	var hints bytes.Buffer
+	rootCmd := commands.NewRootCmd()
+	rootCmd.SetArgs([]string{
+		"--dir", dir, "--json", "diff", "--stage-hints",
+	})
+	rootCmd.SetOut(&hints)
+	require.NoError(t, rootCmd.Execute())
+
+	var out struct {
+		Hints []struct {
+			Selector string `json:"selector"`
+			Lines    string `json:"lines"`
+		} `json:"hints"`
+	}
+	require.NoError(t, json.Unmarshal(hints.Bytes(), &out))
+	require.Len(t, out.Hints, 2)
+	require.Equal(t, "f.txt:-5", out.Hints[1].Lines)
+
+	for _, sel := range []string{out.Hints[1].Selector, out.Hints[1].Lines} {
+		var stdout bytes.Buffer
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs([]string{
+			"--dir", dir, "stage", "--dry-run", sel,
+		})
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		require.Contains(t, stdout.String(), "-5\n", sel)
+		require.NotContains(t, stdout.String(), "+c", sel)
+	}
+
+	rootCmd = commands.NewRootCmd()
+	rootCmd.SetArgs([]string{"--dir", dir, "stage", "f.txt:@1.2"})
+	rootCmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, rootCmd.Execute())
+
+	require.Equal(t, "1\n2\n3\n4\n6\n", gitCmd(t, dir, "show", ":f.txt"))
+}
+
+// TestCommitSelection verifies that committing selected lines leaves other
+// staged changes alone and doesn't stage a reversal of the commit.
+func TestCommitSelection(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "one\ntwo\nthree\nfour\nfive\n")
+	writeFile(t, dir, "other.go", "other\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "other.go", "other\nstaged\n")
+	gitCmd(t, dir, "add", "other.go")
+	writeFile(t, dir, "main.go", "one\nTWO\nthree\nfour\nFIVE\n")
+
+	run := func(args ...string) string {
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir, "commit"}, args...))
+		rootCmd.SetOut(&bytes.Buffer{})
+
+		var stderr bytes.Buffer
+		rootCmd.SetErr(&stderr)
+		require.NoError(t, rootCmd.Execute())
+
+		return stderr.String()
+	}
+
+	// With --update-index, the committed lines are added to the index.
+	require.Empty(t, run("--update-index", "main.go:@1.1", "-m", "second"))
+
+	require.Equal(t, "main.go\n",
+		gitCmd(t, dir, "show", "--name-only", "--format=", "HEAD"))
+	require.Equal(t, "one\nTWO\nthree\nfour\nfive\n",
+		gitCmd(t, dir, "show", "HEAD:main.go"))
+
+	// other.go is still staged, and main.go has nothing staged.
+	require.Equal(t, "other.go\n",
+		gitCmd(t, dir, "diff", "--cached", "--name-only"))
+	require.Equal(t, "one\nTWO\nthree\nfour\nfive\n",
+		gitCmd(t, dir, "show", ":main.go"))
+
+	// By default the index is left exactly as it was.
+	index, err := os.ReadFile(filepath.Join(dir, ".git", "index"))
+	require.NoError(t, err)
+
+	require.Empty(t, run("main.go:5", "-m", "fifth"))
+
+	require.Equal(t, "one\nTWO\nthree\nfour\nFIVE\n",
+		gitCmd(t, dir, "show", "HEAD:main.go"))
+	after, err := os.ReadFile(filepath.Join(dir, ".git", "index"))
+	require.NoError(t, err)
+	require.Equal(t, index, after)
+
+	// Lines that were already staged leave nothing to update.
+	writeFile(t, dir, "main.go", "one\nTWO\nthree\nfour\nFIVE\nsix\n")
+	gitCmd(t, dir, "add", "main.go")
+	require.Empty(t, run("--update-index", "main.go:6", "-m", "sixth"))
+	require.Equal(t, "other.go\n",
+		gitCmd(t, dir, "diff", "--cached", "--name-only"))
+
+	// Staged changes to the same lines keep the index as it was.
+	writeFile(t, dir, "main.go", "uno\nTWO\nthree\nfour\nFIVE\nsix\n")
+	gitCmd(t, dir, "add", "main.go")
+	writeFile(t, dir, "main.go", "ONE\nTWO\nthree\nfour\nFIVE\nsix\n")
+	require.Contains(t, run("--update-index", "main.go:1", "-m", "first"),
+		"warning: index not updated")
+	require.Equal(t, "uno\nTWO\nthree\nfour\nFIVE\nsix\n",
+		gitCmd(t, dir, "show", ":main.go"))
+}
+
+// TestCommitOptions verifies the commit flags that go beyond -m.
+func TestCommitOptions(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	run := func(stdin string, args ...string) error {
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append([]string{"--dir", dir, "commit"}, args...))
+		rootCmd.SetIn(strings.NewReader(stdin))
+		rootCmd.SetOut(&bytes.Buffer{})
+		rootCmd.SetErr(&bytes.Buffer{})
+
+		return rootCmd.Execute()
+	}
+
+	// A multi-line message from stdin, with trailers.
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "main.go")
+	require.NoError(t, run("add main\n\nWith a body.\n",
+		"-F", "-", "--trailer", "Refs=42", "--signoff"))
+
+	body := gitCmd(t, dir, "log", "-1", "--format=%B")
+	require.Contains(t, body, "add main\n\nWith a body.\n")
+	require.Contains(t, body, "Refs: 42")
+	require.Contains(t, body, "Signed-off-by: Test User")
+
+	// Amending with nothing staged rewrites the author only.
+	require.NoError(t, run("", "--amend", "--author", "A U Thor <a@u.com>"))
+	require.Equal(t, "A U Thor add main\n",
+		gitCmd(t, dir, "log", "-1", "--format=%an %s"))
+
+	// A fixup of selected lines.
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n// x\n")
+	require.NoError(t, run("", "--fixup", "HEAD~1", "main.go:4"))
+	require.Equal(t, "fixup! initial\n",
+		gitCmd(t, dir, "log", "-1", "--format=%s"))
+
+	require.ErrorContains(t, run("", "--trailer", "Refs=1"),
+		"commit message required")
+	require.ErrorContains(t, run("", "-m", "x", "--trailer", "nokey"),
+		"invalid trailer")
+	require.Error(t, run("", "-m", "x", "--amend", "--fixup", "HEAD"))
+}
+
+// TestCommitCommandJSON verifies the JSON description of a new commit, and
+// that a hook rejecting it comes back as a structured error.
+func TestCommitCommandJSON(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "one\ntwo\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	run := func(args ...string) (map[string]any, error) {
+		rootCmd := commands.NewRootCmd()
+		rootCmd.SetArgs(append(
+			[]string{"--dir", dir, "--json", "commit"}, args...,
+		))
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		rootCmd.SetErr(&bytes.Buffer{})
+		err := rootCmd.Execute()
+
+		var result map[string]any
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+
+		return result, err
+	}
+
+	writeFile(t, dir, "main.go", "one\nTWO\nthree\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	result, err := run("-m", "update main")
+	require.NoError(t, err)
+	require.Equal(t, true, result["success"])
+
+	commit := result["commit"].(map[string]any)
+	require.Equal(t, strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD")),
+		commit["hash"])
+	require.Equal(t,
+		strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD~1")),
+		commit["parents"].([]any)[0])
+	require.Equal(t, "update main", commit["subject"])
+	require.Equal(t, float64(2), commit["additions"])
+	require.Equal(t, float64(1), commit["deletions"])
+	require.Len(t, commit["files"], 1)
+
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0o755))
+	require.NoError(t, os.WriteFile(
+		hook, []byte("#!/bin/sh\necho 'lint: main.go:2' >&2\nexit 1\n"),
+		0o755,
+	))
+
+	writeFile(t, dir, "main.go", "one\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	result, err = run("-m", "rejected")
+	require.ErrorContains(t, err, "pre-commit hook failed")
+	require.Equal(t, false, result["success"])
+	require.Nil(t, result["commit"])
+
+	hookErr := result["error"].(map[string]any)
+	require.Equal(t, "pre-commit", hookErr["hook"])
+	require.Equal(t, float64(1), hookErr["exit_code"])
+	require.Equal(t, "lint: main.go:2\n", hookErr["output"])
+}
@@ -1,48 +1,205 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/roasbeef/hunk/diff"
 	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/patch"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
 // NewCommitCmd creates the commit command.
 func NewCommitCmd() *cobra.Command {
-	var message string
+	var opts commitOptions
 
 	cmd := &cobra.Command{
 		Use:   "commit",
-		Short: "Commit staged changes",
+		Short: "Commit staged changes or selected lines",
 		Long: `Create a commit with the currently staged changes.
 
-This is a thin wrapper around 'git commit' for convenience.`,
+This is a thin wrapper around 'git commit' for convenience.
+
+Given FILE:LINES selections, the selected lines are committed directly
+instead, without staging them first. The commit is built in a temporary
+index seeded from HEAD, so it holds exactly the selected changes
+regardless of what is staged, and concurrent users of the repository's
+index don't race with it. Line numbers and change blocks refer to the
+changes since HEAD ('git diff HEAD').
+
+The real index is left exactly as it was, so unless the lines were
+already staged, it shows them as staged reversals of the new commit.
+--update-index applies the committed lines to it as well, keeping
+anything else that was staged.
+
+--amend, --fixup and --squash work as in git, as do --author, --date,
+--signoff, --trailer and --allow-empty. -F reads a multi-line message
+from a file, or from stdin when given -.
+
+--validate CMD runs CMD against the tree about to be committed, as 'hunk
+validate' does, and commits only if it passes.`,
 		Example: `  # Commit with a message
   hunk commit -m "add error handling"
 
   # Stage and commit in one command
-  hunk stage main.go:10-20 && hunk commit -m "fix bug"`,
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			if message == "" {
-				return fmt.Errorf("commit message required (-m)")
+  hunk stage main.go:10-20 && hunk commit -m "fix bug"
+
+  # Commit lines without touching what is staged
+  hunk commit main.go:10-20 utils.go:@1.1 -m "fix bug"
+
+  # Fold staged changes into an earlier commit on the next autosquash
+  hunk commit --fixup abc123
+
+  # Commit only if the staged changes pass the tests on their own
+  hunk commit --validate "go test ./..." -m "fix bug"
+
+  # Multi-line message with trailers
+  printf 'fix bug\n\nDetails.\n' | hunk commit -F - --trailer Refs=42`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.readMessage(cmd.InOrStdin()); err != nil {
+				return err
+			}
+
+			// Later failures aren't usage errors, and usage text would
+			// corrupt a JSON error on stdout.
+			cmd.SilenceUsage = true
+
+			// Arguments after -- are literal paths.
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				opts.patch.Files = append(
+					opts.patch.Files, args[dash:]...,
+				)
+				args = args[:dash]
+			}
+
+			if len(args) > 0 || len(opts.patch.Files) > 0 {
+				return runCommitSelection(
+					cmd.Context(), cmd.OutOrStdout(),
+					cmd.ErrOrStderr(), args, opts,
+				)
 			}
 
-			return runCommit(cmd.Context(), cmd.OutOrStdout(), message)
+			return runCommit(
+				cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+				opts,
+			)
 		},
 	}
 
 	cmd.Flags().StringVarP(
-		&message, "message", "m", "",
+		&opts.commit.Message, "message", "m", "",
 		"commit message",
 	)
-	_ = cmd.MarkFlagRequired("message")
+	cmd.Flags().StringVarP(
+		&opts.commit.MessageFile, "file", "F", "",
+		"read the commit message from a file, or - for stdin",
+	)
+	cmd.MarkFlagsMutuallyExclusive("message", "file")
+
+	cmd.Flags().BoolVar(
+		&opts.commit.Amend, "amend", false,
+		"replace the last commit, keeping its message unless one is given",
+	)
+	cmd.Flags().StringVar(
+		&opts.commit.Fixup, "fixup", "",
+		"create a fixup! commit for the given commit",
+	)
+	cmd.Flags().StringVar(
+		&opts.commit.Squash, "squash", "",
+		"create a squash! commit for the given commit",
+	)
+	cmd.MarkFlagsMutuallyExclusive("amend", "fixup", "squash")
+
+	cmd.Flags().StringVar(
+		&opts.commit.Author, "author", "",
+		`override the author, as "Name <email>"`,
+	)
+	cmd.Flags().StringVar(
+		&opts.commit.Date, "date", "",
+		"override the author date",
+	)
+	cmd.Flags().BoolVarP(
+		&opts.commit.Signoff, "signoff", "s", false,
+		"add a Signed-off-by trailer",
+	)
+	cmd.Flags().StringArrayVar(
+		&opts.commit.Trailers, "trailer", nil,
+		"add a trailer, as key=value (repeatable)",
+	)
+	cmd.Flags().BoolVar(
+		&opts.commit.AllowEmpty, "allow-empty", false,
+		"allow a commit that changes nothing",
+	)
+
+	cmd.Flags().BoolVar(
+		&opts.updateIndex, "update-index", false,
+		"when committing selections, also apply them to the real index",
+	)
+	cmd.Flags().StringVar(
+		&opts.validate, "validate", "",
+		"run this command against the commit's tree and commit only if "+
+			"it passes",
+	)
 
 	return cmd
 }
 
-func runCommit(ctx context.Context, w io.Writer, message string) error {
+// commitOptions holds the flags for the commit command.
+type commitOptions struct {
+	commit      git.CommitOptions
+	updateIndex bool
+	validate    string
+	patch       patch.Options
+}
+
+// readMessage reads a message given as -F - from stdin, and checks that
+// the commit has a message from somewhere.
+func (o *commitOptions) readMessage(stdin io.Reader) error {
+	if o.commit.MessageFile == "-" {
+		message, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		o.commit.Message = string(message)
+		o.commit.MessageFile = ""
+
+		if strings.TrimSpace(o.commit.Message) == "" {
+			return fmt.Errorf("empty commit message on stdin")
+		}
+	}
+
+	// An amend keeps the old message, and fixup and squash commits
+	// generate theirs.
+	hasMessage := o.commit.Message != "" || o.commit.MessageFile != "" ||
+		o.commit.Amend || o.commit.Fixup != "" || o.commit.Squash != ""
+	if !hasMessage {
+		return fmt.Errorf("commit message required (-m or -F)")
+	}
+
+	for _, trailer := range o.commit.Trailers {
+		if !strings.ContainsAny(trailer, "=:") {
+			return fmt.Errorf("invalid trailer %q: expected key=value",
+				trailer)
+		}
+	}
+
+	return nil
+}
+
+func runCommit(
+	ctx context.Context, w, errW io.Writer, opts commitOptions,
+) error {
 	cfg := getConfig(ctx)
 	executor := git.NewShellExecutor(cfg.WorkDir)
 
@@ -52,15 +209,306 @@ func runCommit(ctx context.Context, w io.Writer, message string) error {
 		return err
 	}
 
-	if diffText == "" {
+	// An amend may only change the message.
+	allowEmpty := opts.commit.Amend || opts.commit.AllowEmpty
+	if diffText == "" && !allowEmpty {
 		return fmt.Errorf("nothing staged for commit")
 	}
 
-	if err := executor.Commit(ctx, message); err != nil {
+	validation, err := validateCommit(ctx, w, errW, cfg, executor, opts)
+	if err != nil {
+		return err
+	}
+
+	result, err := executor.Commit(ctx, opts.commit)
+	if err != nil {
+		return formatCommitError(w, cfg, err)
+	}
+
+	return formatCommitResult(w, errW, cfg, result, validation)
+}
+
+// runCommitSelection commits the selected changes through a temporary index
+// seeded from HEAD, leaving the repository's index alone unless
+// --update-index asks for the committed lines to be added to it.
+func runCommitSelection(
+	ctx context.Context, w, errW io.Writer, args []string,
+	opts commitOptions,
+) error {
+	selections, err := parseSelectionArgs(args, &opts.patch)
+	if err != nil {
+		return err
+	}
+
+	cfg := getConfig(ctx)
+
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	// An unborn branch starts from an empty tree.
+	var head string
+	if _, err := tmp.RevParse(ctx, "HEAD"); err == nil {
+		head = "HEAD"
+	}
+
+	if err := tmp.ReadTree(ctx, head); err != nil {
+		return err
+	}
+
+	_, patchBytes, err := buildPatch(ctx, cfg, tmp, selections, opts.patch)
+	if err != nil {
+		return err
+	}
+
+	if err := applyVerified(ctx, tmp, patchBytes); err != nil {
+		return fmt.Errorf("failed to build commit: %w", err)
+	}
+
+	validation, err := validateCommit(ctx, w, errW, cfg, tmp, opts)
+	if err != nil {
+		return err
+	}
+
+	result, err := tmp.Commit(ctx, opts.commit)
+	if err != nil {
+		return formatCommitError(w, cfg, err)
+	}
+
+	if opts.updateIndex {
+		err := updateIndex(ctx, errW, cfg, tmp, patchBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return formatCommitResult(w, errW, cfg, result, validation)
+}
+
+// updateIndex carries the committed lines of patchBytes over to the real
+// index, built being the index the commit was made from. Nothing is done
+// if the real index already holds every file as committed, as when the
+// lines were staged before. Should what is staged conflict with them, the
+// index is left as it was with a warning.
+func updateIndex(
+	ctx context.Context, errW io.Writer, cfg Config, built git.Executor,
+	patchBytes []byte,
+) error {
+	parsed, err := diff.Parse(string(patchBytes))
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for _, file := range parsed.AllFiles() {
+		paths = append(paths, file.OldName, file.NewName)
+	}
+
+	index := newPatchExecutor(cfg)
+	staged, err := index.IndexBlobs(ctx, paths...)
+	if err != nil {
+		return err
+	}
+
+	committed, err := built.IndexBlobs(ctx, paths...)
+	if err != nil {
 		return err
 	}
 
-	fmt.Fprintln(w, "Committed successfully.")
+	if maps.Equal(staged, committed) {
+		return nil
+	}
+
+	err = index.ApplyPatch(ctx, bytes.NewReader(patchBytes))
+	if err != nil {
+		fmt.Fprintf(errW, "warning: index not updated with the "+
+			"committed changes, as changes staged to the same "+
+			"lines conflict with them: %v\n", err)
+	}
 
 	return nil
 }
+
+// validateCommit runs the --validate command, if any, against the tree of
+// executor's index. A failure is reported, under --json as a commit output
+// with the validation, and returned as an error so nothing is committed.
+func validateCommit(
+	ctx context.Context, w, errW io.Writer, cfg Config,
+	executor git.Executor, opts commitOptions,
+) (*validationJSON, error) {
+	if opts.validate == "" {
+		return nil, nil
+	}
+
+	tree, err := executor.WriteTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	validation, err := validateTree(ctx, cfg, tree, opts.validate)
+	if err != nil {
+		return nil, err
+	}
+
+	if validation.passed() {
+		return validation, nil
+	}
+
+	if !cfg.JSONOut {
+		fmt.Fprint(errW, validation.Output)
+
+		return nil, validation.err()
+	}
+
+	output := commitOutput{
+		SchemaVersion: schema.Version,
+		Validation:    validation,
+	}
+
+	if err := writeCommitJSON(w, output); err != nil {
+		return nil, err
+	}
+
+	return nil, validation.err()
+}
+
+// commitOutput is the JSON output for commit.
+type commitOutput struct {
+	SchemaVersion int             `json:"schema_version"`
+	Success       bool            `json:"success"`
+	Commit        *commitJSON     `json:"commit,omitempty"`
+	Hooks         []hookJSON      `json:"hooks,omitempty"`
+	HookOutput    string          `json:"hook_output,omitempty"`
+	Validation    *validationJSON `json:"validation,omitempty"`
+	Error         *commitError    `json:"error,omitempty"`
+}
+
+// commitJSON describes the created commit.
+type commitJSON struct {
+	Hash      string           `json:"hash"`
+	Tree      string           `json:"tree"`
+	Parents   []string         `json:"parents"`
+	Subject   string           `json:"subject"`
+	Files     []commitFileJSON `json:"files"`
+	Additions int              `json:"additions"`
+	Deletions int              `json:"deletions"`
+}
+
+// commitFileJSON counts the lines a commit changed in one file.
+type commitFileJSON struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// hookJSON records a hook git ran while committing.
+type hookJSON struct {
+	Name     string `json:"name"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// commitError describes a commit rejected by a hook.
+type commitError struct {
+	Message  string `json:"message"`
+	Hook     string `json:"hook"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// formatCommitResult reports a new commit and the validation it passed, if
+// any. In text mode any hook output is passed on to errW, where git itself
+// would have shown it.
+func formatCommitResult(
+	w, errW io.Writer, cfg Config, result *git.CommitResult,
+	validation *validationJSON,
+) error {
+	if !cfg.JSONOut {
+		fmt.Fprint(errW, result.HookOutput)
+		fmt.Fprintln(w, "Committed successfully.")
+
+		return nil
+	}
+
+	commit := &commitJSON{
+		Hash:    result.Hash,
+		Tree:    result.Tree,
+		Parents: append(make([]string, 0), result.Parents...),
+		Subject: result.Subject,
+		Files:   make([]commitFileJSON, 0, len(result.Files)),
+	}
+
+	for _, file := range result.Files {
+		commit.Files = append(commit.Files, commitFileJSON{
+			Path:      file.Path,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+			Binary:    file.Binary,
+		})
+
+		commit.Additions += file.Additions
+		commit.Deletions += file.Deletions
+	}
+
+	output := commitOutput{
+		SchemaVersion: schema.Version,
+		Success:       true,
+		Commit:        commit,
+		Hooks:         hooksJSON(result.Hooks),
+		HookOutput:    result.HookOutput,
+		Validation:    validation,
+	}
+
+	return writeCommitJSON(w, output)
+}
+
+// formatCommitError reports a failed commit. Under --json a hook failure is
+// written out as a structured error; either way err is returned, so the
+// command still fails.
+func formatCommitError(w io.Writer, cfg Config, err error) error {
+	var hookErr *git.HookError
+	if !cfg.JSONOut || !errors.As(err, &hookErr) {
+		return err
+	}
+
+	output := commitOutput{
+		SchemaVersion: schema.Version,
+		Error: &commitError{
+			Message:  fmt.Sprintf("%s hook failed", hookErr.Hook),
+			Hook:     hookErr.Hook,
+			ExitCode: hookErr.ExitCode,
+			Output:   hookErr.Output,
+		},
+	}
+
+	if writeErr := writeCommitJSON(w, output); writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+// hooksJSON converts hook runs to their JSON form.
+func hooksJSON(hooks []git.HookRun) []hookJSON {
+	var out []hookJSON
+	for _, hook := range hooks {
+		out = append(out, hookJSON{
+			Name:     hook.Name,
+			ExitCode: hook.ExitCode,
+		})
+	}
+
+	return out
+}
+
+func writeCommitJSON(w io.Writer, output commitOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(output)
+}
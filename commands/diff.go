@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/roasbeef/hunk/diff"
 	"github.com/roasbeef/hunk/git"
@@ -19,6 +22,10 @@ func NewDiffCmd() *cobra.Command {
 		showFiles   bool
 		showSummary bool
 		showStage   bool
+		jsonStream  bool
+		whitespace  git.WhitespaceOptions
+		text        textFlags
+		budget      budgetFlags
 	)
 
 	cmd := &cobra.Command{
@@ -29,7 +36,17 @@ func NewDiffCmd() *cobra.Command {
 Each line is prefixed with its line number in the new file,
 making it easy to specify line ranges for staging.
 
-Use --json for machine-readable output suitable for AI agents.`,
+Use --json for machine-readable output suitable for AI agents.
+
+--ignore-whitespace and --ignore-blank-lines hide formatting churn. Line
+numbers still refer to the real files, so they can be passed to 'hunk
+stage' as usual.
+
+--max-lines and --max-files cap how much of the diff is shown in full, for
+text and --json output. Files past the budget are summarized by hunk count,
++/- lines and line ranges, and --offset pages on from there. A file too
+long for one page is cut between hunks, and --hunk-offset pages through
+the rest of it; the footer gives both offsets for the next page.`,
 		Example: `  # Show all unstaged changes
   hunk diff
 
@@ -42,8 +59,28 @@ Use --json for machine-readable output suitable for AI agents.`,
   # JSON output for AI agents
   hunk diff --json
 
-  # Show suggested stage commands
-  hunk diff --stage-hints`,
+  # Stream one JSON record per file and hunk for very large diffs
+  hunk diff --json-stream
+
+  # Suggest a stage command for each change block
+  hunk diff --stage-hints
+  hunk --json diff --stage-hints
+
+  # Force colored output without context lines
+  hunk diff --color=always --no-context
+
+  # Old and new content in two columns
+  hunk diff --format=side-by-side
+
+  # Terse blocks, each headed by the selector that stages it
+  hunk diff --format=compact
+
+  # Hide changes that only touch whitespace
+  hunk diff --ignore-whitespace --ignore-blank-lines
+
+  # Show at most 200 lines, then the next page
+  hunk diff --max-lines 200
+  hunk diff --max-lines 200 --offset 3`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDiff(cmd.Context(), cmd.OutOrStdout(), args, diffOptions{
 				staged:      staged,
@@ -51,6 +88,10 @@ Use --json for machine-readable output suitable for AI agents.`,
 				showFiles:   showFiles,
 				showSummary: showSummary,
 				showStage:   showStage,
+				jsonStream:  jsonStream,
+				whitespace:  whitespace,
+				text:        text,
+				budget:      budget,
 			})
 		},
 	}
@@ -73,8 +114,22 @@ Use --json for machine-readable output suitable for AI agents.`,
 	)
 	cmd.Flags().BoolVar(
 		&showStage, "stage-hints", false,
-		"show suggested hunk stage commands",
+		"suggest a hunk stage command for each change block",
+	)
+	cmd.Flags().BoolVar(
+		&jsonStream, "json-stream", false,
+		"stream newline-delimited JSON records as the diff is read",
 	)
+	cmd.Flags().BoolVar(
+		&whitespace.IgnoreAll, "ignore-whitespace", false,
+		"ignore whitespace when comparing lines",
+	)
+	cmd.Flags().BoolVar(
+		&whitespace.IgnoreBlankLines, "ignore-blank-lines", false,
+		"ignore changes whose lines are all blank",
+	)
+	text.register(cmd)
+	budget.register(cmd)
 
 	return cmd
 }
@@ -85,14 +140,45 @@ type diffOptions struct {
 	showFiles   bool
 	showSummary bool
 	showStage   bool
+	jsonStream  bool
+	whitespace  git.WhitespaceOptions
+	text        textFlags
+	budget      budgetFlags
 }
 
 func runDiff(ctx context.Context, w io.Writer, paths []string, opts diffOptions) error {
 	cfg := getConfig(ctx)
-	executor := git.NewShellExecutor(cfg.WorkDir)
+
+	textOpts, err := opts.text.options(w)
+	if err != nil {
+		return err
+	}
+
+	budget, err := opts.budget.budget()
+	if err != nil {
+		return err
+	}
+
+	if opts.jsonStream {
+		if err := opts.budget.checkStream(); err != nil {
+			return err
+		}
+	}
+
+	executor := newDiffExecutor(cfg)
+	executor.Whitespace = opts.whitespace
+
+	prefix, err := workDirPrefix(ctx, executor)
+	if err != nil {
+		return err
+	}
+	textOpts.RelativeTo = opts.text.relativeTo(prefix)
+
+	if opts.jsonStream {
+		return streamDiff(ctx, w, executor, opts.staged, paths)
+	}
 
 	var diffText string
-	var err error
 
 	if opts.staged {
 		diffText, err = executor.DiffCached(ctx, paths...)
@@ -131,8 +217,26 @@ func runDiff(ctx context.Context, w io.Writer, paths []string, opts diffOptions)
 		return err
 	}
 
+	err = lookupBinarySizes(ctx, executor, !opts.staged, parsed.AllFiles()...)
+	if err != nil {
+		return err
+	}
+
+	// JSON always carries context lines.
+	budget.HideContext = textOpts.HideContext && !cfg.JSONOut
+	shown, truncation := budget.Apply(parsed)
+	textOpts.Truncated = truncation != nil
+
+	if cfg.JSONOut && opts.showStage {
+		return output.FormatStageHintsJSON(w, parsed, prefix)
+	}
+
 	if cfg.JSONOut {
-		return output.FormatJSONWithUntracked(w, parsed, untracked)
+		return output.FormatJSONWithOptions(w, shown, output.JSONOptions{
+			Untracked:  untracked,
+			RelativeTo: prefix,
+			Truncation: truncation,
+		})
 	}
 
 	// Handle different output modes.
@@ -145,9 +249,12 @@ func runDiff(ctx context.Context, w io.Writer, paths []string, opts diffOptions)
 	case opts.showSummary:
 		formatErr = output.FormatTextSummary(w, parsed)
 	case opts.showStage:
-		formatErr = output.FormatStagingCommands(w, parsed)
+		formatErr = output.FormatStagingCommands(w, parsed, prefix)
 	default:
-		formatErr = output.FormatText(w, parsed, output.DefaultTextOptions())
+		formatErr = opts.text.render(w, shown, textOpts)
+		if formatErr == nil {
+			output.FormatTruncation(w, truncation, textOpts)
+		}
 	}
 
 	if formatErr != nil {
@@ -162,3 +269,124 @@ func runDiff(ctx context.Context, w io.Writer, paths []string, opts diffOptions)
 
 	return nil
 }
+
+// streamDiff writes the diff as NDJSON records while git is still producing
+// it: a file record and its hunk records for each file, then a summary.
+func streamDiff(
+	ctx context.Context, w io.Writer, executor git.Executor,
+	staged bool, paths []string,
+) error {
+	prefix, err := workDirPrefix(ctx, executor)
+	if err != nil {
+		return err
+	}
+
+	stream, err := executor.DiffStream(ctx, staged, paths...)
+	if err != nil {
+		return err
+	}
+
+	out := output.NewJSONStream(w)
+	out.RelativeTo = prefix
+
+	for file, parseErr := range diff.ParseStream(stream) {
+		if parseErr != nil {
+			stream.Close()
+
+			return parseErr
+		}
+
+		err := lookupBinarySizes(ctx, executor, !staged, file)
+		if err != nil {
+			stream.Close()
+
+			return err
+		}
+
+		if err := out.WriteFile(file); err != nil {
+			stream.Close()
+
+			return err
+		}
+	}
+
+	if err := stream.Close(); err != nil {
+		return err
+	}
+
+	var untracked []string
+	if !staged {
+		status, statusErr := executor.Status(ctx)
+		if statusErr == nil {
+			untracked = status.UntrackedFiles
+		}
+	}
+
+	return out.Close(untracked)
+}
+
+// lookupBinarySizes records the sizes of the binary files among files, for
+// a diff taken without their contents. Sizes come from the object database,
+// or with workTree set, from the working tree for new content git hasn't
+// stored. A file whose sizes can't be found is left without them.
+func lookupBinarySizes(
+	ctx context.Context, executor git.Executor, workTree bool,
+	files ...*diff.FileDiff,
+) error {
+	var ids []string
+	for _, file := range files {
+		if file.IsBinary {
+			ids = append(ids, file.OldBlob, file.NewBlob)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sizes, err := executor.ObjectSizes(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	var root string
+	if workTree {
+		root, err = executor.Root(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// An all-zero id stands for a missing side, as for a new file.
+	size := func(id string) (int64, bool) {
+		if id != "" && strings.Trim(id, "0") == "" {
+			return 0, true
+		}
+
+		s, ok := sizes[id]
+
+		return s, ok
+	}
+
+	for _, file := range files {
+		if !file.IsBinary {
+			continue
+		}
+
+		oldSize, oldOK := size(file.OldBlob)
+		newSize, newOK := size(file.NewBlob)
+
+		if !newOK && root != "" {
+			info, err := os.Stat(filepath.Join(root, file.NewName))
+			if err == nil {
+				newSize, newOK = info.Size(), true
+			}
+		}
+
+		if oldOK && newOK {
+			file.SetBinarySizes(oldSize, newSize)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/patch"
+	"github.com/spf13/cobra"
+)
+
+// NewHistoryCmd creates the history parent command.
+func NewHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Move or drop lines in earlier commits",
+		Long: `Rewrite commits on the current branch line by line.
+
+Line numbers and change blocks refer to a commit's own diff ('hunk show
+<commit>'), and a bare path takes the whole file. The commits are
+rebuilt with the selected lines moved or removed, and the rest of the
+branch is replayed on top of them with a non-interactive rebase. The
+working tree must be clean.`,
+	}
+
+	cmd.AddCommand(NewHistoryMoveLinesCmd())
+	cmd.AddCommand(NewHistoryDropLinesCmd())
+
+	return cmd
+}
+
+// historyCommit is a commit on the current branch that is being rewritten.
+type historyCommit struct {
+	git.CommitInfo
+
+	// Parent is the commit's parent, which the rewrite rebases onto.
+	Parent string
+
+	// After lists the commits that follow it on the branch, oldest
+	// first.
+	After []git.CommitInfo
+}
+
+// findHistoryCommit looks rev up among the commits of the current branch.
+func findHistoryCommit(
+	ctx context.Context, executor git.Executor, rev string,
+) (*historyCommit, error) {
+	hash, err := executor.RevParse(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := executor.RevParse(ctx, hash+"^")
+	if err != nil {
+		return nil, fmt.Errorf("cannot rewrite %s: it has no parent", rev)
+	}
+
+	commits, err := executor.RebaseList(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &historyCommit{Parent: parent}
+	for _, info := range commits {
+		if info.Hash == hash {
+			c.CommitInfo = info
+		} else {
+			c.After = append(c.After, info)
+		}
+	}
+
+	if c.Hash == "" {
+		return nil, fmt.Errorf("commit %s is not on the current branch",
+			rev)
+	}
+
+	return c, nil
+}
+
+// requireCleanTree refuses to rewrite history over local changes.
+func requireCleanTree(ctx context.Context, executor git.Executor) error {
+	status, err := executor.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(status.StagedFiles) > 0 || len(status.UnstagedFiles) > 0 {
+		return fmt.Errorf("the working tree has changes; commit or " +
+			"stash them before rewriting history")
+	}
+
+	return nil
+}
+
+// selectedLines returns the patch adding the lines args select from c's
+// diff to its parent, and the patch taking them back out of c.
+func selectedLines(
+	ctx context.Context, cfg Config, executor git.Executor,
+	c *historyCommit, args []string,
+) (add, remove []byte, err error) {
+	resolver, err := newPathResolver(ctx, cfg, executor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	forward, err := executor.DiffCommits(ctx, c.Parent, c.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if forward == "" {
+		return nil, nil, fmt.Errorf("commit %s changes nothing",
+			c.ShortHash)
+	}
+
+	fwd, err := diff.Parse(forward)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts patch.Options
+	selections, err := parseSelectionArgs(args, &opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := resolveSelections(resolver, selections, &opts); err != nil {
+		return nil, nil, err
+	}
+
+	// Resolving blocks against the commit's diff leaves each selection
+	// with exact line numbers, which the reverse diff can then use.
+	add, err = selectedPatch(fwd, selections, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reverse, err := executor.DiffCommits(ctx, c.Hash, c.Parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rev, err := diff.Parse(reverse)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The diff back to the parent turns the commit's additions into
+	// deletions and its deletions into additions, each keeping its
+	// number, and lists a renamed file under its old name.
+	renamed := make(map[string]string)
+	for _, file := range fwd.AllFiles() {
+		if file.IsRenamed {
+			renamed[file.NewName] = file.OldName
+		}
+	}
+
+	revPath := func(path string) string {
+		if old, ok := renamed[path]; ok {
+			return old
+		}
+
+		return path
+	}
+
+	revSelections := make([]*diff.FileSelection, 0, len(selections))
+	for _, sel := range selections {
+		revSel := sel.Reversed()
+		revSel.Path = revPath(sel.Path)
+		revSelections = append(revSelections, revSel)
+	}
+
+	revOpts := opts
+	revOpts.Files, revOpts.ModePaths = nil, nil
+	for _, path := range opts.Files {
+		revOpts.Files = append(revOpts.Files, revPath(path))
+	}
+	for _, path := range opts.ModePaths {
+		revOpts.ModePaths = append(revOpts.ModePaths, revPath(path))
+	}
+
+	remove, err = selectedPatch(rev, revSelections, revOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return add, remove, nil
+}
+
+// historyRewriter builds replacement commits through a temporary index,
+// leaving HEAD, the index and the working tree alone.
+type historyRewriter struct {
+	tmp *git.ShellExecutor
+	dir string
+}
+
+func newHistoryRewriter(cfg Config) (*historyRewriter, error) {
+	dir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(dir, "index")
+
+	return &historyRewriter{tmp: tmp, dir: dir}, nil
+}
+
+// Close removes the temporary index.
+func (r *historyRewriter) Close() error {
+	return os.RemoveAll(r.dir)
+}
+
+// tree returns the tree of rev.
+func (r *historyRewriter) tree(
+	ctx context.Context, rev string,
+) (string, error) {
+	if err := r.tmp.ReadTree(ctx, rev); err != nil {
+		return "", err
+	}
+
+	return r.tmp.WriteTree(ctx)
+}
+
+// commit creates a copy of c with patchBytes applied to its tree, keeping
+// its parent, message, author and date, and returns its hash.
+func (r *historyRewriter) commit(
+	ctx context.Context, c *historyCommit, patchBytes []byte,
+) (string, error) {
+	parentTree, err := r.tree(ctx, c.Parent)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.tmp.ReadTree(ctx, c.Hash); err != nil {
+		return "", err
+	}
+
+	err = r.tmp.ApplyPatch(ctx, bytes.NewReader(patchBytes))
+	if err != nil {
+		return "", fmt.Errorf("the selected lines do not apply to "+
+			"%s: %w", c.ShortHash, err)
+	}
+
+	tree, err := r.tmp.WriteTree(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if tree == parentTree {
+		return "", fmt.Errorf("commit %s would be left with no "+
+			"changes", c.ShortHash)
+	}
+
+	message, err := r.tmp.CommitMessage(ctx, c.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	return r.tmp.CommitTree(ctx, git.CommitTreeOptions{
+		Tree:    tree,
+		Parents: []string{c.Parent},
+		Message: message,
+		Author:  c.Author,
+		Date:    c.Date,
+	})
+}
+
+// historyConflicts fills in the conflict report of a rewrite that stopped.
+func historyConflicts(
+	state *git.RebaseState,
+) ([]conflictInfoOutput, []string) {
+	var conflicts []conflictInfoOutput
+	for _, c := range state.Conflicts {
+		conflicts = append(conflicts, conflictInfoOutput{
+			File:         c.Path,
+			ConflictType: c.ConflictType,
+		})
+	}
+
+	return conflicts, []string{
+		"Resolve conflicts in the listed files",
+		"Stage resolved files with 'git add <file>'",
+		"Continue with 'hunk rebase continue'",
+		"Or abort with 'hunk rebase abort'",
+	}
+}
+
+// formatHistoryStopped writes the state of a rewrite that did not finish.
+func formatHistoryStopped(
+	w io.Writer, message string, conflicts []conflictInfoOutput,
+) {
+	fmt.Fprintf(w, "%s.\n", message)
+
+	if len(conflicts) == 0 {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "  hunk rebase abort  # to restore the branch")
+
+		return
+	}
+
+	fmt.Fprintln(w, "")
+
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "  Conflict: %s\n", c.File)
+	}
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Resolve conflicts, then:")
+	fmt.Fprintln(w, "  hunk rebase continue  # to continue")
+	fmt.Fprintln(w, "  hunk rebase abort     # to abort")
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// historyDropLinesOutput is the JSON output for history drop-lines.
+type historyDropLinesOutput struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Success       bool                 `json:"success"`
+	Message       string               `json:"message"`
+	Commit        string               `json:"commit"`
+	Subject       string               `json:"subject"`
+	Rewritten     string               `json:"rewritten"`
+	InProgress    bool                 `json:"in_progress,omitempty"`
+	HasConflict   bool                 `json:"has_conflict,omitempty"`
+	Conflicts     []conflictInfoOutput `json:"conflicts,omitempty"`
+	Instructions  []string             `json:"instructions,omitempty"`
+}
+
+// NewHistoryDropLinesCmd creates the history drop-lines command.
+func NewHistoryDropLinesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drop-lines <commit> FILE:LINES...",
+		Short: "Remove selected lines from an earlier commit",
+		Long: `Rewrite a commit on the current branch without some of its changes.
+
+The selected lines, numbered as in the commit's own diff ('hunk show
+<commit>'), are taken out of the commit: added lines are removed and
+deleted lines are put back. The rewritten commit keeps its message,
+author and date, and the commits after it are replayed on top of it
+with a non-interactive rebase. The dropped lines are gone from the
+branch afterwards, not left as local changes.
+
+A selection that leaves the commit with no changes is refused. If a
+later commit conflicts with the dropped lines, the rebase stops and the
+conflicting files are reported; resolve them and run 'hunk rebase
+continue', or 'hunk rebase abort' to restore the branch.`,
+		Example: `  # Remove an accidental debug line
+  hunk history drop-lines abc123 main.go:42
+
+  # Drop a whole change block and a file
+  hunk history drop-lines HEAD~2 main.go:@3.1 scratch.txt`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Later failures aren't usage errors.
+			cmd.SilenceUsage = true
+
+			return runHistoryDropLines(
+				cmd.Context(), cmd.OutOrStdout(), args[0], args[1:],
+			)
+		},
+	}
+
+	return cmd
+}
+
+func runHistoryDropLines(
+	ctx context.Context, w io.Writer, rev string, args []string,
+) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	if err := requireCleanTree(ctx, executor); err != nil {
+		return err
+	}
+
+	target, err := findHistoryCommit(ctx, executor, rev)
+	if err != nil {
+		return err
+	}
+
+	_, remove, err := selectedLines(ctx, cfg, executor, target, args)
+	if err != nil {
+		return err
+	}
+
+	rewriter, err := newHistoryRewriter(cfg)
+	if err != nil {
+		return err
+	}
+	defer rewriter.Close()
+
+	rewritten, err := rewriter.commit(ctx, target, remove)
+	if err != nil {
+		return err
+	}
+
+	// The original commit is dropped and the rewritten one, which sits
+	// on the same parent, is fast-forwarded to in its place.
+	plan := &rebase.Spec{Actions: []rebase.Action{{
+		Action:  rebase.ActionExec,
+		Command: "git cherry-pick --ff " + rewritten,
+	}}}
+	for _, c := range target.After {
+		plan.Actions = append(plan.Actions, rebase.Action{
+			Action: rebase.ActionPick,
+			Commit: c.Hash,
+		})
+	}
+
+	state, err := startSpecRebase(ctx, executor, target.Parent, plan)
+	if err != nil {
+		return err
+	}
+
+	out := historyDropLinesOutput{
+		SchemaVersion: schema.Version,
+		Success:       !state.InProgress,
+		Commit:        target.Hash,
+		Subject:       target.Subject,
+		Rewritten:     rewritten,
+		InProgress:    state.InProgress,
+		HasConflict:   state.State == git.RebaseStateConflict,
+	}
+
+	switch {
+	case out.HasConflict:
+		out.Message = "Drop paused due to conflicts"
+		out.Conflicts, out.Instructions = historyConflicts(state)
+
+	case out.InProgress:
+		out.Message = "Drop in progress"
+
+	default:
+		out.Message = fmt.Sprintf("Dropped the selected lines from %s",
+			target.ShortHash)
+	}
+
+	if cfg.JSONOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	if out.InProgress {
+		formatHistoryStopped(w, out.Message, out.Conflicts)
+
+		return nil
+	}
+
+	fmt.Fprintf(w, "Dropped the selected lines from %s %s.\n",
+		target.ShortHash, target.Subject)
+
+	return nil
+}
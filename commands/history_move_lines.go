@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// historyMoveLinesOutput is the JSON output for history move-lines.
+type historyMoveLinesOutput struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Success       bool                 `json:"success"`
+	Message       string               `json:"message"`
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	InProgress    bool                 `json:"in_progress,omitempty"`
+	HasConflict   bool                 `json:"has_conflict,omitempty"`
+	TreeChanged   bool                 `json:"tree_changed,omitempty"`
+	Conflicts     []conflictInfoOutput `json:"conflicts,omitempty"`
+	Instructions  []string             `json:"instructions,omitempty"`
+}
+
+// historyMoveLinesOptions holds the flags for the history move-lines
+// command.
+type historyMoveLinesOptions struct {
+	from string
+	to   string
+}
+
+// NewHistoryMoveLinesCmd creates the history move-lines command.
+func NewHistoryMoveLinesCmd() *cobra.Command {
+	var opts historyMoveLinesOptions
+
+	cmd := &cobra.Command{
+		Use:   "move-lines --from <commit> --to <commit> FILE:LINES...",
+		Short: "Move selected lines from one commit to another",
+		Long: `Move some of a commit's changes into another commit on the branch.
+
+The selected lines, numbered as in the --from commit's own diff ('hunk
+show <commit>'), are taken out of --from and made part of --to, which
+may come before or after it. Both commits keep their messages, authors
+and dates, and the branch is replayed with a non-interactive rebase.
+
+Of the two commits, the earlier one is rebuilt up front: moving lines
+later takes them out of --from, and moving them earlier applies them to
+--to, which fails if the lines don't fit there. The later commit is
+rebuilt during the rebase to end at its original tree. Moving lines
+never changes the branch's final tree; the rebase checks this as its
+last step and stops if it does not hold, so 'hunk rebase abort' can
+restore the branch.
+
+A selection that leaves --from with no changes is refused. If a commit
+in between conflicts with the move, the rebase stops and the conflicting
+files are reported; resolve them and run 'hunk rebase continue', or
+'hunk rebase abort' to restore the branch.`,
+		Example: `  # This hunk belongs in the next commit
+  hunk history move-lines --from HEAD~1 --to HEAD main.go:10-20
+
+  # Move a change block back to where it was introduced
+  hunk history move-lines --from abc123 --to def456 main.go:@2.1`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Later failures aren't usage errors.
+			cmd.SilenceUsage = true
+
+			return runHistoryMoveLines(
+				cmd.Context(), cmd.OutOrStdout(), args, opts,
+			)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.from, "from", "", "commit to take the lines from (required)",
+	)
+	cmd.Flags().StringVar(
+		&opts.to, "to", "", "commit to move the lines into (required)",
+	)
+
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func runHistoryMoveLines(
+	ctx context.Context, w io.Writer, args []string,
+	opts historyMoveLinesOptions,
+) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	if err := requireCleanTree(ctx, executor); err != nil {
+		return err
+	}
+
+	from, err := findHistoryCommit(ctx, executor, opts.from)
+	if err != nil {
+		return err
+	}
+
+	to, err := findHistoryCommit(ctx, executor, opts.to)
+	if err != nil {
+		return err
+	}
+
+	if from.Hash == to.Hash {
+		return fmt.Errorf("--from and --to are the same commit")
+	}
+
+	add, remove, err := selectedLines(ctx, cfg, executor, from, args)
+	if err != nil {
+		return err
+	}
+
+	rewriter, err := newHistoryRewriter(cfg)
+	if err != nil {
+		return err
+	}
+	defer rewriter.Close()
+
+	head, err := executor.RevParse(ctx, "HEAD")
+	if err != nil {
+		return err
+	}
+
+	headTree, err := rewriter.tree(ctx, head)
+	if err != nil {
+		return err
+	}
+
+	// Taking the lines out of --from is checked either way, so a move
+	// that would leave it empty is refused before anything changes.
+	withoutLines, err := rewriter.commit(ctx, from, remove)
+	if err != nil {
+		return err
+	}
+
+	earlier, later, rewritten := from, to, withoutLines
+	if !historyContains(from.After, to.Hash) {
+		earlier, later = to, from
+
+		rewritten, err = rewriter.commit(ctx, to, add)
+		if err != nil {
+			return err
+		}
+	}
+
+	plan := historyMovePlan(earlier, later, rewritten, headTree)
+
+	state, err := startSpecRebase(ctx, executor, earlier.Parent, plan)
+	if err != nil {
+		return err
+	}
+
+	out := historyMoveLinesOutput{
+		SchemaVersion: schema.Version,
+		Success:       !state.InProgress,
+		From:          from.Hash,
+		To:            to.Hash,
+		InProgress:    state.InProgress,
+		HasConflict:   state.State == git.RebaseStateConflict,
+	}
+
+	switch {
+	case out.HasConflict:
+		out.Message = "Move paused due to conflicts"
+		out.Conflicts, out.Instructions = historyConflicts(state)
+
+	// Only the tree check is left to fail once nothing remains.
+	case out.InProgress && state.RemainingCount == 0:
+		out.TreeChanged = true
+		out.Message = "Move stopped: the final tree differs from " +
+			"the original"
+		out.Instructions = []string{
+			"Restore the branch with 'hunk rebase abort'",
+		}
+
+	case out.InProgress:
+		out.Message = "Move in progress"
+
+	default:
+		out.Message = fmt.Sprintf("Moved the selected lines from %s "+
+			"to %s", from.ShortHash, to.ShortHash)
+	}
+
+	if cfg.JSONOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	if out.InProgress {
+		formatHistoryStopped(w, out.Message, out.Conflicts)
+
+		return nil
+	}
+
+	fmt.Fprintf(w, "Moved the selected lines from %s %s to %s %s.\n",
+		from.ShortHash, from.Subject, to.ShortHash, to.Subject)
+
+	return nil
+}
+
+// historyMovePlan returns the rebase onto earlier's parent that puts
+// rewritten, the rebuilt earlier commit, in its place. The later commit is
+// recommitted with its original tree, message and author, so the moved
+// lines land on whichever side they now belong to, and the final tree is
+// checked against headTree.
+func historyMovePlan(
+	earlier, later *historyCommit, rewritten, headTree string,
+) *rebase.Spec {
+	plan := &rebase.Spec{Actions: []rebase.Action{{
+		Action:  rebase.ActionExec,
+		Command: "git cherry-pick --ff " + rewritten,
+	}}}
+
+	for _, c := range earlier.After {
+		if c.Hash != later.Hash {
+			plan.Actions = append(plan.Actions, rebase.Action{
+				Action: rebase.ActionPick,
+				Commit: c.Hash,
+			})
+
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, rebase.Action{
+			Action: rebase.ActionExec,
+			Command: fmt.Sprintf("git read-tree -u -m HEAD %s && "+
+				"git commit -q --no-verify -C %s", c.Hash, c.Hash),
+		})
+	}
+
+	plan.Actions = append(plan.Actions, rebase.Action{
+		Action: rebase.ActionExec,
+		Command: fmt.Sprintf(`test "$(git rev-parse HEAD^{tree})" = %s`,
+			headTree),
+	})
+
+	return plan
+}
+
+// historyContains reports whether hash is one of commits.
+func historyContains(commits []git.CommitInfo, hash string) bool {
+	for _, c := range commits {
+		if c.Hash == hash {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// historyCommits is a branch off main with "Add beta", "Add alpha", which
+// creates alpha.txt with eight lines, "Edit beta", and "Edit alpha", which
+// changes lines 2 and 4 of alpha.txt.
+var historyCommits = []testutil.FileCommit{
+	{
+		Message: "Add beta",
+		Files:   map[string]string{"beta.txt": "b1\nb2\nb3\n"},
+	},
+	{
+		Message: "Add alpha",
+		Files: map[string]string{
+			"alpha.txt": "a1\na2\na3\na4\na5\na6\na7\na8\n",
+		},
+	},
+	{
+		Message: "Edit beta",
+		Files:   map[string]string{"beta.txt": "b1\nB2\nb3\n"},
+	},
+	{
+		Message: "Edit alpha",
+		Files: map[string]string{
+			"alpha.txt": "a1\nA2\na3\nA4\na5\na6\na7\na8\n",
+		},
+	},
+}
+
+// historySubjects are the subjects of historyCommits as listed by git log.
+const historySubjects = "Edit alpha\nEdit beta\nAdd alpha\nAdd beta\n"
+
+func TestHistoryDropLines(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, historyCommits...)
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "history", "drop-lines", "HEAD~2",
+		"alpha.txt:7",
+	)
+	require.NoError(t, err, out)
+	require.NoError(t, schema.Validate("history drop-lines", []byte(out)),
+		out)
+
+	var result historyDropLinesOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.True(t, result.Success, out)
+	require.Equal(t, "Add alpha", result.Subject)
+
+	require.Equal(t, historySubjects,
+		repo.Git("log", "--format=%s", "main..HEAD"))
+	require.Equal(t, "a1\na2\na3\na4\na5\na6\na8\n",
+		repo.Git("show", "HEAD~2:alpha.txt"))
+	require.Equal(t, "a1\nA2\na3\nA4\na5\na6\na8\n",
+		repo.Git("show", "HEAD:alpha.txt"))
+
+	// The dropped line is gone, not left as a local change.
+	require.Empty(t, repo.Git("status", "--porcelain"))
+}
+
+func TestHistoryDropLinesConflict(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, historyCommits...)
+	head := repo.GetFullHash()
+
+	// Line 2 of "Add alpha" is changed again by "Edit alpha".
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "history", "drop-lines", "HEAD~2",
+		"alpha.txt:2",
+	)
+	require.NoError(t, err, out)
+	require.NoError(t, schema.Validate("history drop-lines", []byte(out)),
+		out)
+
+	var result historyDropLinesOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.False(t, result.Success)
+	require.True(t, result.HasConflict)
+	require.Equal(t, "alpha.txt", result.Conflicts[0].File)
+	require.NotEmpty(t, result.Instructions)
+
+	abortOut, err := runHunkCommand(t, repo.Dir, "rebase", "abort")
+	require.NoError(t, err, abortOut)
+	require.Equal(t, head, repo.GetFullHash())
+}
+
+func TestHistoryMoveLines(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		sel  string
+
+		// wantAlpha is alpha.txt in "Add alpha", "Edit beta" and
+		// "Edit alpha".
+		wantAlpha []string
+	}{{
+		name: "earlier",
+		from: "HEAD",
+		to:   "HEAD~2",
+		sel:  "alpha.txt:4",
+		wantAlpha: []string{
+			"a1\na2\na3\nA4\na5\na6\na7\na8\n",
+			"a1\na2\na3\nA4\na5\na6\na7\na8\n",
+			"a1\nA2\na3\nA4\na5\na6\na7\na8\n",
+		},
+	}, {
+		name: "later",
+		from: "HEAD~2",
+		to:   "HEAD",
+		sel:  "alpha.txt:7-8",
+		wantAlpha: []string{
+			"a1\na2\na3\na4\na5\na6\n",
+			"a1\na2\na3\na4\na5\na6\n",
+			"a1\nA2\na3\nA4\na5\na6\na7\na8\n",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := testutil.NewFeatureRepo(t, historyCommits...)
+			tree := repo.Git("rev-parse", "HEAD^{tree}")
+
+			out, err := runHunkCommand(
+				t, repo.Dir, "--json", "history", "move-lines",
+				"--from", tc.from, "--to", tc.to, tc.sel,
+			)
+			require.NoError(t, err, out)
+			require.NoError(t, schema.Validate(
+				"history move-lines", []byte(out),
+			), out)
+
+			var result historyMoveLinesOutput
+			require.NoError(t, json.Unmarshal([]byte(out), &result),
+				out)
+			require.True(t, result.Success, out)
+
+			require.Equal(t, historySubjects,
+				repo.Git("log", "--format=%s", "main..HEAD"))
+
+			for i, rev := range []string{"HEAD~2", "HEAD~1", "HEAD"} {
+				require.Equal(t, tc.wantAlpha[i],
+					repo.Git("show", rev+":alpha.txt"), rev)
+			}
+
+			// Moving lines never changes the final tree.
+			require.Equal(t, tree, repo.Git("rev-parse", "HEAD^{tree}"))
+			require.Empty(t, repo.Git("status", "--porcelain"))
+		})
+	}
+}
+
+func TestHistoryRefuses(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, historyCommits...)
+	head := repo.GetFullHash()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{{
+		name: "same commit",
+		args: []string{
+			"move-lines", "--from", "HEAD", "--to", "HEAD",
+			"alpha.txt:2",
+		},
+		want: "--from and --to are the same commit",
+	}, {
+		name: "whole commit",
+		args: []string{"drop-lines", "HEAD~1", "beta.txt"},
+		want: "would be left with no changes",
+	}, {
+		name: "root commit",
+		args: []string{"drop-lines", "main", "base.txt:1"},
+		want: "it has no parent",
+	}, {
+		name: "no matching lines",
+		args: []string{"drop-lines", "HEAD", "alpha.txt:3"},
+		want: "no matching lines",
+	}, {
+		name: "lines don't fit",
+		args: []string{
+			"move-lines", "--from", "HEAD", "--to", "HEAD~3",
+			"alpha.txt:2",
+		},
+		want: "the selected lines do not apply to",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := runHunkCommand(
+				t, repo.Dir, append([]string{"history"}, tc.args...)...,
+			)
+			require.Error(t, err)
+			require.Contains(t, out, tc.want)
+			require.Equal(t, head, repo.GetFullHash())
+		})
+	}
+
+	repo.WriteFile("beta.txt", "changed\n")
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "history", "drop-lines", "HEAD", "alpha.txt:2",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "the working tree has changes")
+}
+
+// sidesCommits is a branch off main with "Add f", which creates f.txt as
+// l1 to l4, "Edit f", which adds X and Y after l1 and deletes l3, and
+// "Later", which adds other.txt. In the diff of "Edit f", Y is added as
+// line 3 and l3 is deleted from line 3.
+var sidesCommits = []testutil.FileCommit{
+	{
+		Message: "Add f",
+		Files:   map[string]string{"f.txt": "l1\nl2\nl3\nl4\n"},
+	},
+	{
+		Message: "Edit f",
+		Files:   map[string]string{"f.txt": "l1\nX\nY\nl2\nl4\n"},
+	},
+	{
+		Message: "Later",
+		Files:   map[string]string{"other.txt": "o1\n"},
+	},
+}
+
+// TestHistorySideSelectors verifies that one-sided and block selections
+// pick the same lines from a commit's diff when they are taken back out of
+// it as when they are added elsewhere.
+func TestHistorySideSelectors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+
+		// wantF is f.txt in "Add f", "Edit f" and "Later".
+		wantF []string
+	}{{
+		name: "drop a deletion",
+		args: []string{"drop-lines", "HEAD~1", "f.txt:-3"},
+		wantF: []string{
+			"l1\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl3\nl4\n",
+		},
+	}, {
+		name: "drop an addition",
+		args: []string{"drop-lines", "HEAD~1", "f.txt:+3"},
+		wantF: []string{
+			"l1\nl2\nl3\nl4\n",
+			"l1\nX\nl2\nl4\n",
+			"l1\nX\nl2\nl4\n",
+		},
+	}, {
+		name: "drop a mix",
+		args: []string{"drop-lines", "HEAD~1", "f.txt:+2,-3"},
+		wantF: []string{
+			"l1\nl2\nl3\nl4\n",
+			"l1\nY\nl2\nl3\nl4\n",
+			"l1\nY\nl2\nl3\nl4\n",
+		},
+	}, {
+		name: "drop a deleting block",
+		args: []string{"drop-lines", "HEAD~1", "f.txt:@1.2"},
+		wantF: []string{
+			"l1\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl3\nl4\n",
+		},
+	}, {
+		name: "move a deletion later",
+		args: []string{
+			"move-lines", "--from", "HEAD~1", "--to", "HEAD",
+			"f.txt:-3",
+		},
+		wantF: []string{
+			"l1\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl4\n",
+		},
+	}, {
+		name: "move an adding block earlier",
+		args: []string{
+			"move-lines", "--from", "HEAD~1", "--to", "HEAD~2",
+			"f.txt:@1.1",
+		},
+		wantF: []string{
+			"l1\nX\nY\nl2\nl3\nl4\n",
+			"l1\nX\nY\nl2\nl4\n",
+			"l1\nX\nY\nl2\nl4\n",
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := testutil.NewFeatureRepo(t, sidesCommits...)
+
+			out, err := runHunkCommand(
+				t, repo.Dir, append([]string{"history"}, tc.args...)...,
+			)
+			require.NoError(t, err, out)
+
+			require.Equal(t, "Later\nEdit f\nAdd f\n",
+				repo.Git("log", "--format=%s", "main..HEAD"))
+
+			for i, rev := range []string{"HEAD~2", "HEAD~1", "HEAD"} {
+				require.Equal(t, tc.wantF[i],
+					repo.Git("show", rev+":f.txt"), rev)
+			}
+
+			require.Empty(t, repo.Git("status", "--porcelain"))
+		})
+	}
+}
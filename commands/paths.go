@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/roasbeef/hunk/git"
+)
+
+// pathResolver maps paths given on the command line, which like git's
+// pathspecs are relative to the working directory, to the repo-root-relative
+// names used in diffs.
+type pathResolver struct {
+	// root is the absolute repository root.
+	root string
+
+	// cwd is the absolute directory hunk runs in: --dir if given, else
+	// the process working directory.
+	cwd string
+}
+
+// newPathResolver creates a resolver for the repository containing the
+// configured working directory.
+func newPathResolver(
+	ctx context.Context, cfg Config, executor git.Executor,
+) (*pathResolver, error) {
+	root, err := executor.Root(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cwd := cfg.WorkDir
+	if cwd == "" {
+		cwd, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cwd, err = filepath.Abs(cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare real paths, since git reports the root with symlinks
+	// resolved.
+	return &pathResolver{
+		root: evalSymlinks(root),
+		cwd:  evalSymlinks(cwd),
+	}, nil
+}
+
+// toRepo returns a working directory relative or absolute path relative to
+// the repository root, with forward slashes.
+func (r *pathResolver) toRepo(path string) (string, error) {
+	var abs string
+	if filepath.IsAbs(path) {
+		// The file may be deleted, so resolve its directory.
+		abs = filepath.Join(
+			evalSymlinks(filepath.Dir(path)), filepath.Base(path),
+		)
+	} else {
+		abs = filepath.Join(r.cwd, path)
+	}
+
+	rel, err := filepath.Rel(r.root, abs)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// prefix returns the working directory relative to the repository root,
+// or "" at the root.
+func (r *pathResolver) prefix() string {
+	rel, err := filepath.Rel(r.root, r.cwd)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// workDirPrefix returns the configured working directory relative to the
+// repository root, or "" at the root.
+func workDirPrefix(ctx context.Context, executor git.Executor) (string, error) {
+	resolver, err := newPathResolver(ctx, getConfig(ctx), executor)
+	if err != nil {
+		return "", err
+	}
+
+	return resolver.prefix(), nil
+}
+
+// evalSymlinks resolves symlinks in path, returning it unchanged if that
+// fails.
+func evalSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	return path
+}
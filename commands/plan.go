@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewPlanCmd creates the plan parent command.
+func NewPlanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Create a planned series of commits",
+		Long: `Create a whole series of commits from one plan document.
+
+A plan lists the commits to make, oldest first, each with its line
+selections, message and options. 'hunk plan apply' checks every
+selection before committing anything, and undoes the commits it made if
+a later one fails.`,
+	}
+
+	cmd.AddCommand(NewPlanApplyCmd())
+
+	return cmd
+}
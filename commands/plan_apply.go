@@ -0,0 +1,373 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/plan"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// planApplyOutput is the JSON output for plan apply.
+type planApplyOutput struct {
+	SchemaVersion int              `json:"schema_version"`
+	Success       bool             `json:"success"`
+	Message       string           `json:"message"`
+	DryRun        bool             `json:"dry_run,omitempty"`
+	Original      string           `json:"original"`
+	Commits       []planCommitJSON `json:"commits"`
+	Failed        *planFailure     `json:"failed,omitempty"`
+	RolledBack    bool             `json:"rolled_back,omitempty"`
+}
+
+// planCommitJSON is a commit of the plan. Hash is empty on a dry run.
+type planCommitJSON struct {
+	Hash    string `json:"hash,omitempty"`
+	Subject string `json:"subject"`
+}
+
+// planFailure describes the plan commit that failed. Hook, ExitCode and
+// Output are set when a hook rejected it.
+type planFailure struct {
+	Commit   int    `json:"commit"`
+	Message  string `json:"message"`
+	Hook     string `json:"hook,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// planApplyOptions holds the flags for the plan apply command.
+type planApplyOptions struct {
+	dryRun      bool
+	updateIndex bool
+}
+
+// NewPlanApplyCmd creates the plan apply command.
+func NewPlanApplyCmd() *cobra.Command {
+	var opts planApplyOptions
+
+	cmd := &cobra.Command{
+		Use:   "apply <plan.json|->",
+		Short: "Create the commits of a plan as one transaction",
+		Long: `Create each commit of a plan in turn, or none of them.
+
+The plan is JSON:
+
+  {"commits": [
+    {"selections": ["main.go:10-20", "util.go:@1.1"], "message": "..."},
+    {"selections": ["README.md", "main.go:runServer"], "message": "...",
+     "signoff": true}
+  ]}
+
+Selections use the FILE:LINES syntax of 'hunk stage', with line numbers
+and change blocks referring to the changes since HEAD ('git diff HEAD')
+when the plan starts. A bare path selects the whole file. Each commit
+holds its own selections on top of the commits before it. A commit may
+also set "author", "date", "signoff" and "trailers", as 'hunk commit'
+does.
+
+A Go symbol can be named instead of lines: "main.go:runServer" selects
+the changes within the declaration of runServer, with its doc comment,
+both as of HEAD and in the working tree. Methods are named Type.Method.
+
+Every selection is checked before anything is committed. The commits are
+then built through a temporary index, as 'hunk commit FILE:LINES' does.
+If one fails, for example because a hook rejects it, HEAD and the index
+are restored to where they were before the plan started. The real index
+is otherwise left as it was; --update-index applies the committed lines
+to it once all of them succeed, as 'hunk commit --update-index' does.`,
+		Example: `  # Check a plan without committing
+  hunk plan apply plan.json --dry-run
+
+  # Create the commits and report their hashes
+  hunk --json plan apply plan.json
+
+  # Read the plan from stdin
+  cat plan.json | hunk plan apply -`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := readPlan(cmd.InOrStdin(), args[0])
+			if err != nil {
+				return err
+			}
+
+			// Later failures aren't usage errors, and usage text would
+			// corrupt a JSON error on stdout.
+			cmd.SilenceUsage = true
+
+			return runPlanApply(
+				cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+				p, opts,
+			)
+		},
+	}
+
+	cmd.Flags().BoolVar(
+		&opts.dryRun, "dry-run", false,
+		"check the plan's selections without committing",
+	)
+	cmd.Flags().BoolVar(
+		&opts.updateIndex, "update-index", false,
+		"apply the committed lines to the real index after committing",
+	)
+
+	return cmd
+}
+
+// readPlan reads and parses a plan from a file, or from stdin given -.
+func readPlan(stdin io.Reader, path string) (*plan.Plan, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	return plan.Parse(data)
+}
+
+func runPlanApply(
+	ctx context.Context, w, errW io.Writer, p *plan.Plan,
+	opts planApplyOptions,
+) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	head, err := executor.RevParse(ctx, "HEAD")
+	if err != nil {
+		return fmt.Errorf("cannot apply a plan without a HEAD commit")
+	}
+
+	out := planApplyOutput{
+		SchemaVersion: schema.Version,
+		DryRun:        opts.dryRun,
+		Original:      head,
+		Commits:       make([]planCommitJSON, 0, len(p.Commits)),
+	}
+
+	// fail reports the failure of plan commit i, as JSON under --json,
+	// and returns it as an error so the command still fails.
+	fail := func(i int, err error) error {
+		err = fmt.Errorf("commit %d: %w", i+1, err)
+		if !cfg.JSONOut {
+			return err
+		}
+
+		out.Success = false
+		out.Commits = out.Commits[:0]
+		out.Message = err.Error()
+		out.Failed = &planFailure{Commit: i + 1, Message: err.Error()}
+
+		var hookErr *git.HookError
+		if errors.As(err, &hookErr) {
+			out.Failed.Hook = hookErr.Hook
+			out.Failed.ExitCode = hookErr.ExitCode
+			out.Failed.Output = hookErr.Output
+		}
+
+		if writeErr := writePlanApplyJSON(w, out); writeErr != nil {
+			return writeErr
+		}
+
+		return err
+	}
+
+	// Every change since HEAD, staged or not, including new files added
+	// to the index with or without their content.
+	diffText, err := executor.DiffCommits(ctx, head, "")
+	if err != nil {
+		return err
+	}
+
+	if diffText == "" {
+		return fmt.Errorf("no changes since HEAD")
+	}
+
+	parsed, err := diff.Parse(diffText)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := newPathResolver(ctx, cfg, executor)
+	if err != nil {
+		return err
+	}
+
+	// Check every selection up front. Each commit's patch takes HEAD to
+	// its tree: its own selections plus those of the commits before it.
+	var (
+		args    []string
+		patches [][]byte
+	)
+	for i, c := range p.Commits {
+		selections, err := symbolSelections(
+			ctx, executor, resolver, parsed, head, c.Selections,
+		)
+		if err != nil {
+			return fail(i, err)
+		}
+
+		args = append(args, selections...)
+
+		patchBytes, err := selectionPatch(resolver, parsed, args)
+		if err != nil {
+			return fail(i, err)
+		}
+
+		if i > 0 && bytes.Equal(patchBytes, patches[i-1]) {
+			return fail(i, fmt.Errorf("selects no changes beyond "+
+				"the commits before it"))
+		}
+
+		patches = append(patches, patchBytes)
+	}
+
+	if opts.dryRun {
+		for _, c := range p.Commits {
+			out.Commits = append(out.Commits, planCommitJSON{
+				Subject: planSubject(c.Message),
+			})
+		}
+
+		out.Success = true
+		out.Message = fmt.Sprintf("Plan is valid: would create %d "+
+			"commit(s)", len(p.Commits))
+
+		return formatPlanApply(w, cfg, out)
+	}
+
+	// The commits are built in a temporary index, but hooks may still
+	// touch the real one, so it is saved to be restored on failure.
+	indexPath, err := executor.IndexPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	savedIndex, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to save the index: %w", err)
+	}
+
+	rollback := func(i int, err error) error {
+		rollbackErr := executor.ResetSoft(ctx, head)
+		if rollbackErr == nil {
+			rollbackErr = os.WriteFile(indexPath, savedIndex, 0o644)
+		}
+
+		if rollbackErr != nil {
+			return fail(i, fmt.Errorf("%w; rolling back to %s "+
+				"also failed: %v", err, head, rollbackErr))
+		}
+
+		out.RolledBack = true
+
+		return fail(i, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	var hookOutput strings.Builder
+	for i, c := range p.Commits {
+		if err := tmp.ReadTree(ctx, head); err != nil {
+			return rollback(i, err)
+		}
+
+		if err := applyVerified(ctx, tmp, patches[i]); err != nil {
+			return rollback(
+				i, fmt.Errorf("failed to build commit: %w", err),
+			)
+		}
+
+		result, err := tmp.Commit(ctx, git.CommitOptions{
+			Message:  c.Message,
+			Author:   c.Author,
+			Date:     c.Date,
+			Signoff:  c.Signoff,
+			Trailers: c.Trailers,
+		})
+		if err != nil {
+			return rollback(i, err)
+		}
+
+		hookOutput.WriteString(result.HookOutput)
+		out.Commits = append(out.Commits, planCommitJSON{
+			Hash:    result.Hash,
+			Subject: result.Subject,
+		})
+	}
+
+	if opts.updateIndex {
+		err := updateIndex(
+			ctx, errW, cfg, tmp, patches[len(patches)-1],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !cfg.JSONOut {
+		fmt.Fprint(errW, hookOutput.String())
+	}
+
+	out.Success = true
+	out.Message = fmt.Sprintf("Created %d commit(s)", len(out.Commits))
+
+	return formatPlanApply(w, cfg, out)
+}
+
+// planSubject returns the subject line of a commit message.
+func planSubject(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+
+	return subject
+}
+
+// formatPlanApply writes the outcome of a plan.
+func formatPlanApply(w io.Writer, cfg Config, out planApplyOutput) error {
+	if cfg.JSONOut {
+		return writePlanApplyJSON(w, out)
+	}
+
+	fmt.Fprintf(w, "%s.\n\n", out.Message)
+
+	for i, c := range out.Commits {
+		if c.Hash == "" {
+			fmt.Fprintf(w, "  %d. %s\n", i+1, c.Subject)
+		} else {
+			fmt.Fprintf(w, "  %s %s\n", c.Hash[:7], c.Subject)
+		}
+	}
+
+	return nil
+}
+
+func writePlanApplyJSON(w io.Writer, out planApplyOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(out)
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// setupPlanRepo creates a repository with changes to alpha.txt and a new
+// beta.txt, added to the index without its content.
+func setupPlanRepo(t *testing.T) *testutil.GitTestRepo {
+	t.Helper()
+
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("alpha.txt", "a1\na2\na3\na4\na5\n")
+	repo.CommitAll("Base commit")
+
+	repo.WriteFile("alpha.txt", "A1\na2\na3\na4\nA5\n")
+	repo.WriteFile("beta.txt", "b1\n")
+	repo.Git("add", "-N", "beta.txt")
+
+	return repo
+}
+
+// applyPlan runs 'hunk --json plan apply' on a plan, returning its output
+// and error.
+func applyPlan(
+	t *testing.T, dir, planJSON string, args ...string,
+) (*planApplyOutput, error) {
+	t.Helper()
+
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(planPath, []byte(planJSON), 0o644))
+
+	rootCmd := NewRootCmd()
+	rootCmd.SetArgs(append(
+		[]string{"--dir", dir, "--json", "plan", "apply", planPath},
+		args...,
+	))
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	err := rootCmd.Execute()
+	if stdout.Len() == 0 {
+		return nil, err
+	}
+
+	require.NoError(t, schema.Validate("plan apply", stdout.Bytes()),
+		stdout.String())
+
+	var out planApplyOutput
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &out))
+
+	return &out, err
+}
+
+func TestPlanApply(t *testing.T) {
+	repo := setupPlanRepo(t)
+	head := repo.GetFullHash()
+
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:1"], "message": "Change line 1"},
+		{"selections": ["beta.txt", "alpha.txt:@1.2"],
+		 "message": "Add beta\n\nAnd line 5.", "signoff": true}
+	]}`, "--update-index")
+	require.NoError(t, err)
+	require.True(t, out.Success)
+	require.Equal(t, head, out.Original)
+	require.Len(t, out.Commits, 2)
+	require.Equal(t, "Add beta", out.Commits[1].Subject)
+	require.Equal(t, out.Commits[1].Hash, repo.GetFullHash())
+
+	require.Equal(t, "Add beta\nChange line 1\nBase commit\n",
+		repo.Git("log", "--format=%s"))
+	require.Equal(t, "A1\na2\na3\na4\na5\n",
+		repo.Git("show", "HEAD~1:alpha.txt"))
+	require.Equal(t, "alpha.txt\nbeta.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD"))
+	require.Contains(t, repo.Git("log", "-1", "--format=%B"),
+		"Signed-off-by:")
+
+	// Everything was committed and added to the index, so nothing shows
+	// as staged.
+	require.Empty(t, repo.DiffCached())
+}
+
+func TestPlanApplyKeepsIndex(t *testing.T) {
+	repo := setupPlanRepo(t)
+
+	indexPath := filepath.Join(repo.Dir, ".git", "index")
+	index, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:1"], "message": "One"}
+	]}`)
+	require.NoError(t, err)
+	require.True(t, out.Success)
+
+	after, err := os.ReadFile(indexPath)
+	require.NoError(t, err)
+	require.Equal(t, index, after)
+}
+
+func TestPlanApplyDryRun(t *testing.T) {
+	repo := setupPlanRepo(t)
+	head := repo.GetFullHash()
+	staged := repo.DiffCached()
+
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:1"], "message": "One"},
+		{"selections": ["beta.txt"], "message": "Two"}
+	]}`, "--dry-run")
+	require.NoError(t, err)
+	require.True(t, out.Success)
+	require.True(t, out.DryRun)
+	require.Equal(t, []planCommitJSON{
+		{Subject: "One"}, {Subject: "Two"},
+	}, out.Commits)
+
+	require.Equal(t, head, repo.GetFullHash())
+	require.Equal(t, staged, repo.DiffCached())
+}
+
+func TestPlanApplyValidation(t *testing.T) {
+	repo := setupPlanRepo(t)
+	head := repo.GetFullHash()
+
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:1"], "message": "One"},
+		{"selections": ["alpha.txt:3"], "message": "Two"}
+	]}`)
+	require.ErrorContains(t, err,
+		"commit 2: selects no changes beyond the commits before it")
+	require.False(t, out.Success)
+	require.Equal(t, 2, out.Failed.Commit)
+	require.Empty(t, out.Commits)
+
+	_, err = applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:3"], "message": "One"}
+	]}`)
+	require.ErrorContains(t, err, "commit 1: no matching lines")
+
+	_, err = applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt:1"], "message": "One"},
+		{"selections": ["gamma.txt"], "message": "Two"}
+	]}`)
+	require.ErrorContains(t, err, "commit 2: no changes for gamma.txt")
+
+	require.Equal(t, head, repo.GetFullHash())
+}
+
+func TestPlanApplyRollback(t *testing.T) {
+	repo := setupPlanRepo(t)
+	head := repo.GetFullHash()
+	staged := repo.DiffCached()
+
+	// Reject any commit that adds beta.txt.
+	hook := filepath.Join(repo.Dir, ".git", "hooks", "pre-commit")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0o755))
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\n"+
+		"git diff --cached --name-only | grep -q beta.txt || exit 0\n"+
+		"echo 'no beta' >&2\nexit 1\n"), 0o755))
+
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["alpha.txt"], "message": "One"},
+		{"selections": ["beta.txt"], "message": "Two"}
+	]}`)
+	require.ErrorContains(t, err, "pre-commit hook failed")
+	require.False(t, out.Success)
+	require.True(t, out.RolledBack)
+	require.Equal(t, &planFailure{
+		Commit:   2,
+		Message:  err.Error(),
+		Hook:     "pre-commit",
+		ExitCode: 1,
+		Output:   "no beta\n",
+	}, out.Failed)
+
+	// The first commit is undone and the index is as it was.
+	require.Equal(t, head, repo.GetFullHash())
+	require.Equal(t, staged, repo.DiffCached())
+	require.Equal(t, "a1\na2\na3\na4\na5\n", repo.Git("show", ":alpha.txt"))
+	require.Equal(t, "beta.txt\n", repo.Git("ls-files", "beta.txt"))
+}
+
+func TestPlanApplySymbols(t *testing.T) {
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("main.go", `package main
+
+// a returns one.
+func a() int {
+	return 1
+}
+
+func c() int {
+	return 3
+}
+
+type T struct{}
+
+func (t *T) M() int {
+	return 4
+}
+
+func b() int {
+	return 2
+}
+`)
+	repo.WriteFile("notes.txt", "n1\n")
+	repo.CommitAll("Base commit")
+
+	first := `package main
+
+// a returns one, plus nothing.
+func a() int {
+	return 1 + 0
+}
+
+type T struct{}
+
+func (t *T) M() int {
+	return 4
+}
+
+func b() int {
+	return 2
+}
+`
+	second := strings.Replace(first, "return 4", "return 40", 1)
+	final := strings.Replace(second, "return 2\n", "return 20\n", 1)
+	repo.WriteFile("main.go", final)
+	repo.WriteFile("notes.txt", "N1\n")
+
+	_, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["main.go:nope"], "message": "One"}
+	]}`, "--dry-run")
+	require.ErrorContains(t, err, "symbol nope not found in main.go")
+
+	_, err = applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["notes.txt:n1"], "message": "One"}
+	]}`, "--dry-run")
+	require.ErrorContains(t, err, "notes.txt is not a Go file")
+
+	// The changes to a and the removal of c, then the method, then b.
+	out, err := applyPlan(t, repo.Dir, `{"commits": [
+		{"selections": ["main.go:a", "main.go:c"], "message": "One"},
+		{"selections": ["main.go:T.M"], "message": "Two"},
+		{"selections": ["main.go:b", "notes.txt"], "message": "Three"}
+	]}`)
+	require.NoError(t, err)
+	require.True(t, out.Success)
+
+	require.Equal(t, first, repo.Git("show", "HEAD~2:main.go"))
+	require.Equal(t, second, repo.Git("show", "HEAD~1:main.go"))
+	require.Equal(t, final, repo.Git("show", "HEAD:main.go"))
+	require.Empty(t, repo.Git("diff", "HEAD"))
+}
@@ -6,14 +6,18 @@ import (
 	"io"
 
 	"github.com/roasbeef/hunk/diff"
-	"github.com/roasbeef/hunk/git"
 	"github.com/roasbeef/hunk/output"
 	"github.com/spf13/cobra"
 )
 
 // NewPreviewCmd creates the preview command.
 func NewPreviewCmd() *cobra.Command {
-	var showRaw bool
+	var (
+		showRaw    bool
+		jsonStream bool
+		text       textFlags
+		budget     budgetFlags
+	)
 
 	cmd := &cobra.Command{
 		Use:   "preview",
@@ -28,9 +32,31 @@ This is equivalent to 'git diff --cached' but with hunk-style formatting.`,
   hunk preview --json
 
   # Show raw unified diff
-  hunk preview --raw`,
+  hunk preview --raw
+
+  # Review staged changes in two columns
+  hunk preview --format=side-by-side
+
+  # Show at most 200 lines of staged changes
+  hunk preview --max-lines 200`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return runPreview(cmd.Context(), cmd.OutOrStdout(), showRaw)
+			if jsonStream {
+				if err := budget.checkStream(); err != nil {
+					return err
+				}
+
+				cfg := getConfig(cmd.Context())
+
+				return streamDiff(
+					cmd.Context(), cmd.OutOrStdout(),
+					newDiffExecutor(cfg), true, nil,
+				)
+			}
+
+			return runPreview(
+				cmd.Context(), cmd.OutOrStdout(), showRaw, text,
+				budget,
+			)
 		},
 	}
 
@@ -38,13 +64,39 @@ This is equivalent to 'git diff --cached' but with hunk-style formatting.`,
 		&showRaw, "raw", false,
 		"show raw unified diff",
 	)
+	cmd.Flags().BoolVar(
+		&jsonStream, "json-stream", false,
+		"stream newline-delimited JSON records as the diff is read",
+	)
+	text.register(cmd)
+	budget.register(cmd)
 
 	return cmd
 }
 
-func runPreview(ctx context.Context, w io.Writer, showRaw bool) error {
+func runPreview(
+	ctx context.Context, w io.Writer, showRaw bool, text textFlags,
+	budgetOpts budgetFlags,
+) error {
 	cfg := getConfig(ctx)
-	executor := git.NewShellExecutor(cfg.WorkDir)
+
+	textOpts, err := text.options(w)
+	if err != nil {
+		return err
+	}
+
+	budget, err := budgetOpts.budget()
+	if err != nil {
+		return err
+	}
+
+	executor := newDiffExecutor(cfg)
+
+	prefix, err := workDirPrefix(ctx, executor)
+	if err != nil {
+		return err
+	}
+	textOpts.RelativeTo = text.relativeTo(prefix)
 
 	diffText, err := executor.DiffCached(ctx)
 	if err != nil {
@@ -66,13 +118,32 @@ func runPreview(ctx context.Context, w io.Writer, showRaw bool) error {
 		return err
 	}
 
+	err = lookupBinarySizes(ctx, executor, false, parsed.AllFiles()...)
+	if err != nil {
+		return err
+	}
+
+	// JSON always carries context lines.
+	budget.HideContext = textOpts.HideContext && !cfg.JSONOut
+	shown, truncation := budget.Apply(parsed)
+	textOpts.Truncated = truncation != nil
+
 	if cfg.JSONOut {
-		return output.FormatJSON(w, parsed)
+		return output.FormatJSONWithOptions(w, shown, output.JSONOptions{
+			RelativeTo: prefix,
+			Truncation: truncation,
+		})
 	}
 
 	if showRaw {
 		return output.FormatRaw(w, parsed)
 	}
 
-	return output.FormatText(w, parsed, output.DefaultTextOptions())
+	if err := text.render(w, shown, textOpts); err != nil {
+		return err
+	}
+
+	output.FormatTruncation(w, truncation, textOpts)
+
+	return nil
 }
@@ -11,11 +11,13 @@ import (
 
 	"github.com/roasbeef/hunk/git"
 	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
 // autosquashOutput is the JSON output for autosquash.
 type autosquashOutput struct {
+	SchemaVersion int                `json:"schema_version"`
 	Success       bool               `json:"success"`
 	Message       string             `json:"message"`
 	FixupsApplied int                `json:"fixups_applied"`
@@ -102,8 +104,9 @@ func runRebaseAutosquash(
 	if len(commits) == 0 {
 		if cfg.JSONOut {
 			return json.NewEncoder(w).Encode(autosquashOutput{
-				Success: true,
-				Message: "No commits to rebase",
+				SchemaVersion: schema.Version,
+				Success:       true,
+				Message:       "No commits to rebase",
 			})
 		}
 
@@ -118,6 +121,7 @@ func runRebaseAutosquash(
 	if fixupCount == 0 {
 		if cfg.JSONOut {
 			return json.NewEncoder(w).Encode(autosquashOutput{
+				SchemaVersion: schema.Version,
 				Success:       true,
 				Message:       "No fixup/squash commits found",
 				FixupsApplied: 0,
@@ -300,15 +304,33 @@ func executeAutosquashRebase(
 	cfg *Config, executor *git.ShellExecutor,
 	onto string, plan *rebase.Spec, fixupCount int,
 ) error {
+	state, err := startSpecRebase(ctx, executor, onto, plan)
+	if err != nil {
+		return err
+	}
+
+	if cfg.JSONOut {
+		return formatAutosquashJSON(w, state, fixupCount)
+	}
+
+	return formatAutosquashText(w, state, fixupCount)
+}
+
+// startSpecRebase runs the rebase described by plan through the _apply-spec
+// sequence editor and returns the state it ends in.
+func startSpecRebase(
+	ctx context.Context, executor *git.ShellExecutor,
+	onto string, plan *rebase.Spec,
+) (*git.RebaseState, error) {
 	// Create temp file for the spec.
 	specData, err := json.Marshal(plan)
 	if err != nil {
-		return fmt.Errorf("failed to serialize spec: %w", err)
+		return nil, fmt.Errorf("failed to serialize spec: %w", err)
 	}
 
-	tmpFile, err := os.CreateTemp("", "hunk-autosquash-spec-*.json")
+	tmpFile, err := os.CreateTemp("", "hunk-rebase-spec-*.json")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tmpPath := tmpFile.Name()
 
@@ -316,7 +338,7 @@ func executeAutosquashRebase(
 		tmpFile.Close()
 		os.Remove(tmpPath)
 
-		return fmt.Errorf("failed to write spec: %w", err)
+		return nil, fmt.Errorf("failed to write spec: %w", err)
 	}
 
 	tmpFile.Close()
@@ -325,27 +347,18 @@ func executeAutosquashRebase(
 	// Get the hunk binary path for the sequence editor.
 	hunkPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
 	}
 
 	editor := formatAutosquashEditorCommand(hunkPath, tmpPath)
 
 	// Start the rebase.
 	if err := executor.RebaseStart(ctx, onto, editor); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check final status.
-	state, err := executor.RebaseStatus(ctx)
-	if err != nil {
-		return err
-	}
-
-	if cfg.JSONOut {
-		return formatAutosquashJSON(w, state, fixupCount)
-	}
-
-	return formatAutosquashText(w, state, fixupCount)
+	return executor.RebaseStatus(ctx)
 }
 
 func formatAutosquashEditorCommand(hunkPath, specPath string) string {
@@ -374,6 +387,7 @@ func formatAutosquashDryRun(
 		}
 
 		return json.NewEncoder(w).Encode(autosquashOutput{
+			SchemaVersion: schema.Version,
 			Success:       true,
 			Message:       "Dry run - no changes made",
 			FixupsApplied: fixupCount,
@@ -394,6 +408,7 @@ func formatAutosquashJSON(
 	w io.Writer, state *git.RebaseState, fixupCount int,
 ) error {
 	output := autosquashOutput{
+		SchemaVersion: schema.Version,
 		Success:       !state.InProgress,
 		FixupsApplied: fixupCount,
 		InProgress:    state.InProgress,
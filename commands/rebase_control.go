@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
@@ -19,9 +20,10 @@ const (
 
 // rebaseControlOutput is the JSON output for rebase control commands.
 type rebaseControlOutput struct {
-	Success    bool   `json:"success"`
-	Message    string `json:"message"`
-	InProgress bool   `json:"in_progress"`
+	SchemaVersion int    `json:"schema_version"`
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+	InProgress    bool   `json:"in_progress"`
 }
 
 // NewRebaseContinueCmd creates the rebase continue command.
@@ -132,8 +134,9 @@ func formatRebaseControlJSON(
 	w io.Writer, action string, state *git.RebaseState,
 ) error {
 	output := rebaseControlOutput{
-		Success:    true,
-		InProgress: state.InProgress,
+		SchemaVersion: schema.Version,
+		Success:       true,
+		InProgress:    state.InProgress,
 	}
 
 	switch action {
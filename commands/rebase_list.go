@@ -7,15 +7,17 @@ import (
 	"io"
 
 	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
 // rebaseListOutput is the JSON output for rebase list.
 type rebaseListOutput struct {
-	Base    string             `json:"base"`
-	Head    string             `json:"head"`
-	Commits []commitInfoOutput `json:"commits"`
-	Count   int                `json:"count"`
+	SchemaVersion int                `json:"schema_version"`
+	Base          string             `json:"base"`
+	Head          string             `json:"head"`
+	Commits       []commitInfoOutput `json:"commits"`
+	Count         int                `json:"count"`
 }
 
 // commitInfoOutput is the JSON output for commit info.
@@ -82,10 +84,11 @@ func runRebaseList(ctx context.Context, w io.Writer, onto string) error {
 
 func formatRebaseListJSON(w io.Writer, onto string, commits []git.CommitInfo) error {
 	output := rebaseListOutput{
-		Base:    onto,
-		Head:    "HEAD",
-		Commits: make([]commitInfoOutput, len(commits)),
-		Count:   len(commits),
+		SchemaVersion: schema.Version,
+		Base:          onto,
+		Head:          "HEAD",
+		Commits:       make([]commitInfoOutput, len(commits)),
+		Count:         len(commits),
 	}
 
 	for i, c := range commits {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/roasbeef/hunk/git"
 	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
@@ -20,10 +21,11 @@ const osWindows = "windows"
 
 // rebaseRunOutput is the JSON output for rebase run.
 type rebaseRunOutput struct {
-	Success     bool   `json:"success"`
-	Message     string `json:"message"`
-	InProgress  bool   `json:"in_progress,omitempty"`
-	HasConflict bool   `json:"has_conflict,omitempty"`
+	SchemaVersion int    `json:"schema_version"`
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+	InProgress    bool   `json:"in_progress,omitempty"`
+	HasConflict   bool   `json:"has_conflict,omitempty"`
 }
 
 // NewRebaseRunCmd creates the rebase run command.
@@ -215,9 +217,10 @@ func parseRebaseSpec(specFile string, args []string) (*rebase.Spec, error) {
 
 func formatRebaseRunJSON(w io.Writer, state *git.RebaseState) error {
 	output := rebaseRunOutput{
-		Success:     !state.InProgress,
-		InProgress:  state.InProgress,
-		HasConflict: state.State == git.RebaseStateConflict,
+		SchemaVersion: schema.Version,
+		Success:       !state.InProgress,
+		InProgress:    state.InProgress,
+		HasConflict:   state.State == git.RebaseStateConflict,
 	}
 
 	if state.InProgress {
@@ -7,11 +7,13 @@ import (
 	"io"
 
 	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/schema"
 	"github.com/spf13/cobra"
 )
 
 // rebaseStatusOutput is the JSON output for rebase status.
 type rebaseStatusOutput struct {
+	SchemaVersion    int                  `json:"schema_version"`
 	InProgress       bool                 `json:"in_progress"`
 	State            string               `json:"state"`
 	CurrentAction    string               `json:"current_action,omitempty"`
@@ -74,6 +76,7 @@ func runRebaseStatus(ctx context.Context, w io.Writer) error {
 
 func formatRebaseStatusJSON(w io.Writer, state *git.RebaseState) error {
 	output := rebaseStatusOutput{
+		SchemaVersion:    schema.Version,
 		InProgress:       state.InProgress,
 		State:            string(state.State),
 		TotalCommits:     state.TotalCount,
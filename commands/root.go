@@ -3,8 +3,10 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"os"
 
+	"github.com/roasbeef/hunk/git"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +17,11 @@ type configKey struct{}
 type Config struct {
 	WorkDir string
 	JSONOut bool
+
+	// Renames controls rename and copy detection for every diff hunk
+	// reads. Diff and stage must agree on it so that line numbers shown
+	// by one are valid selections for the other.
+	Renames git.RenameOptions
 }
 
 // getConfig retrieves config from context, or returns defaults.
@@ -26,11 +33,31 @@ func getConfig(ctx context.Context) Config {
 	return Config{}
 }
 
+// newDiffExecutor creates an executor that diffs with the configured rename
+// and copy detection. Binary changes are left out of its diffs, which suits
+// diffs that are only shown; see lookupBinarySizes.
+func newDiffExecutor(cfg Config) *git.ShellExecutor {
+	executor := git.NewShellExecutor(cfg.WorkDir)
+	executor.Renames = cfg.Renames
+
+	return executor
+}
+
+// newPatchExecutor creates an executor for diffs that patches are built
+// from. Binary changes carry their contents so they can be staged.
+func newPatchExecutor(cfg Config) *git.ShellExecutor {
+	executor := newDiffExecutor(cfg)
+	executor.Binary = true
+
+	return executor
+}
+
 // NewRootCmd creates the root command.
 func NewRootCmd() *cobra.Command {
 	var (
 		workDir string
 		jsonOut bool
+		renames git.RenameOptions
 	)
 
 	cmd := &cobra.Command{
@@ -64,14 +91,24 @@ Examples:
 
   # Apply a patch directly to staging
   hunk apply-patch < changes.diff`,
-		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if renames.Threshold < 1 || renames.Threshold > 100 {
+				return fmt.Errorf(
+					"--find-renames must be between 1 and 100, got %d",
+					renames.Threshold,
+				)
+			}
+
 			// Store config in context for subcommands.
 			cfg := Config{
 				WorkDir: workDir,
 				JSONOut: jsonOut,
+				Renames: renames,
 			}
 			ctx := context.WithValue(cmd.Context(), configKey{}, cfg)
 			cmd.SetContext(ctx)
+
+			return nil
 		},
 	}
 
@@ -84,15 +121,36 @@ Examples:
 		"output in JSON format (for machine consumption)",
 	)
 
+	cmd.PersistentFlags().IntVarP(
+		&renames.Threshold, "find-renames", "M", git.DefaultRenameThreshold,
+		"similarity percentage at which a delete and add pair is a rename",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&renames.Disabled, "no-renames", false,
+		"disable rename and copy detection",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&renames.Copies, "find-copies", false,
+		"also detect copies, using the --find-renames threshold",
+	)
+
 	// Add subcommands.
 	cmd.AddCommand(NewDiffCmd())
 	cmd.AddCommand(NewStageCmd())
 	cmd.AddCommand(NewPreviewCmd())
+	cmd.AddCommand(NewShowCmd())
+	cmd.AddCommand(NewValidateCmd())
 	cmd.AddCommand(NewCommitCmd())
+	cmd.AddCommand(NewAbsorbCmd())
+	cmd.AddCommand(NewAmendIntoCmd())
+	cmd.AddCommand(NewSplitCmd())
+	cmd.AddCommand(NewPlanCmd())
+	cmd.AddCommand(NewHistoryCmd())
 	cmd.AddCommand(NewResetCmd())
 	cmd.AddCommand(NewApplyPatchCmd())
 	cmd.AddCommand(NewVersionCmd())
 	cmd.AddCommand(NewRebaseCmd())
+	cmd.AddCommand(NewSchemaCmd())
 
 	return cmd
 }
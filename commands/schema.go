@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// NewSchemaCmd creates the schema command.
+func NewSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema [command]",
+		Short: "Print the JSON Schema for a command's --json output",
+		Long: `Print the JSON Schema describing a command's --json output.
+
+Every JSON document hunk emits carries a schema_version field. The version
+is bumped whenever a field is removed or changes meaning, so agents can
+detect incompatible output instead of silently misparsing it.
+
+With no arguments, lists the commands that have a published schema.`,
+		Example: `  # List available schemas
+  hunk schema
+
+  # Print the schema for diff --json
+  hunk schema diff
+
+  # Print the schema for rebase list --json
+  hunk schema rebase list
+
+  # Print the schema for diff --stage-hints --json
+  hunk schema diff --stage-hints`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSchema(cmd.OutOrStdout(), args)
+		},
+	}
+
+	// Flags after the command name, as in "diff --stage-hints", name
+	// the schema rather than being flags of schema itself.
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+func runSchema(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(w, "Schema version: %d\n\n", schema.Version)
+
+		for _, name := range schema.Names() {
+			fmt.Fprintf(w, "  %s\n",
+				strings.Join(schema.Commands(name), ", "))
+		}
+
+		return nil
+	}
+
+	data, err := schema.Get(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
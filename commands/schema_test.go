@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// runJSON executes the root command in-process with --json and returns
+// stdout.
+func runJSON(t *testing.T, dir string, args ...string) []byte {
+	t.Helper()
+
+	rootCmd := NewRootCmd()
+	rootCmd.SetArgs(append([]string{"--dir", dir, "--json"}, args...))
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+
+	require.NoError(t, rootCmd.Execute())
+
+	return stdout.Bytes()
+}
+
+// TestOutputsMatchSchemas runs each command that supports --json against a
+// real repository and checks the output against its published schema, so
+// any drift between the two fails here.
+func TestOutputsMatchSchemas(t *testing.T) {
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("base.txt", "base content\n")
+	repo.CommitAll("Base commit")
+
+	repo.CreateBranch("feature")
+
+	repo.WriteFile("main.go", "package main\n\nfunc main() {}\n")
+	repo.CommitAll("Add main")
+
+	repo.WriteFile("main.go", "package main\n\n// Added.\nfunc main() {}\n")
+	repo.WriteFile("base.txt", "changed content\n")
+	repo.StageFile("base.txt")
+	repo.WriteFile("new.txt", "untracked\n")
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+	}{
+		{
+			name:    "diff",
+			command: "diff",
+			args:    []string{"diff"},
+		},
+		{
+			name:    "diff staged",
+			command: "diff",
+			args:    []string{"diff", "--staged"},
+		},
+		{
+			name:    "diff truncated",
+			command: "diff",
+			args:    []string{"diff", "--max-files", "1", "--staged"},
+		},
+		{
+			name:    "diff stage hints",
+			command: "diff stage-hints",
+			args:    []string{"diff", "--stage-hints"},
+		},
+		{
+			name:    "preview",
+			command: "preview",
+			args:    []string{"preview"},
+		},
+		{
+			name:    "show",
+			command: "show",
+			args:    []string{"show", "HEAD"},
+		},
+		{
+			name:    "show root stat",
+			command: "show",
+			args:    []string{"show", "--stat", "main"},
+		},
+		{
+			name:    "validate",
+			command: "validate",
+			args:    []string{"validate", "--run", "cat base.txt"},
+		},
+		{
+			name:    "rebase list",
+			command: "rebase list",
+			args:    []string{"rebase", "list", "--onto", "main"},
+		},
+		{
+			name:    "rebase status",
+			command: "rebase status",
+			args:    []string{"rebase", "status"},
+		},
+		{
+			name:    "rebase autosquash without fixups",
+			command: "rebase autosquash",
+			args: []string{
+				"rebase", "autosquash", "--onto", "main",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runJSON(t, repo.Dir, tt.args...)
+			require.NoError(t, schema.Validate(tt.command, out), string(out))
+		})
+	}
+
+	t.Run("diff json stream", func(t *testing.T) {
+		rootCmd := NewRootCmd()
+		rootCmd.SetArgs([]string{"--dir", repo.Dir, "diff", "--json-stream"})
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.NotEmpty(t, lines)
+
+		for _, line := range lines {
+			err := schema.Validate("diff stream", []byte(line))
+			require.NoError(t, err, line)
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		out := runJSON(t, repo.Dir, "commit", "-m", "Update base")
+		require.NoError(t, schema.Validate("commit", out), string(out))
+	})
+
+	t.Run("rebase run", func(t *testing.T) {
+		repo.Git("add", "-A")
+		repo.Git("commit", "-m", "Update files")
+		hash := repo.GetShortHash()
+
+		out, err := runHunkCommand(
+			t, repo.Dir, "--json",
+			"rebase", "run", "--onto", "main", hash,
+		)
+		require.NoError(t, err, out)
+		require.NoError(t, schema.Validate("rebase run", []byte(out)), out)
+	})
+}
+
+func TestSchemaCommand(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		rootCmd := NewRootCmd()
+		rootCmd.SetArgs([]string{"schema"})
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		require.Contains(t, stdout.String(), "rebase list")
+		require.Contains(t, stdout.String(), "diff --stage-hints")
+		require.Contains(t, stdout.String(), "rebase continue, rebase abort")
+
+		// Every listed name is a real command line, and looking it
+		// up again prints its schema.
+		listed := strings.Split(stdout.String(), "\n\n")[1]
+		listed = strings.ReplaceAll(listed, ", ", "\n")
+		for _, line := range strings.Split(
+			strings.TrimSpace(listed), "\n",
+		) {
+			args := strings.Fields(line)
+
+			words, flags := args, []string(nil)
+			for i, arg := range args {
+				if strings.HasPrefix(arg, "--") {
+					words, flags = args[:i], args[i:]
+
+					break
+				}
+			}
+
+			found, rest, err := NewRootCmd().Find(words)
+			require.NoError(t, err, line)
+			require.Empty(t, rest, line)
+			require.Equal(t, "hunk "+strings.Join(words, " "),
+				found.CommandPath(), line)
+
+			for _, flag := range flags {
+				require.NotNil(t, found.Flags().Lookup(
+					strings.TrimPrefix(flag, "--"),
+				), line)
+			}
+
+			lookup := NewRootCmd()
+			lookup.SetArgs(append([]string{"schema"}, args...))
+
+			var out bytes.Buffer
+			lookup.SetOut(&out)
+			require.NoError(t, lookup.Execute(), line)
+
+			want, err := schema.Get(schema.Resolve(line))
+			require.NoError(t, err, line)
+			require.Equal(t, string(want), out.String(), line)
+		}
+	})
+
+	t.Run("multi-word command", func(t *testing.T) {
+		rootCmd := NewRootCmd()
+		rootCmd.SetArgs([]string{"schema", "rebase", "list"})
+
+		var stdout bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		require.NoError(t, rootCmd.Execute())
+
+		want, err := schema.Get("rebase-list")
+		require.NoError(t, err)
+		require.Equal(t, string(want), stdout.String())
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		rootCmd := NewRootCmd()
+		rootCmd.SetArgs([]string{"schema", "bogus"})
+		rootCmd.SetOut(&bytes.Buffer{})
+		rootCmd.SetErr(&bytes.Buffer{})
+
+		require.ErrorContains(t, rootCmd.Execute(), "no schema")
+	})
+}
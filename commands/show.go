@@ -0,0 +1,332 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/output"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// showOutput is the JSON output for show.
+type showOutput struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Commit        showCommitJSON      `json:"commit"`
+	Parent        string              `json:"parent,omitempty"`
+	Files         []output.FileOutput `json:"files"`
+	Moves         []output.MoveOutput `json:"moves,omitempty"`
+	Stat          *showStatJSON       `json:"stat,omitempty"`
+	Truncated     *output.Truncation  `json:"truncated,omitempty"`
+}
+
+// showCommitJSON is the metadata of the commit shown.
+type showCommitJSON struct {
+	Hash       string   `json:"hash"`
+	ShortHash  string   `json:"short_hash"`
+	Subject    string   `json:"subject"`
+	Message    string   `json:"message"`
+	Author     string   `json:"author"`
+	Date       string   `json:"date"`
+	Committer  string   `json:"committer"`
+	CommitDate string   `json:"commit_date"`
+	Parents    []string `json:"parents"`
+}
+
+// showStatJSON counts the lines each file of the commit adds and deletes.
+type showStatJSON struct {
+	Files     []showFileStatJSON `json:"files"`
+	Additions int                `json:"additions"`
+	Deletions int                `json:"deletions"`
+}
+
+// showFileStatJSON counts the lines one file adds and deletes.
+type showFileStatJSON struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// showOptions holds the flags for the show command.
+type showOptions struct {
+	stat   bool
+	parent int
+	text   textFlags
+	budget budgetFlags
+}
+
+// NewShowCmd creates the show command.
+func NewShowCmd() *cobra.Command {
+	var opts showOptions
+
+	cmd := &cobra.Command{
+		Use:   "show <rev> [files...]",
+		Short: "Show a commit's changes with line numbers",
+		Long: `Show a commit's metadata and its changes with line numbers.
+
+The changes are numbered as 'hunk diff' numbers them, so the line
+numbers and change blocks shown are the ones 'hunk split' and 'hunk
+history' take for that commit. Files limits the diff to those paths.
+
+A merge commit is compared with its first parent, or with the parent
+--parent names. A root commit is compared with the empty tree.
+
+Use --json for the commit's full message, parents and committer plus the
+diff in the structure of 'hunk diff --json'. --stat lists the lines each
+file adds and deletes instead of the lines themselves.`,
+		Example: `  # Show the last commit
+  hunk show HEAD
+
+  # Only one file of a commit
+  hunk show abc123 main.go
+
+  # What a merge brought in from the merged branch
+  hunk show --parent 2 HEAD
+
+  # Files and line counts as JSON
+  hunk --json show --stat HEAD~2`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runShow(
+				cmd.Context(), cmd.OutOrStdout(), args[0], args[1:],
+				opts,
+			)
+		},
+	}
+
+	cmd.Flags().BoolVar(
+		&opts.stat, "stat", false,
+		"show the lines each file adds and deletes, not the lines",
+	)
+	cmd.Flags().IntVar(
+		&opts.parent, "parent", 1,
+		"compare a merge commit with this parent (1 is the first)",
+	)
+	opts.text.register(cmd)
+	opts.budget.register(cmd)
+
+	return cmd
+}
+
+func runShow(
+	ctx context.Context, w io.Writer, rev string, paths []string,
+	opts showOptions,
+) error {
+	cfg := getConfig(ctx)
+
+	textOpts, err := opts.text.options(w)
+	if err != nil {
+		return err
+	}
+
+	budget, err := opts.budget.budget()
+	if err != nil {
+		return err
+	}
+
+	executor := newDiffExecutor(cfg)
+
+	prefix, err := workDirPrefix(ctx, executor)
+	if err != nil {
+		return err
+	}
+	textOpts.RelativeTo = opts.text.relativeTo(prefix)
+
+	info, err := executor.CommitDetails(ctx, rev)
+	if err != nil {
+		return err
+	}
+
+	parent, err := showParent(info, opts.parent)
+	if err != nil {
+		return err
+	}
+
+	from := parent
+	if from == "" {
+		from, err = executor.EmptyTree(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	diffText, err := executor.DiffCommits(ctx, from, info.Hash, paths...)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := diff.Parse(diffText)
+	if err != nil {
+		return err
+	}
+
+	err = lookupBinarySizes(ctx, executor, false, parsed.AllFiles()...)
+	if err != nil {
+		return err
+	}
+
+	// JSON always carries context lines.
+	budget.HideContext = textOpts.HideContext && !cfg.JSONOut
+	shown, truncation := budget.Apply(parsed)
+	textOpts.Truncated = truncation != nil
+
+	if cfg.JSONOut {
+		out := showOutput{
+			SchemaVersion: schema.Version,
+			Commit:        newShowCommitJSON(info),
+			Parent:        parent,
+		}
+
+		if opts.stat {
+			diffOut := output.NewDiffOutput(parsed, output.JSONOptions{
+				RelativeTo: prefix,
+			})
+			for i := range diffOut.Files {
+				diffOut.Files[i].Hunks = nil
+			}
+
+			out.Files = diffOut.Files
+			out.Stat = newShowStatJSON(parsed)
+		} else {
+			diffOut := output.NewDiffOutput(shown, output.JSONOptions{
+				RelativeTo: prefix,
+				Truncation: truncation,
+			})
+
+			out.Files = diffOut.Files
+			out.Moves = diffOut.Moves
+			out.Truncated = diffOut.Truncated
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	formatShowHeader(w, info, parent)
+
+	if opts.stat {
+		formatShowStat(w, newShowStatJSON(parsed))
+
+		return nil
+	}
+
+	if err := opts.text.render(w, shown, textOpts); err != nil {
+		return err
+	}
+
+	output.FormatTruncation(w, truncation, textOpts)
+
+	return nil
+}
+
+// showParent returns the parent of info that the nth --parent names, or ""
+// for a root commit.
+func showParent(info *git.CommitInfo, n int) (string, error) {
+	switch {
+	case len(info.Parents) == 0 && n == 1:
+		return "", nil
+
+	case n < 1 || n > len(info.Parents):
+		return "", fmt.Errorf("commit %s has %d parent(s), no parent %d",
+			info.ShortHash, len(info.Parents), n)
+	}
+
+	return info.Parents[n-1], nil
+}
+
+func newShowCommitJSON(info *git.CommitInfo) showCommitJSON {
+	parents := info.Parents
+	if parents == nil {
+		parents = []string{}
+	}
+
+	return showCommitJSON{
+		Hash:       info.Hash,
+		ShortHash:  info.ShortHash,
+		Subject:    info.Subject,
+		Message:    info.Message,
+		Author:     info.Author,
+		Date:       info.Date.Format(time.RFC3339),
+		Committer:  info.Committer,
+		CommitDate: info.CommitDate.Format(time.RFC3339),
+		Parents:    parents,
+	}
+}
+
+func newShowStatJSON(parsed *diff.ParsedDiff) *showStatJSON {
+	stat := &showStatJSON{Files: make([]showFileStatJSON, 0)}
+
+	for file := range parsed.Files() {
+		added, deleted := file.Stats()
+
+		stat.Files = append(stat.Files, showFileStatJSON{
+			Path:      file.Path(),
+			Additions: added,
+			Deletions: deleted,
+			Binary:    file.IsBinary,
+		})
+		stat.Additions += added
+		stat.Deletions += deleted
+	}
+
+	return stat
+}
+
+// formatShowHeader writes the commit's metadata the way git show does.
+func formatShowHeader(w io.Writer, info *git.CommitInfo, parent string) {
+	fmt.Fprintf(w, "commit %s\n", info.Hash)
+
+	if len(info.Parents) > 1 {
+		short := make([]string, 0, len(info.Parents))
+		for _, p := range info.Parents {
+			short = append(short, p[:7])
+		}
+
+		fmt.Fprintf(w, "Merge: %s\n", strings.Join(short, " "))
+		fmt.Fprintf(w, "Diff against: %s\n", parent[:7])
+	}
+
+	fmt.Fprintf(w, "Author: %s\n", info.Author)
+	fmt.Fprintf(w, "Date:   %s\n\n",
+		info.Date.Format("Mon Jan 2 15:04:05 2006 -0700"))
+
+	for _, line := range strings.Split(
+		strings.TrimRight(info.Message, "\n"), "\n",
+	) {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+
+	fmt.Fprintln(w, "")
+}
+
+// formatShowStat writes the line counts of each file and the total.
+func formatShowStat(w io.Writer, stat *showStatJSON) {
+	width := 0
+	for _, f := range stat.Files {
+		width = max(width, len(f.Path))
+	}
+
+	for _, f := range stat.Files {
+		if f.Binary {
+			fmt.Fprintf(w, " %-*s | binary\n", width, f.Path)
+
+			continue
+		}
+
+		fmt.Fprintf(w, " %-*s | +%d -%d\n", width, f.Path,
+			f.Additions, f.Deletions)
+	}
+
+	fmt.Fprintf(w, " %d file(s) changed, %d insertions(+), "+
+		"%d deletions(-)\n", len(stat.Files), stat.Additions,
+		stat.Deletions)
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// showJSON runs 'hunk --json show' and decodes its output.
+func showJSON(t *testing.T, dir string, args ...string) showOutput {
+	t.Helper()
+
+	var out showOutput
+	data := runJSON(t, dir, append([]string{"show"}, args...)...)
+	require.NoError(t, json.Unmarshal(data, &out), string(data))
+
+	return out
+}
+
+func TestShow(t *testing.T) {
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("alpha.txt", "a1\na2\na3\n")
+	repo.CommitAll("Add alpha")
+
+	repo.WriteFile("alpha.txt", "a1\nA2\na3\n")
+	repo.WriteFile("beta.txt", "b1\n")
+	repo.Git("add", "-A")
+	repo.Git("commit", "-q", "-m", "Edit alpha\n\nAnd add beta.")
+
+	parent := strings.TrimSpace(repo.Git("rev-parse", "HEAD~1"))
+
+	out := showJSON(t, repo.Dir, "HEAD")
+	require.Equal(t, "Edit alpha", out.Commit.Subject)
+	require.Equal(t, "Edit alpha\n\nAnd add beta.\n", out.Commit.Message)
+	require.Equal(t, "Test User <test@test.com>", out.Commit.Committer)
+	require.Equal(t, []string{parent}, out.Commit.Parents)
+	require.Equal(t, parent, out.Parent)
+	require.Len(t, out.Files, 2)
+	require.Equal(t, "alpha.txt", out.Files[0].Path)
+	require.NotEmpty(t, out.Files[0].Hunks)
+
+	// Path filters limit the diff.
+	out = showJSON(t, repo.Dir, "HEAD", "beta.txt")
+	require.Len(t, out.Files, 1)
+	require.Equal(t, "new", out.Files[0].Status)
+
+	// A root commit is shown against the empty tree.
+	out = showJSON(t, repo.Dir, "--stat", "HEAD~1")
+	require.Empty(t, out.Parent)
+	require.Empty(t, out.Commit.Parents)
+	require.Empty(t, out.Files[0].Hunks)
+	require.Equal(t, &showStatJSON{
+		Files:     []showFileStatJSON{{Path: "alpha.txt", Additions: 3}},
+		Additions: 3,
+	}, out.Stat)
+
+	text, err := runHunkCommand(t, repo.Dir, "show", "HEAD", "alpha.txt")
+	require.NoError(t, err, text)
+	require.Contains(t, text, "    And add beta.\n")
+	require.Contains(t, text, "+A2")
+	require.NotContains(t, text, "beta.txt")
+}
+
+func TestShowMerge(t *testing.T) {
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("base.txt", "x1\n")
+	repo.CommitAll("Base commit")
+	repo.CreateBranch("feature")
+
+	repo.WriteFile("feature.txt", "f1\n")
+	repo.CommitAll("Add feature")
+
+	repo.Git("checkout", "-q", "main")
+	repo.WriteFile("main.txt", "m1\n")
+	repo.CommitAll("Add main")
+	repo.Git("merge", "-q", "--no-ff", "-m", "Merge feature", "feature")
+
+	// The first parent is main, so the merge brought in feature.txt.
+	out := showJSON(t, repo.Dir, "HEAD")
+	require.Len(t, out.Commit.Parents, 2)
+	require.Equal(t, out.Commit.Parents[0], out.Parent)
+	require.Len(t, out.Files, 1)
+	require.Equal(t, "feature.txt", out.Files[0].Path)
+
+	out = showJSON(t, repo.Dir, "--parent", "2", "HEAD")
+	require.Equal(t, out.Commit.Parents[1], out.Parent)
+	require.Equal(t, "main.txt", out.Files[0].Path)
+
+	_, err := runHunkCommand(t, repo.Dir, "show", "--parent", "3", "HEAD")
+	require.Error(t, err)
+}
@@ -0,0 +1,439 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/rebase"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// splitOutput is the JSON output for split.
+type splitOutput struct {
+	SchemaVersion int           `json:"schema_version"`
+	Success       bool          `json:"success"`
+	Message       string        `json:"message"`
+	Original      string        `json:"original"`
+	Commits       []splitCommit `json:"commits"`
+	InProgress    bool          `json:"in_progress,omitempty"`
+	HasConflict   bool          `json:"has_conflict,omitempty"`
+}
+
+// splitCommit is one of the commits a split produced. Rest marks the final
+// commit holding the changes no part selected.
+type splitCommit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+	Rest    bool   `json:"rest,omitempty"`
+}
+
+// splitOptions holds the flags for the split command.
+type splitOptions struct {
+	parts       []string
+	messages    []string
+	restMessage string
+	specFile    string
+}
+
+// NewSplitCmd creates the split command.
+func NewSplitCmd() *cobra.Command {
+	var opts splitOptions
+
+	cmd := &cobra.Command{
+		Use:   "split <commit>",
+		Short: "Split a commit into several by line selections",
+		Long: `Rewrite history so that a commit becomes several commits.
+
+Each part is a list of FILE:LINES selections and a message. Line numbers
+and change blocks refer to the commit's own diff ('hunk show <commit>'),
+and a bare path takes the whole file. The parts are committed in order,
+each adding its selections to the parts before it. Whatever no part
+selected goes into a final commit, with --rest-message or else the
+original message. The new commits keep the original author and date.
+
+Parts are given as pairs of --part and --message flags, or as JSON with
+--spec:
+
+  {"parts": [{"selections": ["a.go:1-20"], "message": "..."}],
+   "rest_message": "..."}
+
+A --part lists its selections separated by spaces. Quote a path that
+contains spaces, as in --part '"my file.go":3 b.go'.
+
+The commits after the split one are then replayed on top through a
+non-interactive rebase, as with 'hunk rebase run'. The working tree must
+have no staged or unstaged changes.`,
+		Example: `  # Move the parser changes into their own commit
+  hunk split HEAD~2 -p "parser.go lexer.go:10-40" -m "Add lexer tokens"
+
+  # Two parts, with the rest keeping a new message
+  hunk split abc123 -p "a.go:@1.1" -m "First" -p "b.go" -m "Second" \
+    --rest-message "Third"
+
+  # Parts from a JSON file
+  hunk split abc123 --spec split.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := parseSplitSpec(cmd.InOrStdin(), opts)
+			if err != nil {
+				return err
+			}
+
+			// Later failures aren't usage errors.
+			cmd.SilenceUsage = true
+
+			return runSplit(
+				cmd.Context(), cmd.OutOrStdout(), args[0], spec,
+			)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(
+		&opts.parts, "part", "p", nil,
+		"space-separated selections for the next part (repeatable)",
+	)
+	cmd.Flags().StringArrayVarP(
+		&opts.messages, "message", "m", nil,
+		"message for the matching --part (repeatable)",
+	)
+	cmd.Flags().StringVar(
+		&opts.restMessage, "rest-message", "",
+		"message for the changes no part selected "+
+			"(default: the original message)",
+	)
+	cmd.Flags().StringVar(
+		&opts.specFile, "spec", "",
+		"JSON split spec file, or - for stdin",
+	)
+	cmd.MarkFlagsMutuallyExclusive("spec", "part")
+	cmd.MarkFlagsMutuallyExclusive("spec", "message")
+	cmd.MarkFlagsMutuallyExclusive("spec", "rest-message")
+
+	return cmd
+}
+
+// parseSplitSpec reads the split spec from --spec or the part flags.
+func parseSplitSpec(
+	stdin io.Reader, opts splitOptions,
+) (*rebase.SplitSpec, error) {
+	if opts.specFile == "" {
+		if len(opts.parts) == 0 {
+			return nil, fmt.Errorf("no parts specified; provide " +
+				"--part and --message, or --spec")
+		}
+
+		return rebase.ParseCLISplitSpec(
+			opts.parts, opts.messages, opts.restMessage,
+		)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if opts.specFile == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(opts.specFile)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	return rebase.ParseSplitSpec(data)
+}
+
+func runSplit(
+	ctx context.Context, w io.Writer, rev string, spec *rebase.SplitSpec,
+) error {
+	cfg := getConfig(ctx)
+	executor := newPatchExecutor(cfg)
+
+	status, err := executor.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(status.StagedFiles) > 0 || len(status.UnstagedFiles) > 0 {
+		return fmt.Errorf("the working tree has changes; commit or " +
+			"stash them before splitting")
+	}
+
+	target, err := executor.RevParse(ctx, rev)
+	if err != nil {
+		return err
+	}
+
+	parent, err := executor.RevParse(ctx, target+"^")
+	if err != nil {
+		return fmt.Errorf("cannot split %s: it has no parent", rev)
+	}
+
+	// The rebase replays everything after the parent, which must
+	// include the commit being split.
+	commits, err := executor.RebaseList(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	var (
+		info        git.CommitInfo
+		descendants []git.CommitInfo
+	)
+	for _, c := range commits {
+		if c.Hash == target {
+			info = c
+		} else {
+			descendants = append(descendants, c)
+		}
+	}
+
+	if info.Hash == "" {
+		return fmt.Errorf("commit %s is not on the current branch", rev)
+	}
+
+	diffText, err := executor.DiffCommits(ctx, parent, target)
+	if err != nil {
+		return err
+	}
+
+	if diffText == "" {
+		return fmt.Errorf("commit %s changes nothing", rev)
+	}
+
+	parsed, err := diff.Parse(diffText)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := newPathResolver(ctx, cfg, executor)
+	if err != nil {
+		return err
+	}
+
+	split, err := commitSplitParts(
+		ctx, cfg, executor, resolver, parsed, parent, info, spec,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The original commit is left out of the plan, which drops it, and
+	// the new commits are fast-forwarded to in its place. They end at
+	// the original tree, so the later commits replay cleanly.
+	hashes := make([]string, 0, len(split))
+	for _, c := range split {
+		hashes = append(hashes, c.Hash)
+	}
+
+	plan := &rebase.Spec{Actions: []rebase.Action{{
+		Action:  rebase.ActionExec,
+		Command: "git cherry-pick --ff " + strings.Join(hashes, " "),
+	}}}
+	for _, c := range descendants {
+		plan.Actions = append(plan.Actions, rebase.Action{
+			Action: rebase.ActionPick,
+			Commit: c.Hash,
+		})
+	}
+
+	state, err := startSpecRebase(ctx, executor, parent, plan)
+	if err != nil {
+		return err
+	}
+
+	out := splitOutput{
+		SchemaVersion: schema.Version,
+		Success:       !state.InProgress,
+		Original:      target,
+		Commits:       split,
+		InProgress:    state.InProgress,
+		HasConflict:   state.State == git.RebaseStateConflict,
+	}
+
+	switch {
+	case out.HasConflict:
+		out.Message = "Split paused due to conflicts"
+	case out.InProgress:
+		out.Message = "Split in progress"
+	default:
+		out.Message = fmt.Sprintf("Split %s into %d commits",
+			info.ShortHash, len(split))
+	}
+
+	if cfg.JSONOut {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	return formatSplitText(w, out, state)
+}
+
+// commitSplitParts creates the commits for each part of spec on top of
+// parent, plus one for the rest of the original commit if anything is
+// left, and returns them in order. HEAD is left where it is.
+func commitSplitParts(
+	ctx context.Context, cfg Config, executor git.Executor,
+	resolver *pathResolver, parsed *diff.ParsedDiff, parent string,
+	info git.CommitInfo, spec *rebase.SplitSpec,
+) ([]splitCommit, error) {
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newPatchExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	treeOf := func(rev string) (string, error) {
+		if err := tmp.ReadTree(ctx, rev); err != nil {
+			return "", err
+		}
+
+		return tmp.WriteTree(ctx)
+	}
+
+	prevTree, err := treeOf(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	targetTree, err := treeOf(info.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		args  []string
+		split []splitCommit
+	)
+	prev := parent
+
+	commit := func(tree, message string, rest bool) error {
+		message = strings.TrimRight(message, "\n") + "\n"
+
+		hash, err := tmp.CommitTree(ctx, git.CommitTreeOptions{
+			Tree:    tree,
+			Parents: []string{prev},
+			Message: message,
+			Author:  info.Author,
+			Date:    info.Date,
+		})
+		if err != nil {
+			return err
+		}
+
+		subject, _, _ := strings.Cut(message, "\n")
+		split = append(split, splitCommit{
+			Hash:    hash,
+			Subject: subject,
+			Rest:    rest,
+		})
+		prev, prevTree = hash, tree
+
+		return nil
+	}
+
+	for i, part := range spec.Parts {
+		// Each part is built from the parent with its own selections
+		// and those of every part before it.
+		args = append(args, part.Selections...)
+
+		patchBytes, err := selectionPatch(resolver, parsed, args)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i+1, err)
+		}
+
+		if err := tmp.ReadTree(ctx, parent); err != nil {
+			return nil, err
+		}
+
+		if err := applyVerified(ctx, tmp, patchBytes); err != nil {
+			return nil, fmt.Errorf("failed to build part %d: %w",
+				i+1, err)
+		}
+
+		tree, err := tmp.WriteTree(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if tree == prevTree {
+			return nil, fmt.Errorf("part %d selects no changes "+
+				"beyond the parts before it", i+1)
+		}
+
+		if err := commit(tree, part.Message, false); err != nil {
+			return nil, fmt.Errorf("failed to commit part %d: %w",
+				i+1, err)
+		}
+	}
+
+	if prevTree == targetTree {
+		return split, nil
+	}
+
+	message := spec.RestMessage
+	if message == "" {
+		message, err = executor.CommitMessage(ctx, info.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := commit(targetTree, message, true); err != nil {
+		return nil, fmt.Errorf("failed to commit the rest: %w", err)
+	}
+
+	return split, nil
+}
+
+// formatSplitText writes the outcome of split.
+func formatSplitText(
+	w io.Writer, out splitOutput, state *git.RebaseState,
+) error {
+	fmt.Fprintf(w, "%s.\n\n", out.Message)
+
+	for _, c := range out.Commits {
+		suffix := ""
+		if c.Rest {
+			suffix = "  (rest)"
+		}
+
+		fmt.Fprintf(w, "  %s %s%s\n", c.Hash[:7], c.Subject, suffix)
+	}
+
+	if !out.InProgress {
+		return nil
+	}
+
+	fmt.Fprintln(w, "")
+
+	if out.HasConflict {
+		for _, c := range state.Conflicts {
+			fmt.Fprintf(w, "  Conflict: %s\n", c.Path)
+		}
+
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Resolve conflicts, then:")
+		fmt.Fprintln(w, "  hunk rebase continue  # to continue")
+		fmt.Fprintln(w, "  hunk rebase abort     # to abort")
+	} else {
+		fmt.Fprintf(w, "Rebase in progress. %d commits remaining.\n",
+			state.RemainingCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// splitHistory is a branch off main with a "Add everything" commit that
+// adds alpha.txt and beta.txt and changes base.txt, followed by a "Later"
+// commit that adds later.txt.
+var splitHistory = []testutil.FileCommit{
+	{
+		Message: "Add everything\n\nWith a body.",
+		Author:  "Someone Else <else@example.com>",
+		Date:    "2021-05-06T07:08:09+02:00",
+		Files: map[string]string{
+			"alpha.txt": "a1\na2\n",
+			"beta.txt":  "b1\n",
+			"base.txt":  "Y1\nx2\nY3\n",
+		},
+	},
+	{
+		Message: "Later",
+		Files:   map[string]string{"later.txt": "later\n"},
+	},
+}
+
+func TestSplit(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, splitHistory...)
+	tree := strings.TrimSpace(repo.Git("rev-parse", "HEAD^{tree}"))
+	original := strings.TrimSpace(repo.Git("rev-parse", "HEAD~1"))
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "--json", "split", "HEAD~1",
+		"-p", "alpha.txt", "-m", "Add alpha",
+		"-p", "base.txt:1", "-m", "Change base line 1",
+	)
+	require.NoError(t, err, out)
+	require.NoError(t, schema.Validate("split", []byte(out)), out)
+
+	var result splitOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.True(t, result.Success)
+	require.Equal(t, original, result.Original)
+	require.Len(t, result.Commits, 3)
+	require.Equal(t, "Add everything", result.Commits[2].Subject)
+	require.True(t, result.Commits[2].Rest)
+
+	require.Equal(t,
+		"Later\nAdd everything\nChange base line 1\nAdd alpha\n",
+		repo.Git("log", "--format=%s", "main..HEAD"))
+
+	// The end result is unchanged.
+	require.Equal(t, tree,
+		strings.TrimSpace(repo.Git("rev-parse", "HEAD^{tree}")))
+
+	require.Equal(t, "alpha.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD~3"))
+	require.Equal(t, "Y1\nx2\nx3\n", repo.Git("show", "HEAD~2:base.txt"))
+	require.Equal(t, "base.txt\nbeta.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD~1"))
+
+	// The parts keep the original author, and the rest its message.
+	require.Equal(t,
+		"Someone Else <else@example.com> 2021-05-06T07:08:09+02:00\n",
+		repo.Git("log", "-1", "--format=%an <%ae> %aI", "HEAD~3"))
+	require.Equal(t, "Add everything\n\nWith a body.\n\n",
+		repo.Git("log", "-1", "--format=%B", "HEAD~1"))
+}
+
+func TestSplitSpecFile(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, splitHistory...)
+
+	spec := `{
+		"parts": [
+			{"selections": ["beta.txt", "base.txt:@1.1"], "message": "One"},
+			{"selections": ["base.txt:@1.2"], "message": "Two"}
+		],
+		"rest_message": "Three"
+	}`
+	specPath := filepath.Join(t.TempDir(), "split.json")
+	require.NoError(t, os.WriteFile(specPath, []byte(spec), 0o644))
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "split", "HEAD~1", "--spec", specPath,
+	)
+	require.NoError(t, err, out)
+	require.Contains(t, out, "into 3 commits")
+	require.Contains(t, out, "Three  (rest)")
+
+	require.Equal(t, "Later\nThree\nTwo\nOne\n",
+		repo.Git("log", "--format=%s", "main..HEAD"))
+	require.Equal(t, "alpha.txt\n",
+		repo.Git("show", "--name-only", "--format=", "HEAD~1"))
+}
+
+func TestSplitErrors(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t, splitHistory...)
+	head := repo.GetFullHash()
+
+	out, err := runHunkCommand(
+		t, repo.Dir, "split", "HEAD~1",
+		"-p", "alpha.txt", "-m", "One", "-p", "alpha.txt:1", "-m", "Two",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "part 2 selects no changes")
+
+	out, err = runHunkCommand(
+		t, repo.Dir, "split", "HEAD~1", "-p", "alpha.txt",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "each part needs a message")
+
+	out, err = runHunkCommand(
+		t, repo.Dir, "split", "main", "-p", "base.txt", "-m", "One",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "has no parent")
+
+	repo.WriteFile("alpha.txt", "dirty\n")
+	out, err = runHunkCommand(
+		t, repo.Dir, "split", "HEAD~1", "-p", "alpha.txt", "-m", "One",
+	)
+	require.Error(t, err)
+	require.Contains(t, out, "the working tree has changes")
+
+	require.Equal(t, head, repo.GetFullHash())
+}
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -14,7 +13,7 @@ import (
 
 // NewStageCmd creates the stage command.
 func NewStageCmd() *cobra.Command {
-	var dryRun bool
+	var opts stageOptions
 
 	cmd := &cobra.Command{
 		Use:   "stage FILE:LINES [FILE:LINES...]",
@@ -25,9 +24,37 @@ Lines are specified using FILE:LINES syntax where LINES can be:
   - A single line number: main.go:42
   - A range: main.go:10-20
   - Multiple ranges: main.go:10-20,30,40-50
+  - One side only: main.go:+10-12 (additions), main.go:-7 (deletions)
+  - A change block: main.go:@2.1 (hunk 2, block 1)
+  - Every block of a hunk: main.go:@2
 
-Line numbers refer to the NEW file (after changes).
-Use 'hunk diff' to see line numbers.`,
+Line numbers refer to the NEW file (after changes); deleted lines go by
+their old line number. A plain number selects both an addition and a
+deletion with that number, while + and - pick one side.
+
+Use 'hunk diff' to see line numbers, and 'hunk diff --stage-hints' to list
+the change blocks. A change block is a run of changed lines with no
+context between them; blocks and hunks are numbered from 1 within a file.
+
+A FILE without a line spec stages all of its changes. Binary files can
+only be staged this way.
+
+Paths containing colons or unusual characters can be double-quoted, with
+C-style escapes as git prints them: "a:b.go":10 or "caf\303\251.go".
+Arguments after -- are taken as literal paths and staged whole.
+
+--substantive-only leaves out selected changes that only touch
+whitespace, such as reindented lines and added blank lines, so formatter
+churn stays unstaged while the real edits are staged.
+
+Selecting lines of a file whose mode changed (e.g. chmod +x) stages the
+mode change too. Use --content-only to leave the mode out, and --mode FILE
+to stage just the mode change. Symlinks are staged as a whole: any
+selection naming one stages its new target.
+
+--validate CMD runs CMD against the tree the index would hold with the
+selection staged, as 'hunk validate' does, and stages nothing if it
+fails.`,
 		Example: `  # Stage lines 10-20 from main.go
   hunk stage main.go:10-20
 
@@ -37,69 +64,291 @@ Use 'hunk diff' to see line numbers.`,
   # Stage from multiple files
   hunk stage main.go:10-20 utils.go:5-15
 
+  # Stage the first change block of the second hunk
+  hunk stage main.go:@2.1
+
   # Preview what would be staged
-  hunk stage --dry-run main.go:10-20`,
-		Args: cobra.MinimumNArgs(1),
+  hunk stage --dry-run main.go:10-20
+
+  # Stage a binary file
+  hunk stage logo.png
+
+  # Stage the real edits in a file but not its reformatting
+  hunk stage --substantive-only main.go
+
+  # Stage only the executable bit of a script
+  hunk stage --mode run.sh
+
+  # Stage lines of the script but not its mode change
+  hunk stage --content-only run.sh:3-5
+
+  # Stage only if the result still builds
+  hunk stage --validate "go build ./..." main.go:10-20
+
+  # Paths with colons or spaces
+  hunk stage '"notes:v2.txt":3-5' 'my file.go:10'
+  hunk stage -- notes:v2.txt`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStage(cmd.Context(), cmd.OutOrStdout(), args, dryRun)
+			if len(args) == 0 && len(opts.patch.ModePaths) == 0 {
+				return fmt.Errorf(
+					"requires at least one FILE[:LINES] or --mode FILE",
+				)
+			}
+
+			// Arguments after -- are literal paths.
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				opts.patch.Files = append(
+					opts.patch.Files, args[dash:]...,
+				)
+				args = args[:dash]
+			}
+
+			return runStage(
+				cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(),
+				args, opts,
+			)
 		},
 	}
 
 	cmd.Flags().BoolVar(
-		&dryRun, "dry-run", false,
+		&opts.dryRun, "dry-run", false,
 		"show what would be staged without staging",
 	)
+	cmd.Flags().StringArrayVar(
+		&opts.patch.ModePaths, "mode", nil,
+		"stage the mode change of FILE (repeatable)",
+	)
+	cmd.Flags().BoolVar(
+		&opts.patch.ContentOnly, "content-only", false,
+		"stage selected lines without the file's mode change",
+	)
+	cmd.Flags().BoolVar(
+		&opts.patch.SubstantiveOnly, "substantive-only", false,
+		"leave out selected changes that only touch whitespace",
+	)
+	cmd.Flags().StringVar(
+		&opts.validate, "validate", "",
+		"run this command against the result and stage only if it passes",
+	)
 
 	return cmd
 }
 
-func runStage(ctx context.Context, w io.Writer, args []string, dryRun bool) error {
-	// Parse all selections.
-	selections, err := diff.ParseSelections(args)
+// stageOptions holds the flags for the stage command.
+type stageOptions struct {
+	dryRun   bool
+	validate string
+	patch    patch.Options
+}
+
+func runStage(
+	ctx context.Context, w, errW io.Writer, args []string, opts stageOptions,
+) error {
+	selections, err := parseSelectionArgs(args, &opts.patch)
 	if err != nil {
-		return fmt.Errorf("invalid selection: %w", err)
+		return err
 	}
 
 	cfg := getConfig(ctx)
-	executor := git.NewShellExecutor(cfg.WorkDir)
+	executor := newPatchExecutor(cfg)
+
+	parsed, patchBytes, err := buildPatch(
+		ctx, cfg, executor, selections, opts.patch,
+	)
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		// buildPatch has resolved the whole-file paths in place.
+		warnSplitMoves(errW, parsed, selections, opts.patch.Files)
+		fmt.Fprint(w, string(patchBytes))
+
+		return nil
+	}
+
+	if opts.validate != "" {
+		result, err := validateIndex(
+			ctx, cfg, executor, patchBytes, opts.validate,
+		)
+		if err != nil {
+			return err
+		}
+
+		if !result.passed() {
+			fmt.Fprint(errW, result.Output)
+
+			return fmt.Errorf("%w, nothing staged", result.err())
+		}
+	}
+
+	// Apply the patch to the staging area.
+	if err := applyVerified(ctx, executor, patchBytes); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	fmt.Fprintln(w, "Changes staged successfully.")
+
+	return nil
+}
+
+// parseSelectionArgs parses FILE[:LINES] arguments. Arguments without a
+// line spec name whole files and are added to opts.Files.
+func parseSelectionArgs(
+	args []string, opts *patch.Options,
+) ([]*diff.FileSelection, error) {
+	var lineArgs []string
+	for _, arg := range args {
+		path, _, hasLines, err := diff.SplitSelection(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection: %w", err)
+		}
+
+		if hasLines {
+			lineArgs = append(lineArgs, arg)
+		} else {
+			opts.Files = append(opts.Files, path)
+		}
+	}
+
+	selections, err := diff.ParseSelections(lineArgs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selection: %w", err)
+	}
+
+	return selections, nil
+}
+
+// buildPatch generates a patch staging the selections from the unstaged
+// diff of executor's index. Selection paths are resolved against the
+// working directory first.
+func buildPatch(
+	ctx context.Context, cfg Config, executor git.Executor,
+	selections []*diff.FileSelection, opts patch.Options,
+) (*diff.ParsedDiff, []byte, error) {
+	// Paths on the command line are relative to the working directory,
+	// while diffs name files relative to the repository root.
+	resolver, err := newPathResolver(ctx, cfg, executor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := resolveSelections(resolver, selections, &opts); err != nil {
+		return nil, nil, err
+	}
 
 	// Get the current diff.
 	diffText, err := executor.Diff(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	if diffText == "" {
-		return fmt.Errorf("no unstaged changes")
+		return nil, nil, fmt.Errorf("no unstaged changes")
 	}
 
 	parsed, err := diff.Parse(diffText)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	if err := parsed.ResolveBlocks(selections); err != nil {
+		return nil, nil, fmt.Errorf("invalid selection: %w", err)
 	}
 
 	// Generate a patch for the selected lines.
-	patchBytes, err := patch.Generate(parsed, selections)
+	patchBytes, err := patch.GenerateWithOptions(parsed, selections, opts)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	if len(patchBytes) == 0 {
-		return fmt.Errorf("no matching lines found for selection")
+		return nil, nil, fmt.Errorf("no matching lines found for selection")
 	}
 
-	if dryRun {
-		fmt.Fprint(w, string(patchBytes))
+	return parsed, patchBytes, nil
+}
 
-		return nil
+// selectionPatch generates a patch for the selection arguments args from
+// parsed, a diff against which they are resolved. The arguments are parsed
+// afresh on every call, since generation merges selections in place.
+func selectionPatch(
+	resolver *pathResolver, parsed *diff.ParsedDiff, args []string,
+) ([]byte, error) {
+	var opts patch.Options
+	selections, err := parseSelectionArgs(args, &opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply the patch to the staging area.
-	if err := executor.ApplyPatch(ctx, bytes.NewReader(patchBytes)); err != nil {
-		return fmt.Errorf("failed to stage changes: %w", err)
+	if err := resolveSelections(resolver, selections, &opts); err != nil {
+		return nil, err
 	}
 
-	fmt.Fprintln(w, "Changes staged successfully.")
+	return selectedPatch(parsed, selections, opts)
+}
+
+// selectedPatch generates the patch for already resolved selections from
+// parsed, resolving their change blocks first.
+func selectedPatch(
+	parsed *diff.ParsedDiff, selections []*diff.FileSelection,
+	opts patch.Options,
+) ([]byte, error) {
+	if err := parsed.ResolveBlocks(selections); err != nil {
+		return nil, fmt.Errorf("invalid selection: %w", err)
+	}
+
+	patchBytes, err := patch.GenerateWithOptions(parsed, selections, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patchBytes) == 0 {
+		return nil, fmt.Errorf("no matching lines found for selection")
+	}
+
+	return patchBytes, nil
+}
+
+// resolveSelections rewrites the paths of selections and of whole-file and
+// mode options to be relative to the repository root.
+func resolveSelections(
+	resolver *pathResolver, selections []*diff.FileSelection,
+	opts *patch.Options,
+) error {
+	for _, sel := range selections {
+		path, err := resolver.toRepo(sel.Path)
+		if err != nil {
+			return err
+		}
+		sel.Path = path
+	}
+
+	for _, paths := range [][]string{opts.Files, opts.ModePaths} {
+		for i, p := range paths {
+			path, err := resolver.toRepo(p)
+			if err != nil {
+				return err
+			}
+			paths[i] = path
+		}
+	}
 
 	return nil
 }
+
+// warnSplitMoves warns about moved blocks where the selection covers only
+// the deleted or only the added side. Files staged whole count as fully
+// selected. Staging half of a move leaves the code duplicated or missing in
+// the index.
+func warnSplitMoves(
+	w io.Writer, parsed *diff.ParsedDiff, selections []*diff.FileSelection,
+	files []string,
+) {
+	for _, m := range parsed.SplitMoves(selections, files) {
+		fmt.Fprintf(w, "warning: selection includes only one side of "+
+			"moved block %d (%s:%d-%d -> %s:%d-%d)\n", m.ID,
+			m.OldPath, m.OldStart, m.OldEnd,
+			m.NewPath, m.NewStart, m.NewEnd)
+	}
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/plan"
+)
+
+// symbolSelections returns args with each selection naming a Go symbol,
+// such as main.go:runServer, replaced by the lines of its declaration: the
+// deletions within it as of head and the additions within it in the
+// working tree. Other selections are returned unchanged.
+func symbolSelections(
+	ctx context.Context, executor git.Executor, resolver *pathResolver,
+	parsed *diff.ParsedDiff, head string, args []string,
+) ([]string, error) {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		path, symbol, ok := plan.SymbolSelection(arg)
+		if !ok {
+			result = append(result, arg)
+
+			continue
+		}
+
+		sides, err := symbolLines(
+			ctx, executor, resolver, parsed, head, path, symbol,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(
+			result, diff.QuoteSelectionPath(path)+":"+sides,
+		)
+	}
+
+	return result, nil
+}
+
+// symbolLines returns the side-only line ranges, such as "-4-9,+4-12", of
+// symbol's declaration in path before and after its changes since head.
+func symbolLines(
+	ctx context.Context, executor git.Executor, resolver *pathResolver,
+	parsed *diff.ParsedDiff, head, path, symbol string,
+) (string, error) {
+	repoPath, err := resolver.toRepo(path)
+	if err != nil {
+		return "", err
+	}
+
+	var file *diff.FileDiff
+	for _, f := range parsed.AllFiles() {
+		if f.NewName == repoPath || f.IsDeleted && f.OldName == repoPath {
+			file = f
+
+			break
+		}
+	}
+
+	if file == nil {
+		return "", fmt.Errorf("no changes for %s", path)
+	}
+
+	if !strings.HasSuffix(repoPath, ".go") {
+		return "", fmt.Errorf("cannot select symbol %s: %s is not a "+
+			"Go file", symbol, path)
+	}
+
+	var sides []string
+
+	// The declaration as of head holds the deletions.
+	if !file.IsNew {
+		src, err := executor.FileContent(ctx, head, file.OldName)
+		if err != nil {
+			return "", err
+		}
+
+		start, end, found, err := declLines([]byte(src), symbol)
+		if err != nil {
+			return "", fmt.Errorf("cannot select symbol %s: %s at "+
+				"HEAD: %w", symbol, path, err)
+		}
+
+		if found {
+			sides = append(sides, fmt.Sprintf("-%d-%d", start, end))
+		}
+	}
+
+	// The declaration in the working tree holds the additions.
+	if !file.IsDeleted {
+		src, err := os.ReadFile(filepath.Join(
+			resolver.root, filepath.FromSlash(file.NewName),
+		))
+		if err != nil {
+			return "", err
+		}
+
+		start, end, found, err := declLines(src, symbol)
+		if err != nil {
+			return "", fmt.Errorf("cannot select symbol %s: %s: %w",
+				symbol, path, err)
+		}
+
+		if found {
+			sides = append(sides, fmt.Sprintf("+%d-%d", start, end))
+		}
+	}
+
+	if len(sides) == 0 {
+		return "", fmt.Errorf("symbol %s not found in %s", symbol, path)
+	}
+
+	return strings.Join(sides, ","), nil
+}
+
+// declLines returns the first and last line of the top-level declaration
+// of name in the Go source src, including its doc comment and the blank
+// lines after it. A method is named Type.Method, and a name declared in a
+// grouped var, const or type declaration covers only its own spec. found
+// is false if src declares no such name.
+func declLines(src []byte, name string) (start, end int, found bool,
+	err error) {
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	node, doc := findDecl(file, name)
+	if node == nil {
+		return 0, 0, false, nil
+	}
+
+	first := node.Pos()
+	if doc != nil {
+		first = doc.Pos()
+	}
+
+	// Take the blank lines that separate it from the next declaration
+	// too, which the diff of an added or removed declaration holds. The
+	// text after the final newline is no line.
+	lines := strings.Split(string(src), "\n")
+	end = fset.Position(node.End()).Line
+	for end < len(lines)-1 && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+
+	return fset.Position(first).Line, end, true, nil
+}
+
+// findDecl returns the top-level declaration of name in file with its doc
+// comment, or nil if there is none.
+func findDecl(file *ast.File, name string) (ast.Node, *ast.CommentGroup) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if funcName(d) == name {
+				return d, d.Doc
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if !specDeclares(spec, name) {
+					continue
+				}
+
+				// A lone spec without parentheses is the whole
+				// declaration.
+				if !d.Lparen.IsValid() {
+					return d, d.Doc
+				}
+
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					return s, s.Doc
+
+				case *ast.ValueSpec:
+					return s, s.Doc
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// funcName returns the name of a function, or Type.Method for a method.
+func funcName(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+
+	recv := d.Recv.List[0].Type
+	for {
+		switch r := recv.(type) {
+		case *ast.StarExpr:
+			recv = r.X
+
+		case *ast.IndexExpr:
+			recv = r.X
+
+		case *ast.IndexListExpr:
+			recv = r.X
+
+		case *ast.Ident:
+			return r.Name + "." + d.Name.Name
+
+		default:
+			return d.Name.Name
+		}
+	}
+}
+
+// specDeclares reports whether a var, const or type spec declares name.
+func specDeclares(spec ast.Spec, name string) bool {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Name.Name == name
+
+	case *ast.ValueSpec:
+		for _, ident := range s.Names {
+			if ident.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
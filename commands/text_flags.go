@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/output"
+	"github.com/spf13/cobra"
+)
+
+// Text format names accepted by --format.
+const (
+	formatText       = "text"
+	formatSideBySide = "side-by-side"
+	formatCompact    = "compact"
+)
+
+// textFlags holds the flags that control human-readable diff rendering.
+// They are shared by every command that prints a diff as text.
+type textFlags struct {
+	format         string
+	width          int
+	color          string
+	oldLineNumbers bool
+	noContext      bool
+	section        bool
+	relative       bool
+	compactContext bool
+}
+
+// register adds the text rendering flags to cmd.
+func (f *textFlags) register(cmd *cobra.Command) {
+	defaults := output.DefaultTextOptions()
+
+	cmd.Flags().StringVar(
+		&f.format, "format", formatText,
+		"text layout: text, side-by-side or compact",
+	)
+	cmd.Flags().IntVar(
+		&f.width, "width", 0,
+		"line width for side-by-side output (default: terminal width)",
+	)
+
+	cmd.Flags().StringVar(
+		&f.color, "color", string(output.ColorAuto),
+		"colorize output: auto, always or never",
+	)
+	cmd.Flags().BoolVar(
+		&f.oldLineNumbers, "old-line-numbers", defaults.OldLineNumbers,
+		"show old-file line numbers next to new-file ones",
+	)
+	cmd.Flags().BoolVar(
+		&f.noContext, "no-context", defaults.HideContext,
+		"hide unchanged context lines",
+	)
+	cmd.Flags().BoolVar(
+		&f.section, "section", defaults.Section,
+		"show the enclosing section after each hunk header",
+	)
+	cmd.Flags().BoolVar(
+		&f.compactContext, "compact-context", false,
+		"with --format=compact, show a line of context around each change",
+	)
+	cmd.Flags().BoolVar(
+		&f.relative, "relative-paths", false,
+		"show file paths relative to the current directory",
+	)
+}
+
+// options resolves the flags into TextOptions for output written to w.
+// Color is only enabled in auto mode if w is a terminal.
+func (f *textFlags) options(w io.Writer) (output.TextOptions, error) {
+	mode, err := output.ParseColorMode(f.color)
+	if err != nil {
+		return output.TextOptions{}, err
+	}
+
+	opts := output.DefaultTextOptions()
+	opts.Color = mode.Enabled(w)
+	opts.OldLineNumbers = f.oldLineNumbers
+	opts.HideContext = f.noContext
+	opts.Section = f.section
+	opts.CompactContext = f.compactContext
+
+	opts.Width = f.width
+	if opts.Width <= 0 {
+		opts.Width = output.TerminalWidth(w)
+	}
+
+	switch f.format {
+	case "", formatText, formatSideBySide:
+	case formatCompact:
+		// Blocks only carry the context asked for.
+		opts.HideContext = !f.compactContext
+	default:
+		return output.TextOptions{}, fmt.Errorf(
+			"invalid format %q: expected %s, %s or %s",
+			f.format, formatText, formatSideBySide, formatCompact,
+		)
+	}
+
+	return opts, nil
+}
+
+// relativeTo returns the directory file paths are shown relative to: the
+// working directory prefix with --relative-paths, else the repository root.
+func (f *textFlags) relativeTo(prefix string) string {
+	if f.relative {
+		return prefix
+	}
+
+	return ""
+}
+
+// render writes parsed in the selected text layout.
+func (f *textFlags) render(
+	w io.Writer, parsed *diff.ParsedDiff, opts output.TextOptions,
+) error {
+	switch f.format {
+	case formatSideBySide:
+		return output.FormatSideBySide(w, parsed, opts)
+	case formatCompact:
+		return output.FormatCompact(w, parsed, opts)
+	}
+
+	return output.FormatText(w, parsed, opts)
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/roasbeef/hunk/git"
+	"github.com/roasbeef/hunk/schema"
+	"github.com/spf13/cobra"
+)
+
+// validateOutput is the JSON output for validate.
+type validateOutput struct {
+	SchemaVersion int    `json:"schema_version"`
+	Success       bool   `json:"success"`
+	Command       string `json:"command"`
+	Tree          string `json:"tree"`
+	ExitCode      int    `json:"exit_code"`
+	Output        string `json:"output"`
+}
+
+// validationJSON is the result of running a command against a tree, as
+// reported by validate and by commit --validate.
+type validationJSON struct {
+	Command  string `json:"command"`
+	Tree     string `json:"tree"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// passed reports whether the command exited successfully.
+func (v *validationJSON) passed() bool {
+	return v.ExitCode == 0
+}
+
+// err returns the error for a failed validation.
+func (v *validationJSON) err() error {
+	return fmt.Errorf("validation failed: %q exited with status %d",
+		v.Command, v.ExitCode)
+}
+
+// validateOptions holds the flags for the validate command.
+type validateOptions struct {
+	run string
+}
+
+// NewValidateCmd creates the validate command.
+func NewValidateCmd() *cobra.Command {
+	var opts validateOptions
+
+	cmd := &cobra.Command{
+		Use:   "validate --run CMD",
+		Short: "Run a command against only what is staged",
+		Long: `Run a command, such as a build or the tests, against the staged tree.
+
+A commit holds what is staged, not what is in the working tree, so a
+build that passes locally can still fail once committed if it depends on
+unstaged changes or untracked files. Validate writes the index out as a
+tree, checks it out into a temporary worktree, and runs CMD there with
+sh -c, from the same subdirectory hunk was run in. The worktree is
+removed afterwards; the working tree and index are never touched.
+
+The command's combined output and exit status are reported, and validate
+fails if the command does. 'hunk stage --validate CMD' and 'hunk commit
+--validate CMD' run the same check before they change anything.`,
+		Example: `  # Do the staged changes build and pass the tests on their own?
+  hunk validate --run "go build ./... && go test ./..."
+
+  # Exit status and output as JSON
+  hunk --json validate --run "make check"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Later failures aren't usage errors.
+			cmd.SilenceUsage = true
+
+			return runValidate(
+				cmd.Context(), cmd.OutOrStdout(), opts,
+			)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.run, "run", "", "command to run against the staged tree",
+	)
+	_ = cmd.MarkFlagRequired("run")
+
+	return cmd
+}
+
+func runValidate(
+	ctx context.Context, w io.Writer, opts validateOptions,
+) error {
+	cfg := getConfig(ctx)
+	executor := newDiffExecutor(cfg)
+
+	tree, err := executor.WriteTree(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := validateTree(ctx, cfg, tree, opts.run)
+	if err != nil {
+		return err
+	}
+
+	if cfg.JSONOut {
+		out := validateOutput{
+			SchemaVersion: schema.Version,
+			Success:       result.passed(),
+			Command:       result.Command,
+			Tree:          result.Tree,
+			ExitCode:      result.ExitCode,
+			Output:        result.Output,
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprint(w, result.Output)
+	}
+
+	if !result.passed() {
+		return result.err()
+	}
+
+	if !cfg.JSONOut {
+		fmt.Fprintln(w, "Validation passed.")
+	}
+
+	return nil
+}
+
+// validateIndex runs command against the tree executor's index would hold
+// with patchBytes applied, without changing that index.
+func validateIndex(
+	ctx context.Context, cfg Config, executor git.Executor,
+	patchBytes []byte, command string,
+) (*validationJSON, error) {
+	tree, err := executor.WriteTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "hunk-index-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := newDiffExecutor(cfg)
+	tmp.IndexFile = filepath.Join(tmpDir, "index")
+
+	if err := tmp.ReadTree(ctx, tree); err != nil {
+		return nil, err
+	}
+
+	if err := applyVerified(ctx, tmp, patchBytes); err != nil {
+		return nil, err
+	}
+
+	tree, err = tmp.WriteTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateTree(ctx, cfg, tree, command)
+}
+
+// validateTree checks tree out into a temporary worktree and runs command
+// there. A command that runs but fails is reported in the result, not as an
+// error.
+func validateTree(
+	ctx context.Context, cfg Config, tree, command string,
+) (*validationJSON, error) {
+	executor := newDiffExecutor(cfg)
+
+	prefix, err := workDirPrefix(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+
+	// A worktree checks out a commit, so the tree gets a throwaway one on
+	// top of HEAD. Nothing refers to it once the worktree is gone.
+	var parents []string
+	if head, err := executor.RevParse(ctx, "HEAD"); err == nil {
+		parents = []string{head}
+	}
+
+	commit, err := executor.CommitTree(ctx, git.CommitTreeOptions{
+		Tree:    tree,
+		Parents: parents,
+		Message: "hunk validate\n",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "hunk-validate-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	worktree := filepath.Join(tmpDir, "tree")
+	if err := executor.AddWorktree(ctx, worktree, commit); err != nil {
+		return nil, err
+	}
+	defer func() {
+		cleanupCtx := context.WithoutCancel(ctx)
+		_ = executor.RemoveWorktree(cleanupCtx, worktree)
+	}()
+
+	// Run from the same subdirectory as hunk, if the tree has it.
+	dir := filepath.Join(worktree, prefix)
+	if _, err := os.Stat(dir); err != nil {
+		dir = worktree
+	}
+
+	var output bytes.Buffer
+	run := exec.CommandContext(ctx, "sh", "-c", command)
+	run.Dir = dir
+	run.Env = validateEnv()
+	run.Stdout = &output
+	run.Stderr = &output
+
+	result := &validationJSON{Command: command, Tree: tree}
+
+	var exitErr *exec.ExitError
+	switch err := run.Run(); {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+
+	case err != nil:
+		return nil, fmt.Errorf("failed to run %q: %w", command, err)
+	}
+
+	result.Output = output.String()
+
+	return result, nil
+}
+
+// validateEnv returns our environment without the variables that would
+// point git commands run by the validation back at the real repository's
+// index or working tree.
+func validateEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case "GIT_DIR", "GIT_WORK_TREE", "GIT_INDEX_FILE":
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	return env
+}
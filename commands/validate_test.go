@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/roasbeef/hunk/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// runValidated runs a hunk command with --json in dir, checks its output
+// against the command's schema, and decodes it into out. It returns the
+// command's error.
+func runValidated(
+	t *testing.T, dir, command string, out any, args ...string,
+) error {
+	t.Helper()
+
+	rootCmd := NewRootCmd()
+	rootCmd.SetArgs(append([]string{"--dir", dir, "--json"}, args...))
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	err := rootCmd.Execute()
+
+	require.NoError(t, schema.Validate(command, stdout.Bytes()),
+		stdout.String())
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), out))
+
+	return err
+}
+
+// setupValidateRepo creates a repository whose app.txt reads "broken" in
+// HEAD and "fixed" in the working tree, with an untracked extra.txt.
+func setupValidateRepo(t *testing.T) *testutil.GitTestRepo {
+	t.Helper()
+
+	repo := testutil.NewGitTestRepo(t)
+
+	repo.WriteFile("app.txt", "broken\n")
+	repo.WriteFile("sub/local.txt", "local\n")
+	repo.CommitAll("Base commit")
+
+	repo.WriteFile("app.txt", "fixed\n")
+	repo.WriteFile("extra.txt", "untracked\n")
+
+	return repo
+}
+
+// checkFixed passes only when app.txt is fixed and there's no extra.txt,
+// so it fails on the working tree and on HEAD but not once app.txt is
+// staged.
+const checkFixed = "grep fixed app.txt && test ! -e extra.txt"
+
+func TestValidate(t *testing.T) {
+	repo := setupValidateRepo(t)
+
+	var out validateOutput
+	err := runValidated(t, repo.Dir, "validate", &out,
+		"validate", "--run", checkFixed)
+	require.ErrorContains(t, err, "validation failed")
+	require.False(t, out.Success)
+	require.Equal(t, 1, out.ExitCode)
+	require.Equal(t, checkFixed, out.Command)
+	require.Equal(t, strings.TrimSpace(repo.Git("write-tree")), out.Tree)
+
+	repo.StageFile("app.txt")
+
+	out = validateOutput{}
+	err = runValidated(t, repo.Dir, "validate", &out,
+		"validate", "--run", checkFixed)
+	require.NoError(t, err)
+	require.True(t, out.Success)
+	require.Equal(t, "fixed\n", out.Output)
+
+	// The command runs from the same subdirectory as hunk.
+	out = validateOutput{}
+	err = runValidated(t, filepath.Join(repo.Dir, "sub"), "validate",
+		&out, "validate", "--run", "cat local.txt")
+	require.NoError(t, err)
+	require.Equal(t, "local\n", out.Output)
+
+	// Nothing is left behind, and the index and working tree are as
+	// they were.
+	require.Equal(t, 1, strings.Count(repo.Git("worktree", "list"), "\n"))
+	require.Equal(t, "M  app.txt\n?? extra.txt\n",
+		repo.Git("status", "--porcelain"))
+}
+
+func TestStageValidate(t *testing.T) {
+	repo := setupValidateRepo(t)
+	repo.WriteFile("sub/local.txt", "changed\n")
+
+	// Staging only the other file leaves app.txt broken.
+	text, err := runHunkCommand(
+		t, repo.Dir, "stage", "--validate", checkFixed, "sub/local.txt",
+	)
+	require.Error(t, err)
+	require.Contains(t, text, "nothing staged")
+	require.Empty(t, repo.DiffCached())
+
+	text, err = runHunkCommand(
+		t, repo.Dir, "stage", "--validate", checkFixed, "app.txt:1",
+	)
+	require.NoError(t, err, text)
+	require.Equal(t, "fixed\n", repo.Git("show", ":app.txt"))
+}
+
+func TestCommitValidate(t *testing.T) {
+	repo := setupValidateRepo(t)
+	head := repo.GetFullHash()
+
+	var out commitOutput
+	err := runValidated(t, repo.Dir, "commit", &out,
+		"commit", "--validate", checkFixed, "--allow-empty", "-m", "x")
+	require.ErrorContains(t, err, "validation failed")
+	require.False(t, out.Success)
+	require.Nil(t, out.Commit)
+	require.Equal(t, 1, out.Validation.ExitCode)
+	require.Equal(t, head, repo.GetFullHash())
+
+	// Selected lines are validated as they will be committed.
+	out = commitOutput{}
+	err = runValidated(t, repo.Dir, "commit", &out,
+		"commit", "--validate", checkFixed, "app.txt", "-m", "Fix app")
+	require.NoError(t, err)
+	require.True(t, out.Success)
+	require.Equal(t, 0, out.Validation.ExitCode)
+	require.Equal(t, out.Commit.Tree, out.Validation.Tree)
+	require.Equal(t, "Fix app", out.Commit.Subject)
+}
@@ -0,0 +1,234 @@
+package diff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// binaryPatchMarker starts the body of a binary patch in git's diff output.
+const binaryPatchMarker = "GIT binary patch"
+
+// base85Alphabet is the character set git uses to encode binary patches.
+const base85Alphabet = "0123456789" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"!#$%&()*+-;<=>?@^_`{|}~"
+
+// binarySection is one direction of a binary patch: either the literal
+// content or a delta against the other side.
+type binarySection struct {
+	// kind is "literal" or "delta".
+	kind string
+
+	// size is the inflated size of the literal content or delta.
+	size int64
+
+	// lines are the encoded data lines.
+	lines []string
+}
+
+// binarySizes are the sizes in bytes of a binary file before and after a
+// change.
+type binarySizes struct {
+	old int64
+	new int64
+}
+
+// SetBinarySizes records the sizes of a binary file whose diff was taken
+// without --binary, as looked up from the objects or files themselves.
+func (f *FileDiff) SetBinarySizes(oldSize, newSize int64) {
+	f.sizes = &binarySizes{old: oldSize, new: newSize}
+}
+
+// BinarySizes returns the sizes in bytes of a binary file before and after
+// the change. They are read from the binary patch, or else are those set
+// with SetBinarySizes. ok is false when neither is available.
+func (f *FileDiff) BinarySizes() (oldSize, newSize int64, ok bool) {
+	if f.BinaryPatch == "" {
+		if f.sizes == nil {
+			return 0, 0, false
+		}
+
+		return f.sizes.old, f.sizes.new, true
+	}
+
+	sections, err := parseBinarySections(f.BinaryPatch)
+	if err != nil || len(sections) == 0 {
+		return 0, 0, false
+	}
+
+	// The forward section produces the new content. A delta's header
+	// also records the size of the content it applies to.
+	forward := sections[0]
+	switch forward.kind {
+	case "literal":
+		newSize = forward.size
+
+	case "delta":
+		src, dst, err := deltaSizes(forward.lines)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		return src, dst, true
+	}
+
+	// The reverse section, if any, produces the old content.
+	if len(sections) < 2 {
+		return 0, newSize, f.IsNew
+	}
+
+	reverse := sections[1]
+	switch reverse.kind {
+	case "literal":
+		oldSize = reverse.size
+
+	case "delta":
+		_, dst, err := deltaSizes(reverse.lines)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		oldSize = dst
+	}
+
+	return oldSize, newSize, true
+}
+
+// parseBinarySections splits a binary patch body into its forward and
+// reverse sections.
+func parseBinarySections(body string) ([]binarySection, error) {
+	var (
+		sections []binarySection
+		cur      *binarySection
+	)
+
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case line == "":
+			if cur != nil {
+				sections = append(sections, *cur)
+				cur = nil
+			}
+
+		case cur == nil:
+			kind, sizeStr, ok := strings.Cut(line, " ")
+			if !ok || (kind != "literal" && kind != "delta") {
+				return nil, fmt.Errorf(
+					"invalid binary patch section %q", line,
+				)
+			}
+
+			size, err := strconv.ParseInt(sizeStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"invalid binary patch size %q", line,
+				)
+			}
+
+			cur = &binarySection{kind: kind, size: size}
+
+		default:
+			cur.lines = append(cur.lines, line)
+		}
+	}
+
+	if cur != nil {
+		sections = append(sections, *cur)
+	}
+
+	return sections, nil
+}
+
+// deltaSizes decodes the header of a git delta, which starts with the sizes
+// of the source and target content as little-endian base-128 varints.
+func deltaSizes(lines []string) (src, dst int64, err error) {
+	data, err := decodeBase85Lines(lines)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delta: %w", err)
+	}
+	defer zr.Close()
+
+	// The header is at most two 10-byte varints.
+	header := make([]byte, 2*binary.MaxVarintLen64)
+	n, err := io.ReadFull(zr, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, 0, fmt.Errorf("invalid delta: %w", err)
+	}
+	r := bytes.NewReader(header[:n])
+
+	srcSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delta header: %w", err)
+	}
+
+	dstSize, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delta header: %w", err)
+	}
+
+	return int64(srcSize), int64(dstSize), nil
+}
+
+// decodeBase85Lines decodes git's base85 data lines. Each line starts with
+// a character giving its decoded length: 'A'-'Z' for 1-26 bytes and 'a'-'z'
+// for 27-52.
+func decodeBase85Lines(lines []string) ([]byte, error) {
+	var out []byte
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		var n int
+		switch c := line[0]; {
+		case c >= 'A' && c <= 'Z':
+			n = int(c-'A') + 1
+		case c >= 'a' && c <= 'z':
+			n = int(c-'a') + 27
+		default:
+			return nil, fmt.Errorf("invalid base85 line length %q", c)
+		}
+
+		enc := line[1:]
+		if len(enc)%5 != 0 {
+			return nil, fmt.Errorf("invalid base85 line %q", line)
+		}
+
+		var decoded []byte
+		for i := 0; i < len(enc); i += 5 {
+			var acc uint32
+			for _, c := range []byte(enc[i : i+5]) {
+				v := strings.IndexByte(base85Alphabet, c)
+				if v < 0 {
+					return nil, fmt.Errorf(
+						"invalid base85 character %q", c,
+					)
+				}
+				acc = acc*85 + uint32(v)
+			}
+
+			decoded = binary.BigEndian.AppendUint32(decoded, acc)
+		}
+
+		if n > len(decoded) {
+			return nil, fmt.Errorf("short base85 line %q", line)
+		}
+
+		out = append(out, decoded[:n]...)
+	}
+
+	return out, nil
+}
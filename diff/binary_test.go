@@ -0,0 +1,74 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinarySizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		diffText string
+		wantOld  int64
+		wantNew  int64
+		wantOK   bool
+	}{
+		{
+			name: "literal",
+			diffText: `diff --git a/a.bin b/a.bin
+index 96db3e1c616a9650209b6a2491a6a663261c7edf..9f515e09a5927af627893671206eade3db75cce0 100644
+GIT binary patch
+literal 8
+PcmYdHN@hq&O-ly=3<3hZ
+
+literal 7
+OcmYdHN@hq&O#=W4MFLg;
+
+`,
+			wantOld: 7,
+			wantNew: 8,
+			wantOK:  true,
+		},
+		{
+			name: "delta",
+			diffText: `diff --git a/big.bin b/big.bin
+index 6a99293a0f2f5acc7374723c2c768b17e70db83a..e5f94e5a907153c9ab74af6b6bae7c21e6411bd4 100644
+GIT binary patch
+delta 9
+Qcmcb@eU*E|6;{S702MU@!~g&Q
+
+delta 7
+Ocmcc0eT93&6;=QaDFaCW
+
+`,
+			wantOld: 1492,
+			wantNew: 1493,
+			wantOK:  true,
+		},
+		{
+			name: "no binary patch",
+			diffText: `diff --git a/a.bin b/a.bin
+index 96db3e1..9f515e0 100644
+Binary files a/a.bin and b/a.bin differ
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := diff.Parse(tt.diffText)
+			require.NoError(t, err)
+			require.Equal(t, 1, parsed.FileCount())
+
+			file := parsed.AllFiles()[0]
+			require.True(t, file.IsBinary)
+
+			oldSize, newSize, ok := file.BinarySizes()
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantOld, oldSize)
+			require.Equal(t, tt.wantNew, newSize)
+		})
+	}
+}
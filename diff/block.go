@@ -0,0 +1,262 @@
+package diff
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BlockRef addresses a change block, or a whole hunk, by number within its
+// file. It is written "@HUNK.BLOCK", or "@HUNK" for every block of a hunk.
+type BlockRef struct {
+	Hunk  int // 1-based hunk number within the file.
+	Block int // 1-based block number within the hunk, 0 for all.
+}
+
+// String returns the reference in selector syntax, e.g. "@2.1".
+func (r BlockRef) String() string {
+	if r.Block == 0 {
+		return fmt.Sprintf("@%d", r.Hunk)
+	}
+
+	return fmt.Sprintf("@%d.%d", r.Hunk, r.Block)
+}
+
+// parseBlockRef parses a block reference like "@2.1" or "@2".
+func parseBlockRef(s string) (BlockRef, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(s), "@")
+	if !ok {
+		return BlockRef{}, fmt.Errorf("block reference must start with @")
+	}
+
+	hunkSpec, blockSpec, hasBlock := strings.Cut(spec, ".")
+
+	hunk, err := strconv.Atoi(hunkSpec)
+	if err != nil || hunk < 1 {
+		return BlockRef{}, fmt.Errorf("invalid hunk number %q", hunkSpec)
+	}
+
+	ref := BlockRef{Hunk: hunk}
+	if hasBlock {
+		ref.Block, err = strconv.Atoi(blockSpec)
+		if err != nil || ref.Block < 1 {
+			return BlockRef{}, fmt.Errorf(
+				"invalid block number %q", blockSpec,
+			)
+		}
+	}
+
+	return ref, nil
+}
+
+// ChangeBlock is a run of consecutive added and deleted lines within a hunk,
+// with no context lines between them. Blocks are the smallest units that
+// stage hints and block selectors address.
+type ChangeBlock struct {
+	// Ref is the block's number within its file.
+	Ref BlockRef
+
+	// Section is the enclosing section of the block's hunk.
+	Section string
+
+	// Start is the index of the block's first line in its hunk's Lines.
+	Start int
+
+	// Lines are the block's changed lines.
+	Lines []DiffLine
+}
+
+// Selection returns a selection of exactly the block's lines of path: its
+// additions by new line number and its deletions by old line number.
+func (b ChangeBlock) Selection(path string) *FileSelection {
+	var added, deleted []int
+	for _, line := range b.Lines {
+		switch line.Op {
+		case OpAdd:
+			added = append(added, line.NewLineNum)
+		case OpDelete:
+			deleted = append(deleted, line.OldLineNum)
+		}
+	}
+
+	return &FileSelection{
+		Path:    path,
+		Added:   toRanges(added),
+		Deleted: toRanges(deleted),
+	}
+}
+
+// BlockSpec returns a LINES spec that selects exactly block, one of f's
+// blocks. It is written with plain line numbers, as the diff shows them,
+// unless one of those would also select a line of another block, e.g. an
+// addition elsewhere whose new line number is one of the block's deleted
+// line numbers. Then the block's lines are written with their sides, as in
+// "+3-4,-7".
+func (f *FileDiff) BlockSpec(block ChangeBlock) string {
+	sel := block.Selection("")
+
+	var ambiguous bool
+	for h, hunk := range f.Hunks {
+		for i, line := range hunk.Lines {
+			inBlock := h == block.Ref.Hunk-1 && i >= block.Start &&
+				i < block.Start+len(block.Lines)
+			if inBlock || !line.IsChange() {
+				continue
+			}
+
+			// Numbers are unique on each side, so only the other
+			// side can collide.
+			other := sel.Added
+			if line.Op == OpAdd {
+				other = sel.Deleted
+			}
+
+			num := line.EffectiveLineNum()
+			ambiguous = ambiguous || rangesContain(other, num)
+		}
+	}
+
+	var parts []string
+	if !ambiguous {
+		var nums []int
+		for _, line := range block.Lines {
+			nums = append(nums, line.EffectiveLineNum())
+		}
+
+		for _, r := range toRanges(nums) {
+			parts = append(parts, r.String())
+		}
+
+		return strings.Join(parts, ",")
+	}
+
+	for _, r := range sel.Added {
+		parts = append(parts, "+"+r.String())
+	}
+	for _, r := range sel.Deleted {
+		parts = append(parts, "-"+r.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// toRanges returns line numbers as sorted, merged ranges.
+func toRanges(nums []int) []LineRange {
+	slices.Sort(nums)
+	nums = slices.Compact(nums)
+
+	var ranges []LineRange
+	for _, n := range nums {
+		if last := len(ranges) - 1; last >= 0 && ranges[last].End == n-1 {
+			ranges[last].End = n
+
+			continue
+		}
+
+		ranges = append(ranges, LineRange{Start: n, End: n})
+	}
+
+	return ranges
+}
+
+// Stats returns the number of added and deleted lines in the block.
+func (b ChangeBlock) Stats() (added, deleted int) {
+	for _, line := range b.Lines {
+		switch line.Op {
+		case OpAdd:
+			added++
+		case OpDelete:
+			deleted++
+		}
+	}
+
+	return added, deleted
+}
+
+// Blocks returns an iterator over the change blocks of every hunk, in order.
+func (f *FileDiff) Blocks() iter.Seq[ChangeBlock] {
+	return func(yield func(ChangeBlock) bool) {
+		for h, hunk := range f.Hunks {
+			index := 0
+			lines := hunk.Lines
+
+			for start := 0; start < len(lines); {
+				if !lines[start].IsChange() {
+					start++
+
+					continue
+				}
+
+				end := start
+				for end < len(lines) && lines[end].IsChange() {
+					end++
+				}
+
+				index++
+				block := ChangeBlock{
+					Ref:     BlockRef{Hunk: h + 1, Block: index},
+					Section: hunk.Section,
+					Start:   start,
+					Lines:   lines[start:end],
+				}
+				if !yield(block) {
+					return
+				}
+
+				start = end
+			}
+		}
+	}
+}
+
+// ResolveBlocks replaces the block references of each selection with the
+// exact lines of the blocks they name in d, kept apart by side so that a
+// block never selects lines of another with the same numbers. Selections
+// must be resolved before their lines are looked up, since Selects only
+// checks line numbers.
+func (d *ParsedDiff) ResolveBlocks(selections []*FileSelection) error {
+	for _, sel := range selections {
+		if len(sel.Blocks) == 0 {
+			continue
+		}
+
+		file := d.FileByPath(sel.Path)
+		if file == nil {
+			return fmt.Errorf("no changes for %s", sel.Path)
+		}
+
+		for _, ref := range sel.Blocks {
+			if ref.Hunk > len(file.Hunks) {
+				return fmt.Errorf("%s has no hunk %d (%d hunks)",
+					sel.Path, ref.Hunk, len(file.Hunks))
+			}
+
+			var found bool
+			for block := range file.Blocks() {
+				if block.Ref.Hunk != ref.Hunk ||
+					ref.Block != 0 && block.Ref.Block != ref.Block {
+
+					continue
+				}
+
+				exact := block.Selection(sel.Path)
+				sel.Added = append(sel.Added, exact.Added...)
+				sel.Deleted = append(
+					sel.Deleted, exact.Deleted...,
+				)
+				found = true
+			}
+
+			if !found {
+				return fmt.Errorf("%s has no change block %s",
+					sel.Path, ref)
+			}
+		}
+
+		sel.Blocks = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/stretchr/testify/require"
+)
+
+// blockTestDiff has two hunks; the first holds two change blocks.
+const blockTestDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,7 +1,7 @@ package main
+ one
+-two
++TWO
+ three
+ four
+-five
+-six
++FIVE
+ seven
+@@ -20,3 +19,4 @@ func main() {
+ twenty
++added
+ twentyone
+`
+
+func TestFileDiffBlocks(t *testing.T) {
+	parsed, err := diff.Parse(blockTestDiff)
+	require.NoError(t, err)
+
+	file := parsed.FileByPath("main.go")
+
+	var blocks []diff.ChangeBlock
+	for b := range file.Blocks() {
+		blocks = append(blocks, b)
+	}
+	require.Len(t, blocks, 3)
+
+	require.Equal(t, diff.BlockRef{Hunk: 1, Block: 2}, blocks[1].Ref)
+	require.Equal(t, "@1.2", blocks[1].Ref.String())
+	require.Equal(t, 5, blocks[1].Start)
+	require.Equal(t, "package main", blocks[1].Section)
+	require.Equal(t, &diff.FileSelection{
+		Path:    "main.go",
+		Added:   []diff.LineRange{{Start: 5, End: 5}},
+		Deleted: []diff.LineRange{{Start: 5, End: 6}},
+	}, blocks[1].Selection("main.go"))
+	require.Equal(t, "5-6", file.BlockSpec(blocks[1]))
+
+	added, deleted := blocks[1].Stats()
+	require.Equal(t, 1, added)
+	require.Equal(t, 2, deleted)
+
+	require.Equal(t, diff.BlockRef{Hunk: 2, Block: 1}, blocks[2].Ref)
+	require.Equal(t, "20", file.BlockSpec(blocks[2]))
+}
+
+func TestParseFileSelection_Blocks(t *testing.T) {
+	sel, err := diff.ParseFileSelection("main.go:@1.2,@2,30")
+	require.NoError(t, err)
+	require.Equal(t, []diff.BlockRef{{Hunk: 1, Block: 2}, {Hunk: 2}},
+		sel.Blocks)
+	require.Equal(t, []diff.LineRange{{Start: 30, End: 30}}, sel.Ranges)
+	require.Equal(t, "main.go:30,@1.2,@2", sel.String())
+
+	for _, bad := range []string{"main.go:@", "main.go:@0", "main.go:@1.x",
+		"main.go:@1.0", "main.go:@-1"} {
+
+		_, err := diff.ParseFileSelection(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestResolveBlocks(t *testing.T) {
+	parsed, err := diff.Parse(blockTestDiff)
+	require.NoError(t, err)
+
+	sels, err := diff.ParseSelections([]string{"main.go:@1.2,@2"})
+	require.NoError(t, err)
+	require.NoError(t, parsed.ResolveBlocks(sels))
+	require.Empty(t, sels[0].Blocks)
+	require.Empty(t, sels[0].Ranges)
+	require.Equal(t, []diff.LineRange{
+		{Start: 5, End: 5}, {Start: 20, End: 20},
+	}, sels[0].Added)
+	require.Equal(t, []diff.LineRange{{Start: 5, End: 6}}, sels[0].Deleted)
+
+	// A whole hunk selects each of its blocks.
+	sels, err = diff.ParseSelections([]string{"main.go:@1"})
+	require.NoError(t, err)
+	require.NoError(t, parsed.ResolveBlocks(sels))
+	require.Equal(t, []diff.LineRange{
+		{Start: 2, End: 2}, {Start: 5, End: 5},
+	}, sels[0].Added)
+	require.Equal(t, []diff.LineRange{
+		{Start: 2, End: 2}, {Start: 5, End: 6},
+	}, sels[0].Deleted)
+
+	for sel, want := range map[string]string{
+		"main.go:@3":   "main.go has no hunk 3 (2 hunks)",
+		"main.go:@2.2": "main.go has no change block @2.2",
+		"other.go:@1":  "no changes for other.go",
+	} {
+		sels, err := diff.ParseSelections([]string{sel})
+		require.NoError(t, err)
+		require.EqualError(t, parsed.ResolveBlocks(sels), want)
+	}
+}
+
+// crossedBlocksDiff inserts a, b and c after line 2 and deletes line 5, so
+// the deletion in @1.2 has the same number, 5, as the addition of c in
+// @1.1.
+const crossedBlocksDiff = `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,6 +1,8 @@
+ 1
+ 2
++a
++b
++c
+ 3
+ 4
+-5
+ 6
+`
+
+func TestResolveBlocksCrossedNumbers(t *testing.T) {
+	parsed, err := diff.Parse(crossedBlocksDiff)
+	require.NoError(t, err)
+
+	file := parsed.FileByPath("f.txt")
+
+	var blocks []diff.ChangeBlock
+	for b := range file.Blocks() {
+		blocks = append(blocks, b)
+	}
+	require.Len(t, blocks, 2)
+
+	// Plain numbers would select across blocks, so the specs carry
+	// their sides.
+	require.Equal(t, "+3-5", file.BlockSpec(blocks[0]))
+	require.Equal(t, "-5", file.BlockSpec(blocks[1]))
+
+	for ref, want := range map[string][]string{
+		"f.txt:@1.1": {"a", "b", "c"},
+		"f.txt:@1.2": {"5"},
+		"f.txt:+3-5": {"a", "b", "c"},
+		"f.txt:-5":   {"5"},
+
+		// A plain number still selects both sides.
+		"f.txt:5": {"c", "5"},
+	} {
+		sels, err := diff.ParseSelections([]string{ref})
+		require.NoError(t, err)
+		require.NoError(t, parsed.ResolveBlocks(sels))
+
+		var got []string
+		for _, line := range file.Hunks[0].Lines {
+			if line.IsChange() && sels[0].Selects(line) {
+				got = append(got, line.Content)
+			}
+		}
+		require.Equal(t, want, got, ref)
+	}
+}
+
+func TestParseFileSelection_Sides(t *testing.T) {
+	sel, err := diff.ParseFileSelection("main.go:+3-5,-7,9")
+	require.NoError(t, err)
+	require.Equal(t, []diff.LineRange{{Start: 3, End: 5}}, sel.Added)
+	require.Equal(t, []diff.LineRange{{Start: 7, End: 7}}, sel.Deleted)
+	require.Equal(t, []diff.LineRange{{Start: 9, End: 9}}, sel.Ranges)
+	require.Equal(t, "main.go:9,+3-5,-7", sel.String())
+
+	for _, bad := range []string{"main.go:+", "main.go:-", "main.go:+-3",
+		"main.go:--3", "main.go:+0"} {
+
+		_, err := diff.ParseFileSelection(bad)
+		require.Error(t, err, bad)
+	}
+}
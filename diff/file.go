@@ -28,6 +28,55 @@ type FileDiff struct {
 
 	// IsRenamed is true if this file was renamed.
 	IsRenamed bool
+
+	// IsCopied is true if this file was copied from OldName. The old file
+	// is left in place.
+	IsCopied bool
+
+	// Similarity is the similarity percentage git reported for a rename
+	// or copy, or zero if the diff had no similarity index.
+	Similarity int
+
+	// OldMode is the file mode before the change (e.g. "100644"), or empty
+	// if the diff didn't say.
+	OldMode string
+
+	// NewMode is the file mode after the change, or empty if the diff
+	// didn't say.
+	NewMode string
+
+	// OldBlob is the (possibly abbreviated) object id of the original
+	// content from the diff's index line. It identifies the preimage a
+	// patch must be applied to.
+	OldBlob string
+
+	// NewBlob is the object id of the changed content.
+	NewBlob string
+
+	// BinaryPatch is the body of git's binary patch for the file, the
+	// lines following "GIT binary patch". It is only present when the diff
+	// was taken with --binary, and is what lets a binary file be staged.
+	BinaryPatch string
+
+	// sizes holds a binary file's sizes when they were looked up rather
+	// than read from BinaryPatch.
+	sizes *binarySizes
+}
+
+// ModeSymlink is the git file mode of a symbolic link.
+const ModeSymlink = "120000"
+
+// IsSymlink reports whether either side of the change is a symbolic link.
+// A symlink's content is its target path, which only makes sense to stage
+// as a whole.
+func (f *FileDiff) IsSymlink() bool {
+	return f.OldMode == ModeSymlink || f.NewMode == ModeSymlink
+}
+
+// ModeChanged reports whether an existing file's mode changed.
+func (f *FileDiff) ModeChanged() bool {
+	return !f.IsNew && !f.IsDeleted && f.OldMode != "" && f.NewMode != "" &&
+		f.OldMode != f.NewMode
 }
 
 // Path returns the canonical file path.
@@ -122,18 +171,12 @@ func (f *FileDiff) Format() string {
 	var sb strings.Builder
 
 	// File header.
-	fmt.Fprintf(&sb, "--- a/%s\n", f.OldName)
-	fmt.Fprintf(&sb, "+++ b/%s\n", f.NewName)
+	fmt.Fprintf(&sb, "--- %s\n", GitQuote("a/"+f.OldName))
+	fmt.Fprintf(&sb, "+++ %s\n", GitQuote("b/"+f.NewName))
 
 	// Hunks.
 	for _, hunk := range f.Hunks {
-		sb.WriteString(hunk.Header())
-		sb.WriteByte('\n')
-
-		for _, line := range hunk.Lines {
-			sb.WriteString(line.String())
-			sb.WriteByte('\n')
-		}
+		sb.WriteString(hunk.Format())
 	}
 
 	return sb.String()
@@ -3,6 +3,7 @@ package diff
 import (
 	"fmt"
 	"iter"
+	"strings"
 )
 
 // Hunk represents a contiguous block of changes in a file.
@@ -40,6 +41,27 @@ func (h *Hunk) Header() string {
 	return header
 }
 
+// Format returns the hunk header and lines in unified diff format, with a
+// NoNewlineMarker after any line lacking a trailing newline.
+func (h *Hunk) Format() string {
+	var sb strings.Builder
+
+	sb.WriteString(h.Header())
+	sb.WriteByte('\n')
+
+	for _, line := range h.Lines {
+		sb.WriteString(line.String())
+		sb.WriteByte('\n')
+
+		if line.NoNewline {
+			sb.WriteString(NoNewlineMarker)
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String()
+}
+
 // All returns an iterator over all lines in this hunk.
 func (h *Hunk) All() iter.Seq[DiffLine] {
 	return func(yield func(DiffLine) bool) {
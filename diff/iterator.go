@@ -148,15 +148,7 @@ func LinesInRange(lines iter.Seq[DiffLine], start, end int) iter.Seq[DiffLine] {
 func SelectedLines(
 	lines iter.Seq[DiffLine], sel *FileSelection,
 ) iter.Seq[DiffLine] {
-	return FilteredLines(lines, func(line DiffLine) bool {
-		// For deletions, check OldLineNum.
-		if line.Op == OpDelete {
-			return sel.Contains(line.OldLineNum)
-		}
-
-		// For additions and context, check NewLineNum.
-		return sel.Contains(line.NewLineNum)
-	})
+	return FilteredLines(lines, sel.Selects)
 }
 
 // ForEach applies a function to each line.
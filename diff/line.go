@@ -62,8 +62,16 @@ type DiffLine struct {
 	// NewLineNum is the line number in the new file.
 	// Zero if this is a deleted line.
 	NewLineNum int
+
+	// NoNewline is true if this is the last line of its file and has no
+	// trailing newline. Patches mark it with NoNewlineMarker.
+	NoNewline bool
 }
 
+// NoNewlineMarker follows a line that lacks a trailing newline in a unified
+// diff.
+const NoNewlineMarker = `\ No newline at end of file`
+
 // String returns the line in unified diff format.
 func (l DiffLine) String() string {
 	return string(l.Op.Prefix()) + l.Content
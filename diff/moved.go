@@ -0,0 +1,252 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minMoveLines is the minimum number of consecutive lines a block must
+	// span before it is reported as moved.
+	minMoveLines = 3
+
+	// minMoveChars is the minimum number of alphanumeric characters a
+	// block must contain before it is reported as moved. This mirrors
+	// git's --color-moved heuristic and keeps runs of braces or blank
+	// lines from being flagged.
+	minMoveChars = 20
+)
+
+// MovedBlock describes a block of lines that was deleted in one place and
+// added verbatim in another, either within a single file or across files.
+type MovedBlock struct {
+	// ID identifies the move group. IDs start at 1 and are assigned in
+	// diff order of the deleted side.
+	ID int
+
+	// OldPath is the file the block was removed from.
+	OldPath string
+
+	// OldStart is the first deleted line (old file numbering).
+	OldStart int
+
+	// OldEnd is the last deleted line (old file numbering).
+	OldEnd int
+
+	// NewPath is the file the block was added to.
+	NewPath string
+
+	// NewStart is the first added line (new file numbering).
+	NewStart int
+
+	// NewEnd is the last added line (new file numbering).
+	NewEnd int
+}
+
+// Lines returns the number of lines in the moved block.
+func (m MovedBlock) Lines() int {
+	return m.OldEnd - m.OldStart + 1
+}
+
+// ContainsOld reports whether the deleted side of the move covers the given
+// old line in path.
+func (m MovedBlock) ContainsOld(path string, lineNum int) bool {
+	return m.OldPath == path && lineNum >= m.OldStart && lineNum <= m.OldEnd
+}
+
+// ContainsNew reports whether the added side of the move covers the given
+// new line in path.
+func (m MovedBlock) ContainsNew(path string, lineNum int) bool {
+	return m.NewPath == path && lineNum >= m.NewStart && lineNum <= m.NewEnd
+}
+
+// Moves returns all moved blocks detected in the diff.
+func (d *ParsedDiff) Moves() []MovedBlock {
+	return d.moves
+}
+
+// MoveForLine returns the moved block a change line belongs to, if any.
+func (d *ParsedDiff) MoveForLine(
+	file *FileDiff, line DiffLine,
+) (MovedBlock, bool) {
+	for _, m := range d.moves {
+		switch line.Op {
+		case OpDelete:
+			if m.ContainsOld(file.OldName, line.OldLineNum) {
+				return m, true
+			}
+
+		case OpAdd:
+			if m.ContainsNew(file.NewName, line.NewLineNum) {
+				return m, true
+			}
+		}
+	}
+
+	return MovedBlock{}, false
+}
+
+// SplitMoves returns the moved blocks for which the selections pick up one
+// side of the move but not the other. Files names whole files that are
+// selected along with the line selections. Staging such a selection leaves
+// the moved code either duplicated or missing in the index.
+func (d *ParsedDiff) SplitMoves(
+	selections []*FileSelection, files []string,
+) []MovedBlock {
+	selMap := NewSelectionMap(selections)
+
+	var split []MovedBlock
+
+	for _, m := range d.moves {
+		oldSel := slices.Contains(files, m.OldPath) || rangeSelected(
+			selMap.Get(m.OldPath), OpDelete, m.OldStart, m.OldEnd,
+		)
+		newSel := slices.Contains(files, m.NewPath) || rangeSelected(
+			selMap.Get(m.NewPath), OpAdd, m.NewStart, m.NewEnd,
+		)
+
+		if oldSel != newSel {
+			split = append(split, m)
+		}
+	}
+
+	return split
+}
+
+// rangeSelected reports whether any of the lines numbered start to end on
+// op's side, old for deletions and new for additions, is selected.
+func rangeSelected(sel *FileSelection, op LineOp, start, end int) bool {
+	if sel == nil {
+		return false
+	}
+
+	for i := start; i <= end; i++ {
+		line := DiffLine{Op: op, OldLineNum: i, NewLineNum: i}
+		if sel.Selects(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// moveRun is a maximal run of consecutive added or deleted lines.
+type moveRun struct {
+	file  *FileDiff
+	lines []DiffLine
+}
+
+// movePos addresses a single line within a slice of runs.
+type movePos struct {
+	run int
+	idx int
+}
+
+// detectMoves finds blocks of deleted lines that reappear verbatim as added
+// lines elsewhere in the diff. Matching is greedy: each deleted line is
+// paired with the longest available run of identical added lines, and every
+// added line is claimed by at most one move.
+func detectMoves(files []*FileDiff) []MovedBlock {
+	var delRuns, addRuns []moveRun
+
+	for _, f := range files {
+		for _, hunk := range f.Hunks {
+			for chunk := range ChunkByOp(hunk.All()) {
+				switch chunk[0].Op {
+				case OpDelete:
+					delRuns = append(delRuns, moveRun{f, chunk})
+				case OpAdd:
+					addRuns = append(addRuns, moveRun{f, chunk})
+				}
+			}
+		}
+	}
+
+	if len(delRuns) == 0 || len(addRuns) == 0 {
+		return nil
+	}
+
+	// Index added lines by content so candidates can be found quickly.
+	index := make(map[string][]movePos)
+	used := make([][]bool, len(addRuns))
+
+	for r, run := range addRuns {
+		used[r] = make([]bool, len(run.lines))
+
+		for i, line := range run.lines {
+			if strings.TrimSpace(line.Content) == "" {
+				continue
+			}
+
+			index[line.Content] = append(
+				index[line.Content], movePos{run: r, idx: i},
+			)
+		}
+	}
+
+	var moves []MovedBlock
+
+	for _, del := range delRuns {
+		for i := 0; i < len(del.lines); {
+			best, bestLen := movePos{}, 0
+
+			for _, cand := range index[del.lines[i].Content] {
+				add := addRuns[cand.run].lines
+
+				n := 0
+				for i+n < len(del.lines) && cand.idx+n < len(add) &&
+					!used[cand.run][cand.idx+n] &&
+					del.lines[i+n].Content == add[cand.idx+n].Content {
+
+					n++
+				}
+
+				if n > bestLen {
+					best, bestLen = cand, n
+				}
+			}
+
+			block := del.lines[i : i+bestLen]
+			if bestLen < minMoveLines || alnumCount(block) < minMoveChars {
+				i++
+
+				continue
+			}
+
+			add := addRuns[best.run]
+			for j := range bestLen {
+				used[best.run][best.idx+j] = true
+			}
+
+			moves = append(moves, MovedBlock{
+				ID:       len(moves) + 1,
+				OldPath:  del.file.OldName,
+				OldStart: block[0].OldLineNum,
+				OldEnd:   block[bestLen-1].OldLineNum,
+				NewPath:  add.file.NewName,
+				NewStart: add.lines[best.idx].NewLineNum,
+				NewEnd:   add.lines[best.idx+bestLen-1].NewLineNum,
+			})
+
+			i += bestLen
+		}
+	}
+
+	return moves
+}
+
+// alnumCount returns the number of letters and digits across all lines.
+func alnumCount(lines []DiffLine) int {
+	count := 0
+
+	for _, line := range lines {
+		for _, r := range line.Content {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				count++
+			}
+		}
+	}
+
+	return count
+}
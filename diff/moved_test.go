@@ -0,0 +1,204 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/stretchr/testify/require"
+)
+
+// movedAcrossFiles moves a three-line helper from a.go to b.go.
+const movedAcrossFiles = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,4 +1,1 @@
+ package a
+-func helper(value int) int {
+-	return value * multiplier
+-}
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1 +1,4 @@
+ package b
++func helper(value int) int {
++	return value * multiplier
++}
+`
+
+func TestDetectMoves(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		validate func(t *testing.T, d *diff.ParsedDiff)
+	}{
+		{
+			name:  "move between files",
+			input: movedAcrossFiles,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				moves := d.Moves()
+				require.Len(t, moves, 1)
+
+				m := moves[0]
+				require.Equal(t, 1, m.ID)
+				require.Equal(t, "a.go", m.OldPath)
+				require.Equal(t, 2, m.OldStart)
+				require.Equal(t, 4, m.OldEnd)
+				require.Equal(t, "b.go", m.NewPath)
+				require.Equal(t, 2, m.NewStart)
+				require.Equal(t, 4, m.NewEnd)
+				require.Equal(t, 3, m.Lines())
+			},
+		},
+		{
+			name: "move within a file",
+			input: `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ package main
+-func first() {
+-	println("the first function")
+-}
+ func main() {}
++func first() {
++	println("the first function")
++}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				moves := d.Moves()
+				require.Len(t, moves, 1)
+				require.Equal(t, "main.go", moves[0].OldPath)
+				require.Equal(t, "main.go", moves[0].NewPath)
+				require.Equal(t, 2, moves[0].OldStart)
+				require.Equal(t, 3, moves[0].NewStart)
+			},
+		},
+		{
+			name: "short blocks are ignored",
+			input: `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+-}
+-}
+-}
+ x
++}
++}
++}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				require.Empty(t, d.Moves())
+			},
+		},
+		{
+			name: "changed content is not a move",
+			input: `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+-func helper(value int) int {
+-	return value * multiplier
+-}
++func helper(value int) int {
++	return value * divisor
++}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				require.Empty(t, d.Moves())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := diff.Parse(tt.input)
+			require.NoError(t, err)
+
+			tt.validate(t, d)
+		})
+	}
+}
+
+func TestParsedDiff_MoveForLine(t *testing.T) {
+	d, err := diff.Parse(movedAcrossFiles)
+	require.NoError(t, err)
+
+	files := d.AllFiles()
+	require.Len(t, files, 2)
+
+	// The deleted side lives in a.go.
+	m, ok := d.MoveForLine(files[0], files[0].Hunks[0].Lines[1])
+	require.True(t, ok)
+	require.Equal(t, 1, m.ID)
+
+	// Context lines never belong to a move.
+	_, ok = d.MoveForLine(files[0], files[0].Hunks[0].Lines[0])
+	require.False(t, ok)
+
+	// The added side lives in b.go.
+	m, ok = d.MoveForLine(files[1], files[1].Hunks[0].Lines[1])
+	require.True(t, ok)
+	require.Equal(t, 1, m.ID)
+}
+
+func TestParsedDiff_SplitMoves(t *testing.T) {
+	d, err := diff.Parse(movedAcrossFiles)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		args      []string
+		files     []string
+		wantSplit int
+	}{
+		{
+			name:      "only the deletion",
+			args:      []string{"a.go:2-4"},
+			wantSplit: 1,
+		},
+		{
+			name:      "only the addition",
+			args:      []string{"b.go:3"},
+			wantSplit: 1,
+		},
+		{
+			name:      "both sides",
+			args:      []string{"a.go:2-4", "b.go:2-4"},
+			wantSplit: 0,
+		},
+		{
+			name:      "only the deleting file",
+			files:     []string{"a.go"},
+			wantSplit: 1,
+		},
+		{
+			name:      "whole file and lines",
+			args:      []string{"b.go:2-4"},
+			files:     []string{"a.go"},
+			wantSplit: 0,
+		},
+		{
+			name:      "both files",
+			files:     []string{"a.go", "b.go"},
+			wantSplit: 0,
+		},
+		{
+			name:      "neither side",
+			args:      []string{"a.go:1"},
+			wantSplit: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sels, err := diff.ParseSelections(tt.args)
+			require.NoError(t, err)
+
+			require.Len(t, d.SplitMoves(sels, tt.files), tt.wantSplit)
+		})
+	}
+}
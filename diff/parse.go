@@ -2,8 +2,12 @@ package diff
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"iter"
+	"slices"
+	"strconv"
 	"strings"
 
 	godiff "github.com/sourcegraph/go-diff/diff"
@@ -12,6 +16,7 @@ import (
 // ParsedDiff wraps a parsed multi-file diff.
 type ParsedDiff struct {
 	files []*FileDiff
+	moves []MovedBlock
 }
 
 // Parse parses a unified diff string into a structured representation.
@@ -34,9 +39,38 @@ func Parse(diffText string) (*ParsedDiff, error) {
 		parsed.files = append(parsed.files, fd)
 	}
 
+	parsed.moves = detectMoves(parsed.files)
+
 	return parsed, nil
 }
 
+// ParseStream parses a unified diff incrementally, yielding each file as
+// soon as it has been read from r. Unlike Parse, the whole diff is never
+// held in memory, so moved blocks are not detected. Iteration stops after
+// the first error.
+func ParseStream(r io.Reader) iter.Seq2[*FileDiff, error] {
+	return func(yield func(*FileDiff, error) bool) {
+		reader := godiff.NewMultiFileDiffReader(r)
+
+		for {
+			f, err := reader.ReadFile()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to parse diff: %w", err))
+
+				return
+			}
+
+			if !yield(convertFileDiff(f), nil) {
+				return
+			}
+		}
+	}
+}
+
 // Files returns an iterator over all file diffs.
 func (d *ParsedDiff) Files() iter.Seq[*FileDiff] {
 	return func(yield func(*FileDiff) bool) {
@@ -80,6 +114,12 @@ func (d *ParsedDiff) AllFiles() []*FileDiff {
 	return d.files
 }
 
+// WithFiles returns a diff holding the given files in place of d's, keeping
+// the moved blocks detected in d so lines still report where they moved.
+func (d *ParsedDiff) WithFiles(files []*FileDiff) *ParsedDiff {
+	return &ParsedDiff{files: files, moves: d.moves}
+}
+
 // Stats returns total addition and deletion counts across all files.
 func (d *ParsedDiff) Stats() (added, deleted int) {
 	for _, f := range d.files {
@@ -143,20 +183,56 @@ func convertFileDiff(f *godiff.FileDiff) *FileDiff {
 		IsDeleted: f.NewName == "/dev/null",
 	}
 
-	// Check for renames.
-	if fd.OldName != fd.NewName && !fd.IsNew && !fd.IsDeleted {
-		fd.IsRenamed = true
+	// go-diff leaves the body of a binary patch in the extended header
+	// lines.
+	extended := f.Extended
+	if i := slices.Index(extended, binaryPatchMarker); i >= 0 {
+		fd.IsBinary = true
+		fd.BinaryPatch = strings.Join(extended[i+1:], "\n") + "\n"
+		extended = extended[:i]
 	}
 
-	// Check for binary.
-	for _, ex := range f.Extended {
-		if strings.Contains(ex, "Binary files") {
+	for _, ex := range extended {
+		switch {
+		case strings.Contains(ex, "Binary files"):
 			fd.IsBinary = true
 
-			break
+		case strings.HasPrefix(ex, "similarity index "):
+			pct := strings.TrimSuffix(
+				strings.TrimPrefix(ex, "similarity index "), "%",
+			)
+			fd.Similarity, _ = strconv.Atoi(pct)
+
+		case strings.HasPrefix(ex, "copy from "):
+			fd.IsCopied = true
+
+		case strings.HasPrefix(ex, "old mode "):
+			fd.OldMode = strings.TrimPrefix(ex, "old mode ")
+
+		case strings.HasPrefix(ex, "new mode "):
+			fd.NewMode = strings.TrimPrefix(ex, "new mode ")
+
+		case strings.HasPrefix(ex, "new file mode "):
+			fd.IsNew = true
+			fd.NewMode = strings.TrimPrefix(ex, "new file mode ")
+
+		case strings.HasPrefix(ex, "deleted file mode "):
+			fd.IsDeleted = true
+			fd.OldMode = strings.TrimPrefix(ex, "deleted file mode ")
+
+		case strings.HasPrefix(ex, "index "):
+			parseIndexLine(fd, strings.TrimPrefix(ex, "index "))
 		}
 	}
 
+	// Check for renames. A copy also has differing names but leaves the
+	// old file in place.
+	if fd.OldName != fd.NewName && !fd.IsNew && !fd.IsDeleted &&
+		!fd.IsCopied {
+
+		fd.IsRenamed = true
+	}
+
 	for _, h := range f.Hunks {
 		hunk := convertHunk(h)
 		fd.Hunks = append(fd.Hunks, hunk)
@@ -165,6 +241,31 @@ func convertFileDiff(f *godiff.FileDiff) *FileDiff {
 	return fd
 }
 
+// parseIndexLine parses the "<old>..<new> [<mode>]" part of an index
+// header. The mode is only present when it didn't change, in which case it
+// applies to both sides.
+func parseIndexLine(fd *FileDiff, spec string) {
+	ids, mode, _ := strings.Cut(spec, " ")
+
+	oldID, newID, ok := strings.Cut(ids, "..")
+	if !ok {
+		return
+	}
+
+	fd.OldBlob, fd.NewBlob = oldID, newID
+
+	if mode == "" {
+		return
+	}
+
+	if fd.OldMode == "" && !fd.IsNew {
+		fd.OldMode = mode
+	}
+	if fd.NewMode == "" && !fd.IsDeleted {
+		fd.NewMode = mode
+	}
+}
+
 // convertHunk converts a go-diff Hunk to our Hunk type with line numbers.
 func convertHunk(h *godiff.Hunk) *Hunk {
 	hunk := &Hunk{
@@ -179,8 +280,18 @@ func convertHunk(h *godiff.Hunk) *Hunk {
 	oldLine := hunk.OldStart
 	newLine := hunk.NewStart
 
+	// go-diff drops "\ No newline at end of file" markers. A missing
+	// newline on the old side is recorded as the body offset just past the
+	// affected line; on the new side the body simply lacks its final
+	// newline.
+	noNewlineAt := int(h.OrigNoNewlineAt)
+	missingFinal := len(h.Body) > 0 && h.Body[len(h.Body)-1] != '\n'
+
+	offset := 0
 	lines := bytes.Split(h.Body, []byte("\n"))
-	for _, lineBytes := range lines {
+	for i, lineBytes := range lines {
+		offset += len(lineBytes) + 1
+
 		if len(lineBytes) == 0 {
 			continue
 		}
@@ -228,6 +339,9 @@ func convertHunk(h *godiff.Hunk) *Hunk {
 			continue
 		}
 
+		dl.NoNewline = (noNewlineAt > 0 && offset == noNewlineAt) ||
+			(missingFinal && i == len(lines)-1)
+
 		hunk.Lines = append(hunk.Lines, dl)
 	}
 
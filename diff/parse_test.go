@@ -1,6 +1,7 @@
 package diff_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/roasbeef/hunk/diff"
@@ -229,6 +230,144 @@ deleted file mode 100644
 				require.Equal(t, "func handler() {", hunk.Section)
 			},
 		},
+		{
+			name: "rename with edits",
+			input: `diff --git a/old.go b/new.go
+similarity index 90%
+rename from old.go
+rename to new.go
+index 0ff3bbb..fb3ced1 100644
+--- a/old.go
++++ b/new.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+ func main() {}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				files := d.AllFiles()
+				require.Len(t, files, 1)
+				require.True(t, files[0].IsRenamed)
+				require.False(t, files[0].IsCopied)
+				require.Equal(t, 90, files[0].Similarity)
+				require.Equal(t, "old.go", files[0].OldName)
+				require.Equal(t, "new.go", files[0].Path())
+				require.Len(t, files[0].Hunks, 1)
+			},
+		},
+		{
+			name: "pure rename",
+			input: `diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				files := d.AllFiles()
+				require.Len(t, files, 1)
+				require.True(t, files[0].IsRenamed)
+				require.Equal(t, 100, files[0].Similarity)
+				require.Empty(t, files[0].Hunks)
+			},
+		},
+		{
+			name: "copy",
+			input: `diff --git a/a.go b/b.go
+similarity index 95%
+copy from a.go
+copy to b.go
+index 0ff3bbb..fb3ced1 100644
+--- a/a.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+ package main
++// Copied.
+ func main() {}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				files := d.AllFiles()
+				require.Len(t, files, 1)
+				require.True(t, files[0].IsCopied)
+				require.False(t, files[0].IsRenamed)
+				require.Equal(t, 95, files[0].Similarity)
+				require.Equal(t, "a.go", files[0].OldName)
+				require.Equal(t, "b.go", files[0].Path())
+			},
+		},
+		{
+			name: "index line with unchanged mode",
+			input: `diff --git a/main.go b/main.go
+index 0ff3bbb..fb3ced1 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				f := d.AllFiles()[0]
+				require.Equal(t, "0ff3bbb", f.OldBlob)
+				require.Equal(t, "fb3ced1", f.NewBlob)
+				require.Equal(t, "100644", f.OldMode)
+				require.Equal(t, "100644", f.NewMode)
+				require.False(t, f.ModeChanged())
+			},
+		},
+		{
+			name: "mode change",
+			input: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 0ff3bbb..fb3ced1
+--- a/run.sh
++++ b/run.sh
+@@ -1 +1,2 @@
+ #!/bin/sh
++echo hi
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				f := d.AllFiles()[0]
+				require.Equal(t, "100644", f.OldMode)
+				require.Equal(t, "100755", f.NewMode)
+				require.True(t, f.ModeChanged())
+				require.Equal(t, "0ff3bbb", f.OldBlob)
+			},
+		},
+		{
+			name: "new empty file",
+			input: `diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000..e69de29
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				f := d.AllFiles()[0]
+				require.True(t, f.IsNew)
+				require.Equal(t, "empty.txt", f.Path())
+				require.Empty(t, f.OldMode)
+				require.Equal(t, "100644", f.NewMode)
+				require.Equal(t, "e69de29", f.NewBlob)
+			},
+		},
+		{
+			name: "deleted file",
+			input: `diff --git a/old.go b/old.go
+deleted file mode 100755
+index 0ff3bbb..0000000
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`,
+			validate: func(t *testing.T, d *diff.ParsedDiff) {
+				f := d.AllFiles()[0]
+				require.True(t, f.IsDeleted)
+				require.Equal(t, "100755", f.OldMode)
+				require.Empty(t, f.NewMode)
+				require.False(t, f.ModeChanged())
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -246,6 +385,75 @@ deleted file mode 100644
 	}
 }
 
+func TestParseNoNewline(t *testing.T) {
+	input := `diff --git a/link b/link
+index 0ff3bbb..fb3ced1 120000
+--- a/link
++++ b/link
+@@ -1 +1 @@
+-old-target
+\ No newline at end of file
++new-target
+\ No newline at end of file
+`
+
+	d, err := diff.Parse(input)
+	require.NoError(t, err)
+
+	hunk := d.AllFiles()[0].Hunks[0]
+	require.Len(t, hunk.Lines, 2)
+	require.Equal(t, "old-target", hunk.Lines[0].Content)
+	require.True(t, hunk.Lines[0].NoNewline)
+	require.Equal(t, "new-target", hunk.Lines[1].Content)
+	require.True(t, hunk.Lines[1].NoNewline)
+
+	// Formatting restores the markers.
+	require.Equal(t, "@@ -1,1 +1,1 @@\n"+
+		"-old-target\n"+diff.NoNewlineMarker+"\n"+
+		"+new-target\n"+diff.NoNewlineMarker+"\n", hunk.Format())
+}
+
+func TestParseQuotedPaths(t *testing.T) {
+	// Names with spaces get a trailing tab on the ---/+++ lines, and
+	// names with special characters are C-quoted.
+	diffText := "diff --git a/my file.go b/my file.go\n" +
+		"index 7898192..422c2b7 100644\n" +
+		"--- a/my file.go\t\n" +
+		"+++ b/my file.go\t\n" +
+		"@@ -1 +1,2 @@\n" +
+		" a\n" +
+		"+b\n" +
+		`diff --git "a/caf\303\251.go" "b/caf\303\251.go"` + "\n" +
+		"index 7898192..422c2b7 100644\n" +
+		`--- "a/caf\303\251.go"` + "\n" +
+		`+++ "b/caf\303\251.go"` + "\n" +
+		"@@ -1 +1,2 @@\n" +
+		" a\n" +
+		"+b\n" +
+		`diff --git "a/mode\tx.sh" "b/mode\tx.sh"` + "\n" +
+		"old mode 100644\n" +
+		"new mode 100755\n" +
+		`diff --git a/old.go "b/n\303\253w:file.go"` + "\n" +
+		"similarity index 100%\n" +
+		"rename from old.go\n" +
+		`rename to "n\303\253w:file.go"` + "\n"
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var paths []string
+	for file := range parsed.Files() {
+		paths = append(paths, file.OldName+" -> "+file.NewName)
+	}
+
+	require.Equal(t, []string{
+		"my file.go -> my file.go",
+		"café.go -> café.go",
+		"mode\tx.sh -> mode\tx.sh",
+		"old.go -> nëw:file.go",
+	}, paths)
+}
+
 func TestLineNumbers(t *testing.T) {
 	input := `diff --git a/main.go b/main.go
 --- a/main.go
@@ -334,3 +542,43 @@ func TestLinesWithContext(t *testing.T) {
 	require.Equal(t, 0, addCtx.HunkIndex)
 	require.Equal(t, "main.go", addCtx.File.Path())
 }
+
+func TestParseStream(t *testing.T) {
+	input := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1 +1,2 @@
+ package a
++// change a
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1 +1,2 @@
+ package b
++// change b
+`
+
+	var paths []string
+	for f, err := range diff.ParseStream(strings.NewReader(input)) {
+		require.NoError(t, err)
+		require.Len(t, f.Hunks, 1)
+
+		paths = append(paths, f.Path())
+	}
+
+	require.Equal(t, []string{"a.go", "b.go"}, paths)
+
+	// Stopping early does not read further files.
+	count := 0
+	for range diff.ParseStream(strings.NewReader(input)) {
+		count++
+
+		break
+	}
+	require.Equal(t, 1, count)
+
+	// Empty input yields nothing.
+	for range diff.ParseStream(strings.NewReader("")) {
+		t.Fatal("unexpected file")
+	}
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GitQuote returns a path as git writes it in diff headers. Paths containing
+// double quotes, backslashes, control characters or non-ASCII bytes are
+// C-quoted, with octal escapes for the raw bytes, like git's default
+// core.quotePath. Other paths are returned unchanged.
+func GitQuote(path string) string {
+	if !needsGitQuote(path) {
+		return path
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+
+		switch c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// needsGitQuote reports whether git would quote a path.
+func needsGitQuote(path string) bool {
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '"' || c == '\\' || c < 0x20 || c >= 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QuoteSelectionPath returns a path as it must be written in a FILE:LINES
+// selection. Paths containing colons, leading double quotes or characters
+// that aren't printable are double-quoted, so they split unambiguously from
+// the line spec. Other paths are returned unchanged.
+func QuoteSelectionPath(path string) string {
+	if strings.Contains(path, ":") || strings.HasPrefix(path, `"`) ||
+		strings.ContainsFunc(path, func(r rune) bool {
+			return !strconv.IsPrint(r)
+		}) {
+
+		return strconv.Quote(path)
+	}
+
+	return path
+}
+
+// unquotePath reads a double-quoted path from the start of s and returns
+// the path and the rest of s. Both Go escapes and git's C-style escapes,
+// such as octal bytes, are understood.
+func unquotePath(s string) (path, rest string, err error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+
+		case '"':
+			path, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted path %s: %w",
+					s[:i+1], err)
+			}
+
+			return path, s[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated quoted path in %q", s)
+}
@@ -0,0 +1,39 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitQuote(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"a/main.go", "a/main.go"},
+		{"a/my file.go", "a/my file.go"},
+		{"a/a:b.go", "a/a:b.go"},
+		{"a/café.go", `"a/caf\303\251.go"`},
+		{"a/tab\tx.go", `"a/tab\tx.go"`},
+		{`a/q"uote\.go`, `"a/q\"uote\\.go"`},
+		{"a/bell\a.go", `"a/bell\a.go"`},
+		{"a/esc\x1b.go", `"a/esc\033.go"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			require.Equal(t, tc.want, diff.GitQuote(tc.path))
+		})
+	}
+}
+
+func TestQuoteSelectionPath(t *testing.T) {
+	require.Equal(t, "main.go", diff.QuoteSelectionPath("main.go"))
+	require.Equal(t, "my file.go", diff.QuoteSelectionPath("my file.go"))
+	require.Equal(t, "café.go", diff.QuoteSelectionPath("café.go"))
+	require.Equal(t, `"a:b.go"`, diff.QuoteSelectionPath("a:b.go"))
+	require.Equal(t, `"tab\tx.go"`, diff.QuoteSelectionPath("tab\tx.go"))
+	require.Equal(t, `"\"lead.go"`, diff.QuoteSelectionPath(`"lead.go`))
+}
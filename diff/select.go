@@ -2,8 +2,10 @@ package diff
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 // LineRange represents a range of lines to select.
@@ -28,8 +30,21 @@ func (r LineRange) String() string {
 
 // FileSelection represents selected lines for a file.
 type FileSelection struct {
-	Path   string
+	Path string
+
+	// Ranges select changed lines on either side: additions by their new
+	// line number and deletions by their old one.
 	Ranges []LineRange
+
+	// Added and Deleted select changes on one side only, written "+N" and
+	// "-N". A deletion and an addition can share a number, and a plain
+	// range selects both.
+	Added   []LineRange
+	Deleted []LineRange
+
+	// Blocks are change blocks selected by number. They are turned into
+	// Added and Deleted by ParsedDiff.ResolveBlocks.
+	Blocks []BlockRef
 }
 
 // ParseFileSelection parses "FILE:LINES" syntax.
@@ -37,18 +52,23 @@ type FileSelection struct {
 //   - "main.go:10-20" - lines 10 through 20
 //   - "main.go:10,15,20-25" - lines 10, 15, and 20-25
 //   - "main.go:10" - just line 10
+//   - "main.go:@2.1" - the first change block of the second hunk
+//   - "main.go:@2" - every change block of the second hunk
+//   - "main.go:+5,-5" - only the addition at new line 5 and only the
+//     deletion at old line 5
+//   - `"a:b.go":10` - line 10 of a quoted path, see SplitSelection
 func ParseFileSelection(s string) (*FileSelection, error) {
-	// Find the last colon to handle Windows paths like C:\path\file.go:10.
-	lastColon := strings.LastIndex(s, ":")
-	if lastColon == -1 {
+	path, rangeSpec, hasLines, err := SplitSelection(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasLines {
 		return nil, fmt.Errorf(
 			"invalid selection syntax: expected FILE:LINES, got %q", s,
 		)
 	}
 
-	path := s[:lastColon]
-	rangeSpec := s[lastColon+1:]
-
 	if path == "" {
 		return nil, fmt.Errorf("empty file path in selection: %q", s)
 	}
@@ -57,18 +77,107 @@ func ParseFileSelection(s string) (*FileSelection, error) {
 		return nil, fmt.Errorf("empty line range in selection: %q", s)
 	}
 
-	var ranges []LineRange
+	sel := &FileSelection{Path: path}
 
 	for _, part := range strings.Split(rangeSpec, ",") {
-		r, err := parseRange(part)
+		if strings.HasPrefix(strings.TrimSpace(part), "@") {
+			ref, err := parseBlockRef(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid block %q in %q: %w",
+					part, s, err)
+			}
+
+			sel.Blocks = append(sel.Blocks, ref)
+
+			continue
+		}
+
+		side := &sel.Ranges
+		spec := strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(spec, "+"):
+			side, spec = &sel.Added, spec[1:]
+
+		case strings.HasPrefix(spec, "-"):
+			side, spec = &sel.Deleted, spec[1:]
+		}
+
+		r, err := parseRange(spec)
 		if err != nil {
 			return nil, fmt.Errorf("invalid range %q in %q: %w", part, s, err)
 		}
 
-		ranges = append(ranges, r)
+		*side = append(*side, r)
 	}
 
-	return &FileSelection{Path: path, Ranges: ranges}, nil
+	return sel, nil
+}
+
+// SplitSelection splits a selection into its path and line spec. hasLines
+// is false when there is no ":LINES" part, as when naming a whole file.
+//
+// An unquoted path ends at the last colon, which handles paths like
+// C:\path\file.go:10. A path starting with a double quote is read up to
+// the closing quote, using Go or git C-style escapes, so any path can be
+// named: "a:b.go":10 or "caf\303\251.go".
+func SplitSelection(s string) (path, lines string, hasLines bool, err error) {
+	if strings.HasPrefix(s, `"`) {
+		path, rest, err := unquotePath(s)
+		if err != nil {
+			return "", "", false, err
+		}
+
+		if rest == "" {
+			return path, "", false, nil
+		}
+
+		lines, ok := strings.CutPrefix(rest, ":")
+		if !ok {
+			return "", "", false, fmt.Errorf(
+				"invalid selection syntax: expected \"FILE\":LINES, "+
+					"got %q", s,
+			)
+		}
+
+		return path, lines, true, nil
+	}
+
+	lastColon := strings.LastIndex(s, ":")
+	if lastColon == -1 {
+		return s, "", false, nil
+	}
+
+	return s[:lastColon], s[lastColon+1:], true, nil
+}
+
+// SplitSelectionList splits a space-separated list of selections. A quoted
+// path is kept whole, spaces and all, so "my file.go":3 is one selection.
+// The selections are returned as written, quotes included.
+func SplitSelectionList(s string) ([]string, error) {
+	var selections []string
+
+	for s = strings.TrimLeftFunc(s, unicode.IsSpace); s != ""; {
+		end := 0
+		if strings.HasPrefix(s, `"`) {
+			_, rest, err := unquotePath(s)
+			if err != nil {
+				return nil, err
+			}
+
+			end = len(s) - len(rest)
+		}
+
+		if i := strings.IndexFunc(s[end:], unicode.IsSpace); i >= 0 {
+			end += i
+		} else {
+			end = len(s)
+		}
+
+		selections = append(selections, s[:end])
+		s = strings.TrimLeftFunc(s[end:], unicode.IsSpace)
+	}
+
+	return selections, nil
 }
 
 // parseRange parses a single range like "10", "10-20".
@@ -121,7 +230,29 @@ func parseRange(s string) (LineRange, error) {
 
 // Contains checks if a line number is within any of the ranges.
 func (fs *FileSelection) Contains(lineNum int) bool {
-	for _, r := range fs.Ranges {
+	return rangesContain(fs.Ranges, lineNum)
+}
+
+// Selects reports whether the selection includes line: an addition by its
+// new line number, a deletion by its old one, taking in the ranges of its
+// own side. Context lines go by their new line number.
+func (fs *FileSelection) Selects(line DiffLine) bool {
+	switch line.Op {
+	case OpAdd:
+		return fs.Contains(line.NewLineNum) ||
+			rangesContain(fs.Added, line.NewLineNum)
+
+	case OpDelete:
+		return fs.Contains(line.OldLineNum) ||
+			rangesContain(fs.Deleted, line.OldLineNum)
+	}
+
+	return fs.Contains(line.NewLineNum)
+}
+
+// rangesContain checks if a line number is within any of ranges.
+func rangesContain(ranges []LineRange, lineNum int) bool {
+	for _, r := range ranges {
 		if r.Contains(lineNum) {
 			return true
 		}
@@ -136,8 +267,31 @@ func (fs *FileSelection) String() string {
 	for _, r := range fs.Ranges {
 		parts = append(parts, r.String())
 	}
+	for _, r := range fs.Added {
+		parts = append(parts, "+"+r.String())
+	}
+	for _, r := range fs.Deleted {
+		parts = append(parts, "-"+r.String())
+	}
+	for _, b := range fs.Blocks {
+		parts = append(parts, b.String())
+	}
+
+	return QuoteSelectionPath(fs.Path) + ":" + strings.Join(parts, ",")
+}
 
-	return fs.Path + ":" + strings.Join(parts, ",")
+// Reversed returns a selection of the same lines in the reverse diff, in
+// which additions become deletions and deletions additions. Each line
+// keeps its number, since the reverse diff numbers a deletion by the line
+// it had as an addition and the other way round. Blocks are numbered by
+// the diff they were taken from, so they must be resolved first.
+func (fs *FileSelection) Reversed() *FileSelection {
+	return &FileSelection{
+		Path:    fs.Path,
+		Ranges:  slices.Clone(fs.Ranges),
+		Added:   slices.Clone(fs.Deleted),
+		Deleted: slices.Clone(fs.Added),
+	}
 }
 
 // AllLines returns all individual line numbers covered by the ranges.
@@ -153,27 +307,34 @@ func (fs *FileSelection) AllLines() []int {
 	return lines
 }
 
-// Merge merges overlapping and adjacent ranges.
+// Merge merges overlapping and adjacent ranges of each side.
 func (fs *FileSelection) Merge() {
-	if len(fs.Ranges) <= 1 {
-		return
+	fs.Ranges = mergeRanges(fs.Ranges)
+	fs.Added = mergeRanges(fs.Added)
+	fs.Deleted = mergeRanges(fs.Deleted)
+}
+
+// mergeRanges sorts ranges and merges overlapping and adjacent ones.
+func mergeRanges(ranges []LineRange) []LineRange {
+	if len(ranges) <= 1 {
+		return ranges
 	}
 
 	// Sort by start line.
-	for i := 0; i < len(fs.Ranges); i++ {
-		for j := i + 1; j < len(fs.Ranges); j++ {
-			if fs.Ranges[j].Start < fs.Ranges[i].Start {
-				fs.Ranges[i], fs.Ranges[j] = fs.Ranges[j], fs.Ranges[i]
+	for i := 0; i < len(ranges); i++ {
+		for j := i + 1; j < len(ranges); j++ {
+			if ranges[j].Start < ranges[i].Start {
+				ranges[i], ranges[j] = ranges[j], ranges[i]
 			}
 		}
 	}
 
 	// Merge overlapping.
-	merged := []LineRange{fs.Ranges[0]}
+	merged := []LineRange{ranges[0]}
 
-	for i := 1; i < len(fs.Ranges); i++ {
+	for i := 1; i < len(ranges); i++ {
 		last := &merged[len(merged)-1]
-		curr := fs.Ranges[i]
+		curr := ranges[i]
 
 		if curr.Start <= last.End+1 {
 			// Overlapping or adjacent, merge.
@@ -185,7 +346,7 @@ func (fs *FileSelection) Merge() {
 		}
 	}
 
-	fs.Ranges = merged
+	return merged
 }
 
 // ParseSelections parses multiple FILE:LINES arguments.
@@ -215,6 +376,10 @@ func NewSelectionMap(selections []*FileSelection) SelectionMap {
 		if existing, ok := m[sel.Path]; ok {
 			// Merge ranges for the same file.
 			existing.Ranges = append(existing.Ranges, sel.Ranges...)
+			existing.Added = append(existing.Added, sel.Added...)
+			existing.Deleted = append(
+				existing.Deleted, sel.Deleted...,
+			)
 			existing.Merge()
 		} else {
 			m[sel.Path] = sel
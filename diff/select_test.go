@@ -66,8 +66,10 @@ func TestParseFileSelection(t *testing.T) {
 			wantErr: true,
 		},
 		{
+			// A leading - selects the deletion side, so only a
+			// second one makes the number negative.
 			name:    "negative line",
-			input:   "main.go:-5",
+			input:   "main.go:--5",
 			wantErr: true,
 		},
 		{
@@ -75,6 +77,45 @@ func TestParseFileSelection(t *testing.T) {
 			input:   "main.go:0",
 			wantErr: true,
 		},
+		{
+			name:      "colon in path",
+			input:     "a:b.go:2-3",
+			wantPath:  "a:b.go",
+			wantLines: []int{2, 3},
+		},
+		{
+			name:      "quoted path with colon",
+			input:     `"10:20":5`,
+			wantPath:  "10:20",
+			wantLines: []int{5},
+		},
+		{
+			name:      "quoted path with git escapes",
+			input:     `"caf\303\251 \"v2\".go":1`,
+			wantPath:  `café "v2".go`,
+			wantLines: []int{1},
+		},
+		{
+			name:      "path with spaces",
+			input:     "my file.go:7",
+			wantPath:  "my file.go",
+			wantLines: []int{7},
+		},
+		{
+			name:    "unterminated quote",
+			input:   `"main.go:10`,
+			wantErr: true,
+		},
+		{
+			name:    "text after quoted path",
+			input:   `"main.go"10`,
+			wantErr: true,
+		},
+		{
+			name:    "quoted path without lines",
+			input:   `"main.go"`,
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -93,6 +134,63 @@ func TestParseFileSelection(t *testing.T) {
 	}
 }
 
+func TestSplitSelection(t *testing.T) {
+	tests := []struct {
+		input        string
+		wantPath     string
+		wantLines    string
+		wantHasLines bool
+	}{
+		{"main.go", "main.go", "", false},
+		{"main.go:1-2", "main.go", "1-2", true},
+		{`"a:b.go"`, "a:b.go", "", false},
+		{`"a:b.go":4`, "a:b.go", "4", true},
+		{`"tab\tname.go"`, "tab\tname.go", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			path, lines, hasLines, err := diff.SplitSelection(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantPath, path)
+			require.Equal(t, tc.wantLines, lines)
+			require.Equal(t, tc.wantHasLines, hasLines)
+		})
+	}
+}
+
+func TestSplitSelectionList(t *testing.T) {
+	got, err := diff.SplitSelectionList(
+		` a.go:1-2  "my file.go":3 "a b":@1.1,4` + "\tc.go ",
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"a.go:1-2", `"my file.go":3`, `"a b":@1.1,4`, "c.go",
+	}, got)
+
+	got, err = diff.SplitSelectionList("  ")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = diff.SplitSelectionList(`"open.go:1`)
+	require.ErrorContains(t, err, "unterminated quoted path")
+}
+
+func TestFileSelectionStringQuotesPath(t *testing.T) {
+	for _, path := range []string{"main.go", "a:b.go", "tab\tx.go",
+		`"lead.go`, "café.go"} {
+
+		sel := &diff.FileSelection{
+			Path: path, Ranges: []diff.LineRange{{Start: 3, End: 4}},
+		}
+
+		// The string form parses back to the same selection.
+		parsed, err := diff.ParseFileSelection(sel.String())
+		require.NoError(t, err, sel.String())
+		require.Equal(t, sel, parsed)
+	}
+}
+
 func TestFileSelectionContains(t *testing.T) {
 	sel, err := diff.ParseFileSelection("main.go:10-20,30-40")
 	require.NoError(t, err)
@@ -0,0 +1,309 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HookError reports a commit rejected by a hook.
+type HookError struct {
+	// Hook is the name of the hook that failed, e.g. "pre-commit".
+	Hook string
+
+	// ExitCode is the hook's exit status.
+	ExitCode int
+
+	// Output is everything the hooks printed.
+	Output string
+}
+
+// Error implements error.
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook failed with exit code %d: %s",
+		e.Hook, e.ExitCode, strings.TrimSpace(e.Output))
+}
+
+// Commit creates a commit from the staged changes. A commit rejected by a
+// hook fails with a *HookError. Once the commit exists it is returned even
+// if the hook runs can't be read, since failing then would invite a retry
+// that commits twice.
+func (e *ShellExecutor) Commit(
+	ctx context.Context, opts CommitOptions,
+) (*CommitResult, error) {
+	// Git's trace2 event log records each hook it runs and how it
+	// exited, which tells a hook failure apart from git's own.
+	trace, err := os.CreateTemp("", "hunk-trace-*.json")
+	if err != nil {
+		return nil, err
+	}
+	trace.Close()
+	defer os.Remove(trace.Name())
+
+	args := commitArgs(opts)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if e.WorkDir != "" {
+		cmd.Dir = e.WorkDir
+	}
+
+	env := e.env()
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "GIT_TRACE2_EVENT="+trace.Name())
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+
+	hooks, traceErr := readHookRuns(trace.Name())
+
+	if runErr != nil {
+		if traceErr != nil {
+			return nil, fmt.Errorf(
+				"git %s failed: %w: %s (failed to read hook "+
+					"results: %v)", strings.Join(args, " "),
+				runErr, stderr.String(), traceErr,
+			)
+		}
+
+		if hook := failedHook(hooks); hook != nil {
+			return nil, &HookError{
+				Hook:     hook.Name,
+				ExitCode: hook.ExitCode,
+				Output:   stderr.String(),
+			}
+		}
+
+		return nil, fmt.Errorf(
+			"git %s failed: %w: %s",
+			strings.Join(args, " "), runErr, stderr.String(),
+		)
+	}
+
+	result, err := e.commitResult(ctx, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// The hook runs are only informational here, so an unreadable trace
+	// leaves them out.
+	result.Hooks = hooks
+	if len(hooks) > 0 {
+		// Git sends hook output to stderr.
+		result.HookOutput = stderr.String()
+	}
+
+	return result, nil
+}
+
+// commitArgs builds the git arguments for a commit.
+func commitArgs(opts CommitOptions) []string {
+	args := []string{"commit"}
+
+	switch {
+	case opts.MessageFile != "":
+		args = append(args, "-F", opts.MessageFile)
+
+	case opts.Message != "":
+		args = append(args, "-m", opts.Message)
+
+	default:
+		// Keep the amended message or use the generated fixup or squash
+		// subject, rather than waiting on an editor.
+		args = append(args, "--no-edit")
+	}
+
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.Fixup != "" {
+		args = append(args, "--fixup="+opts.Fixup)
+	}
+	if opts.Squash != "" {
+		args = append(args, "--squash="+opts.Squash)
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Date != "" {
+		args = append(args, "--date="+opts.Date)
+	}
+	if opts.Signoff {
+		args = append(args, "--signoff")
+	}
+	for _, trailer := range opts.Trailers {
+		args = append(args, "--trailer", trailer)
+	}
+	if opts.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+
+	return args
+}
+
+// commitResult describes the commit rev names.
+func (e *ShellExecutor) commitResult(
+	ctx context.Context, rev string,
+) (*CommitResult, error) {
+	output, err := e.run(
+		ctx, nil, "log", "-1", "--format=%H%x00%T%x00%P%x00%s", rev,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit: %w", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(output, "\n"), "\x00", 4)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("unexpected log output %q", output)
+	}
+
+	result := &CommitResult{
+		Hash:    parts[0],
+		Tree:    parts[1],
+		Parents: strings.Fields(parts[2]),
+		Subject: parts[3],
+	}
+
+	// Compare against the first parent, or the empty tree for a root
+	// commit.
+	args := []string{
+		"diff-tree", "-r", "--numstat", "--no-commit-id", "-z",
+	}
+	if len(result.Parents) > 0 {
+		args = append(args, result.Parents[0], result.Hash)
+	} else {
+		args = append(args, "--root", result.Hash)
+	}
+
+	output, err = e.run(ctx, nil, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit stats: %w", err)
+	}
+
+	result.Files, err = parseNumstat(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parseNumstat parses 'git diff-tree --numstat -z' output. Rename detection
+// must be off, so each entry names a single path.
+func parseNumstat(output string) ([]FileStat, error) {
+	var files []FileStat
+
+	for _, entry := range strings.Split(output, "\x00") {
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("unexpected numstat entry %q", entry)
+		}
+
+		stat := FileStat{Path: fields[2]}
+
+		// Binary files are counted as "-\t-".
+		if fields[0] == "-" {
+			stat.Binary = true
+			files = append(files, stat)
+
+			continue
+		}
+
+		var err error
+		if stat.Additions, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("unexpected numstat entry %q", entry)
+		}
+		if stat.Deletions, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("unexpected numstat entry %q", entry)
+		}
+
+		files = append(files, stat)
+	}
+
+	return files, nil
+}
+
+// traceEvent holds the fields of a trace2 event that describe a hook.
+type traceEvent struct {
+	Event      string `json:"event"`
+	SID        string `json:"sid"`
+	ChildID    int    `json:"child_id"`
+	ChildClass string `json:"child_class"`
+	HookName   string `json:"hook_name"`
+	Code       int    `json:"code"`
+}
+
+// readHookRuns reads the hooks run by a git command from its trace2 event
+// log. Git commands run by the hooks log to the same file, so only events
+// from the first session in it are used.
+func readHookRuns(path string) ([]HookRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sid   string
+		hooks []HookRun
+	)
+	children := make(map[int]int)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		var event traceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+
+		if sid == "" {
+			sid = event.SID
+		}
+		if event.SID != sid {
+			continue
+		}
+
+		switch event.Event {
+		case "child_start":
+			if event.ChildClass != "hook" {
+				continue
+			}
+
+			children[event.ChildID] = len(hooks)
+			hooks = append(hooks, HookRun{Name: event.HookName})
+
+		case "child_exit":
+			if i, ok := children[event.ChildID]; ok {
+				hooks[i].ExitCode = event.Code
+			}
+		}
+	}
+
+	return hooks, scanner.Err()
+}
+
+// failedHook returns the last hook that exited non-zero, or nil.
+func failedHook(hooks []HookRun) *HookRun {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hooks[i].ExitCode != 0 {
+			return &hooks[i]
+		}
+	}
+
+	return nil
+}
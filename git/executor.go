@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +19,26 @@ type ShellExecutor struct {
 	// WorkDir is the working directory for git commands.
 	// If empty, uses current directory.
 	WorkDir string
+
+	// Renames controls rename and copy detection in diffs.
+	Renames RenameOptions
+
+	// Binary includes binary file contents in diffs, so binary changes
+	// can be staged with git apply. Diffs that are only shown leave it
+	// off and look up binary sizes with ObjectSizes instead.
+	Binary bool
+
+	// Whitespace hides whitespace changes from diffs.
+	Whitespace WhitespaceOptions
+
+	// IndexFile, if set, is an index file used in place of the
+	// repository's own, as with GIT_INDEX_FILE. It must be an absolute
+	// path.
+	IndexFile string
+
+	// Autostash stashes local changes before a rebase starts and restores
+	// them once it ends, as with git rebase --autostash.
+	Autostash bool
 }
 
 // NewShellExecutor creates a new ShellExecutor.
@@ -28,11 +49,26 @@ func NewShellExecutor(workDir string) *ShellExecutor {
 // run executes a git command and returns stdout.
 func (e *ShellExecutor) run(
 	ctx context.Context, stdin io.Reader, args ...string,
+) (string, error) {
+	return e.runEnv(ctx, stdin, nil, args...)
+}
+
+// runEnv executes a git command with extra environment variables and
+// returns stdout.
+func (e *ShellExecutor) runEnv(
+	ctx context.Context, stdin io.Reader, extraEnv []string, args ...string,
 ) (string, error) {
 	cmd := exec.CommandContext(ctx, "git", args...)
 	if e.WorkDir != "" {
 		cmd.Dir = e.WorkDir
 	}
+	cmd.Env = e.env()
+	if len(extraEnv) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, extraEnv...)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -49,42 +85,486 @@ func (e *ShellExecutor) run(
 	return stdout.String(), nil
 }
 
+// env returns the environment for git commands, or nil to inherit ours.
+func (e *ShellExecutor) env() []string {
+	if e.IndexFile == "" {
+		return nil
+	}
+
+	return append(os.Environ(), "GIT_INDEX_FILE="+e.IndexFile)
+}
+
+// diffArgs builds the git arguments for an unstaged or staged diff.
+func (e *ShellExecutor) diffArgs(cached bool, paths []string) []string {
+	args := []string{"diff"}
+	if cached {
+		args = append(args, "--cached")
+	}
+
+	args = append(args, "--no-color")
+	args = append(args, e.Renames.args()...)
+
+	args = append(args, e.Whitespace.args()...)
+
+	// Full object ids let a patch be checked against the index, and let
+	// binary sizes be looked up when the contents are left out.
+	args = append(args, "--full-index")
+	if e.Binary {
+		args = append(args, "--binary")
+	}
+
+	return append(args, paths...)
+}
+
+// args returns the git diff arguments for these options.
+func (o RenameOptions) args() []string {
+	if o.Disabled {
+		return []string{"--no-renames"}
+	}
+
+	threshold := o.Threshold
+	if threshold <= 0 {
+		threshold = DefaultRenameThreshold
+	}
+
+	args := []string{fmt.Sprintf("--find-renames=%d%%", threshold)}
+	if o.Copies {
+		args = append(args, fmt.Sprintf("--find-copies=%d%%", threshold))
+	}
+
+	return args
+}
+
+// args returns the git diff arguments for these options.
+func (o WhitespaceOptions) args() []string {
+	var args []string
+	if o.IgnoreAll {
+		args = append(args, "--ignore-all-space")
+	}
+	if o.IgnoreBlankLines {
+		args = append(args, "--ignore-blank-lines")
+	}
+
+	return args
+}
+
 // Diff returns the unified diff for unstaged changes.
 func (e *ShellExecutor) Diff(
 	ctx context.Context, paths ...string,
 ) (string, error) {
-	args := []string{"diff", "--no-color"}
-	args = append(args, paths...)
-
-	return e.run(ctx, nil, args...)
+	return e.run(ctx, nil, e.diffArgs(false, paths)...)
 }
 
 // DiffCached returns the unified diff for staged changes.
 func (e *ShellExecutor) DiffCached(
 	ctx context.Context, paths ...string,
 ) (string, error) {
-	args := []string{"diff", "--cached", "--no-color"}
-	args = append(args, paths...)
+	return e.run(ctx, nil, e.diffArgs(true, paths)...)
+}
+
+// DiffCommits returns the unified diff between two commits, or between
+// from and the working tree when to is empty.
+func (e *ShellExecutor) DiffCommits(
+	ctx context.Context, from, to string, paths ...string,
+) (string, error) {
+	args := append(e.diffArgs(false, nil), from)
+	if to != "" {
+		args = append(args, to)
+	}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
 
 	return e.run(ctx, nil, args...)
 }
 
+// DiffStream starts git diff and returns its output as a stream.
+func (e *ShellExecutor) DiffStream(
+	ctx context.Context, cached bool, paths ...string,
+) (io.ReadCloser, error) {
+	args := e.diffArgs(cached, paths)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if e.WorkDir != "" {
+		cmd.Dir = e.WorkDir
+	}
+	cmd.Env = e.env()
+
+	stream := &cmdStream{cmd: cmd, args: args}
+	cmd.Stderr = &stream.stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open diff output: %w", err)
+	}
+	stream.stdout = stdout
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf(
+			"git %s failed: %w", strings.Join(args, " "), err,
+		)
+	}
+
+	return stream, nil
+}
+
+// cmdStream exposes the stdout of a running git command. Close waits for
+// the command to exit and reports its failure, if any.
+type cmdStream struct {
+	cmd    *exec.Cmd
+	args   []string
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+}
+
+// Read reads from the command's stdout.
+func (s *cmdStream) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+// Close drains any unread output and waits for the command to exit.
+func (s *cmdStream) Close() error {
+	_, _ = io.Copy(io.Discard, s.stdout)
+
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf(
+			"git %s failed: %w: %s",
+			strings.Join(s.args, " "), err, s.stderr.String(),
+		)
+	}
+
+	return nil
+}
+
+// IndexBlobs returns the object id staged for each of the given paths.
+func (e *ShellExecutor) IndexBlobs(
+	ctx context.Context, paths ...string,
+) (map[string]string, error) {
+	blobs := make(map[string]string, len(paths))
+	if len(paths) == 0 {
+		return blobs, nil
+	}
+
+	// Diff paths are relative to the repository root, so anchor the
+	// pathspecs there regardless of the working directory.
+	args := []string{"ls-files", "--stage", "--full-name", "-z", "--"}
+	for _, p := range paths {
+		args = append(args, ":(top,literal)"+p)
+	}
+
+	output, err := e.run(ctx, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is "<mode> <object> <stage>\t<path>\0".
+	for _, entry := range strings.Split(output, "\x00") {
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(info)
+		if len(fields) != 3 {
+			continue
+		}
+
+		blobs[path] = fields[1]
+	}
+
+	return blobs, nil
+}
+
+// ObjectSizes returns the size in bytes of each of the given objects. Ids
+// missing from the object database are omitted.
+func (e *ShellExecutor) ObjectSizes(
+	ctx context.Context, ids ...string,
+) (map[string]int64, error) {
+	sizes := make(map[string]int64, len(ids))
+	if len(ids) == 0 {
+		return sizes, nil
+	}
+
+	output, err := e.run(
+		ctx, strings.NewReader(strings.Join(ids, "\n")+"\n"),
+		"cat-file", "--batch-check=%(objectname) %(objectsize)",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is "<object> <size>", or "<id> missing".
+	for _, line := range strings.Split(output, "\n") {
+		id, sizeStr, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		sizes[id] = size
+	}
+
+	return sizes, nil
+}
+
 // ApplyPatch applies a patch to the staging area.
 func (e *ShellExecutor) ApplyPatch(
 	ctx context.Context, patch io.Reader,
 ) error {
-	_, err := e.run(ctx, patch, "apply", "--cached", "-")
+	// Patch paths are relative to the repository root. Run in a
+	// subdirectory, git apply would skip files outside of it.
+	root, err := e.Root(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.run(ctx, patch, "-C", root, "apply", "--cached", "-")
 
 	return err
 }
 
-// Commit creates a commit with the given message.
-func (e *ShellExecutor) Commit(ctx context.Context, message string) error {
-	_, err := e.run(ctx, nil, "commit", "-m", message)
+// ReadTree replaces the index with the tree of treeish, or empties it if
+// treeish is empty.
+func (e *ShellExecutor) ReadTree(ctx context.Context, treeish string) error {
+	args := []string{"read-tree", "--empty"}
+	if treeish != "" {
+		args = []string{"read-tree", treeish}
+	}
+
+	_, err := e.run(ctx, nil, args...)
 
 	return err
 }
 
+// WriteTree writes the index out as a tree and returns its hash.
+func (e *ShellExecutor) WriteTree(ctx context.Context) (string, error) {
+	output, err := e.run(ctx, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// CommitTree creates a commit object without touching HEAD, the index or
+// the working tree, and returns its hash.
+func (e *ShellExecutor) CommitTree(
+	ctx context.Context, opts CommitTreeOptions,
+) (string, error) {
+	args := []string{"commit-tree", opts.Tree}
+	for _, parent := range opts.Parents {
+		args = append(args, "-p", parent)
+	}
+	args = append(args, "-F", "-")
+
+	var env []string
+	if opts.Author != "" {
+		name, email, ok := strings.Cut(opts.Author, " <")
+		if !ok || !strings.HasSuffix(email, ">") {
+			return "", fmt.Errorf(
+				"invalid author %q: expected \"Name <email>\"",
+				opts.Author,
+			)
+		}
+
+		env = append(env,
+			"GIT_AUTHOR_NAME="+name,
+			"GIT_AUTHOR_EMAIL="+strings.TrimSuffix(email, ">"),
+		)
+	}
+	if !opts.Date.IsZero() {
+		env = append(env,
+			"GIT_AUTHOR_DATE="+opts.Date.Format(time.RFC3339),
+		)
+	}
+
+	output, err := e.runEnv(
+		ctx, strings.NewReader(opts.Message), env, args...,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// CommitMessage returns the full message of the commit rev names.
+func (e *ShellExecutor) CommitMessage(
+	ctx context.Context, rev string,
+) (string, error) {
+	output, err := e.run(ctx, nil, "log", "-1", "--format=%B", rev)
+	if err != nil {
+		return "", err
+	}
+
+	// Log adds a newline after the message's own.
+	return strings.TrimRight(output, "\n") + "\n", nil
+}
+
+// FileContent returns the content of path, relative to the repository
+// root, in the tree of rev.
+func (e *ShellExecutor) FileContent(
+	ctx context.Context, rev, path string,
+) (string, error) {
+	return e.run(ctx, nil, "cat-file", "blob", rev+":"+path)
+}
+
+// CommitDetails returns the commit rev names with its full message,
+// parents and committer.
+func (e *ShellExecutor) CommitDetails(
+	ctx context.Context, rev string,
+) (*CommitInfo, error) {
+	hash, err := e.RevParse(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	// NUL separates the fields, since the message may hold anything
+	// else.
+	format := strings.Join([]string{
+		"%H", "%h", "%s", "%an <%ae>", "%aI", "%cn <%ce>", "%cI", "%P",
+		"%B",
+	}, "%x00")
+
+	output, err := e.run(ctx, nil, "log", "-1", "--format="+format, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(output, "\x00", 9)
+	if len(parts) < 9 {
+		return nil, fmt.Errorf("unexpected log output for %s", rev)
+	}
+
+	date, _ := time.Parse(time.RFC3339, parts[4])
+	commitDate, _ := time.Parse(time.RFC3339, parts[6])
+
+	// Log adds a newline after the message's own.
+	message := strings.TrimRight(parts[8], "\n") + "\n"
+
+	return &CommitInfo{
+		Hash:       parts[0],
+		ShortHash:  parts[1],
+		Subject:    parts[2],
+		Author:     parts[3],
+		Date:       date,
+		Message:    message,
+		Parents:    strings.Fields(parts[7]),
+		Committer:  parts[5],
+		CommitDate: commitDate,
+	}, nil
+}
+
+// EmptyTree returns the hash of the empty tree, which a root commit's
+// changes are taken against. It depends on the repository's hash
+// algorithm.
+func (e *ShellExecutor) EmptyTree(ctx context.Context) (string, error) {
+	output, err := e.run(
+		ctx, strings.NewReader(""), "hash-object", "-t", "tree", "--stdin",
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// RevParse returns the full hash of the commit rev names.
+func (e *ShellExecutor) RevParse(
+	ctx context.Context, rev string,
+) (string, error) {
+	output, err := e.run(
+		ctx, nil, "rev-parse", "--verify", "--quiet", rev+"^{commit}",
+	)
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %q", rev)
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// Blame attributes each line of path, as of rev, to the commit that last
+// changed it, looking no further back than base if it's set.
+func (e *ShellExecutor) Blame(
+	ctx context.Context, base, rev, path string,
+) ([]BlameLine, error) {
+	root, err := e.Root(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revs := rev
+	if base != "" {
+		revs = base + ".." + rev
+	}
+
+	// Without --root, a root commit is reported as a boundary too.
+	output, err := e.run(
+		ctx, nil, "-C", root, "blame", "--porcelain", "--root", revs,
+		"--", path,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame %s: %w", path, err)
+	}
+
+	return parseBlamePorcelain(output)
+}
+
+// parseBlamePorcelain parses 'git blame --porcelain' output. Each line of
+// the file is introduced by a header naming its commit and final line
+// number. The first header for a commit is followed by details about it,
+// including "boundary" for a boundary commit.
+func parseBlamePorcelain(output string) ([]BlameLine, error) {
+	var (
+		lines    []BlameLine
+		commit   string
+		lineNum  int
+		header   = true
+		boundary = make(map[string]bool)
+	)
+
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case header:
+			if line == "" {
+				continue
+			}
+
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("unexpected blame header %q", line)
+			}
+
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("unexpected blame header %q", line)
+			}
+
+			commit, lineNum, header = fields[0], n, false
+
+		case strings.HasPrefix(line, "\t"):
+			for len(lines) < lineNum {
+				lines = append(lines, BlameLine{})
+			}
+			lines[lineNum-1].Commit = commit
+			header = true
+
+		case line == "boundary":
+			boundary[commit] = true
+		}
+	}
+
+	for i := range lines {
+		lines[i].Boundary = boundary[lines[i].Commit]
+	}
+
+	return lines, nil
+}
+
 // Reset unstages all staged changes.
 func (e *ShellExecutor) Reset(ctx context.Context) error {
 	_, err := e.run(ctx, nil, "reset", "HEAD")
@@ -92,6 +572,14 @@ func (e *ShellExecutor) Reset(ctx context.Context) error {
 	return err
 }
 
+// ResetSoft moves HEAD to rev, leaving the index and working tree as they
+// are.
+func (e *ShellExecutor) ResetSoft(ctx context.Context, rev string) error {
+	_, err := e.run(ctx, nil, "reset", "--soft", rev)
+
+	return err
+}
+
 // ResetPath unstages changes for a specific path.
 func (e *ShellExecutor) ResetPath(ctx context.Context, path string) error {
 	_, err := e.run(ctx, nil, "reset", "HEAD", "--", path)
@@ -161,6 +649,56 @@ func (e *ShellExecutor) gitDir(ctx context.Context) (string, error) {
 	return gitDir, nil
 }
 
+// IndexPath returns the path of the index file the executor uses.
+func (e *ShellExecutor) IndexPath(ctx context.Context) (string, error) {
+	if e.IndexFile != "" {
+		return e.IndexFile, nil
+	}
+
+	output, err := e.run(ctx, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSpace(output)
+	if !filepath.IsAbs(path) && e.WorkDir != "" {
+		path = filepath.Join(e.WorkDir, path)
+	}
+
+	return path, nil
+}
+
+// AddWorktree checks commit out, detached, into a new linked worktree at
+// dir. Checkout hooks are not run.
+func (e *ShellExecutor) AddWorktree(
+	ctx context.Context, dir, commit string,
+) error {
+	_, err := e.run(
+		ctx, nil, "worktree", "add", "--detach", "--no-checkout", dir,
+		commit,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Populating the worktree's index and files with read-tree, rather
+	// than a checkout, keeps post-checkout hooks out of it.
+	_, err = e.run(ctx, nil, "-C", dir, "read-tree", "-u", "--reset", "HEAD")
+	if err != nil {
+		_ = e.RemoveWorktree(ctx, dir)
+	}
+
+	return err
+}
+
+// RemoveWorktree deletes the linked worktree at dir, along with any changes
+// made in it.
+func (e *ShellExecutor) RemoveWorktree(ctx context.Context, dir string) error {
+	_, err := e.run(ctx, nil, "worktree", "remove", "--force", dir)
+
+	return err
+}
+
 // RebaseList returns commits that would be rebased onto the given base.
 func (e *ShellExecutor) RebaseList(
 	ctx context.Context, base string,
@@ -207,9 +745,12 @@ func (e *ShellExecutor) RebaseList(
 func (e *ShellExecutor) RebaseStart(
 	ctx context.Context, base, editor string,
 ) error {
-	cmd := exec.CommandContext(
-		ctx, "git", "rebase", "-i", base,
-	)
+	args := []string{"rebase", "-i"}
+	if e.Autostash {
+		args = append(args, "--autostash")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append(args, base)...)
 	if e.WorkDir != "" {
 		cmd.Dir = e.WorkDir
 	}
@@ -2,11 +2,13 @@ package git_test
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/roasbeef/hunk/git"
 	"github.com/stretchr/testify/require"
@@ -71,6 +73,133 @@ func TestShellExecutorDiff(t *testing.T) {
 	_, _ = executor.Diff(ctx, "nonexistent.go")
 }
 
+func TestShellExecutorDiffRenames(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	content := "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	writeFile(t, dir, "old.txt", content)
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	// Rename with a one-line edit: 80% similar.
+	gitCmd(t, dir, "mv", "old.txt", "new.txt")
+	writeFile(t, dir, "new.txt", strings.Replace(content, "3", "three", 1))
+	gitCmd(t, dir, "add", "-A")
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		renames git.RenameOptions
+		want    string
+		notWant string
+	}{
+		{
+			name: "default threshold",
+			want: "rename from old.txt",
+		},
+		{
+			name:    "threshold above similarity",
+			renames: git.RenameOptions{Threshold: 90},
+			want:    "deleted file mode",
+			notWant: "rename from",
+		},
+		{
+			name:    "disabled",
+			renames: git.RenameOptions{Disabled: true},
+			want:    "deleted file mode",
+			notWant: "rename from",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := git.NewShellExecutor(dir)
+			executor.Renames = tt.renames
+
+			diffText, err := executor.DiffCached(ctx)
+			require.NoError(t, err)
+			require.Contains(t, diffText, tt.want)
+
+			if tt.notWant != "" {
+				require.NotContains(t, diffText, tt.notWant)
+			}
+		})
+	}
+
+	// Copy detection only reports copies of files modified in the diff.
+	writeFile(t, dir, "copy.txt", content)
+	writeFile(t, dir, "new.txt", content+"line 6\n")
+	gitCmd(t, dir, "add", "-A")
+
+	executor := git.NewShellExecutor(dir)
+	executor.Renames = git.RenameOptions{Copies: true}
+
+	diffText, err := executor.DiffCached(ctx)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "copy to copy.txt")
+}
+
+func TestShellExecutorDiffBinary(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.bin", "abc\x00def")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "a.bin", "abc\x00defg")
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+
+	diffText, err := executor.Diff(ctx)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "Binary files a/a.bin and b/a.bin differ")
+	require.NotContains(t, diffText, "GIT binary patch")
+
+	// Object ids are given in full, so the sizes can be looked up.
+	oldBlob := strings.TrimSpace(gitCmd(t, dir, "rev-parse", ":a.bin"))
+	require.Contains(t, diffText, "index "+oldBlob+"..")
+
+	sizes, err := executor.ObjectSizes(ctx, oldBlob, strings.Repeat("1", 40))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{oldBlob: 7}, sizes)
+
+	executor.Binary = true
+
+	diffText, err = executor.Diff(ctx)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "GIT binary patch\nliteral 8\n")
+}
+
+func TestShellExecutorDiffWhitespace(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "func a() {\nreturn\n}\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "func a() {\n\n\treturn\n}\n")
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+
+	diffText, err := executor.Diff(ctx)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "+\treturn")
+
+	executor.Whitespace = git.WhitespaceOptions{
+		IgnoreAll: true, IgnoreBlankLines: true,
+	}
+
+	diffText, err = executor.Diff(ctx)
+	require.NoError(t, err)
+	require.Empty(t, diffText)
+}
+
 func TestShellExecutorDiffCached(t *testing.T) {
 	dir, cleanup := setupTestRepo(t)
 	defer cleanup()
@@ -125,6 +254,34 @@ func TestShellExecutorApplyPatch(t *testing.T) {
 	require.Contains(t, diffText, "+// Added via patch.")
 }
 
+func TestShellExecutorIndexBlobs(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	writeFile(t, dir, "sub/util.go", "package sub\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	want := strings.TrimSpace(gitCmd(t, dir, "rev-parse", ":main.go"))
+
+	// Paths are resolved from the repository root even when running in a
+	// subdirectory.
+	executor := git.NewShellExecutor(filepath.Join(dir, "sub"))
+	ctx := context.Background()
+
+	blobs, err := executor.IndexBlobs(ctx, "main.go", "sub/util.go", "missing.go")
+	require.NoError(t, err)
+	require.Len(t, blobs, 2)
+	require.Equal(t, want, blobs["main.go"])
+	require.Len(t, blobs["sub/util.go"], 40)
+
+	blobs, err = executor.IndexBlobs(ctx)
+	require.NoError(t, err)
+	require.Empty(t, blobs)
+}
+
 func TestShellExecutorCommit(t *testing.T) {
 	dir, cleanup := setupTestRepo(t)
 	defer cleanup()
@@ -137,12 +294,274 @@ func TestShellExecutorCommit(t *testing.T) {
 	ctx := context.Background()
 
 	// Commit.
-	err := executor.Commit(ctx, "test commit")
+	result, err := executor.Commit(ctx, git.CommitOptions{
+		Message: "test commit",
+	})
 	require.NoError(t, err)
 
 	// Verify commit exists.
 	log := gitCmd(t, dir, "log", "--oneline")
 	require.Contains(t, log, "test commit")
+
+	// The result describes the new root commit.
+	require.Equal(t, strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD")),
+		result.Hash)
+	require.Equal(t,
+		strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD^{tree}")),
+		result.Tree)
+	require.Empty(t, result.Parents)
+	require.Equal(t, "test commit", result.Subject)
+	require.Equal(t, []git.FileStat{
+		{Path: "main.go", Additions: 3},
+	}, result.Files)
+	require.Empty(t, result.Hooks)
+}
+
+// TestShellExecutorCommitHooks verifies that hook runs are reported, and a
+// hook rejecting the commit fails with a HookError.
+func TestShellExecutorCommitHooks(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	hooks := filepath.Join(dir, ".git", "hooks")
+	require.NoError(t, os.MkdirAll(hooks, 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(hooks, "pre-commit"),
+		[]byte("#!/bin/sh\necho lint ok\n"), 0o755,
+	))
+
+	executor := git.NewShellExecutor(dir)
+	ctx := context.Background()
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	result, err := executor.Commit(ctx, git.CommitOptions{Message: "two"})
+	require.NoError(t, err)
+	require.Equal(t, []git.HookRun{{Name: "pre-commit"}}, result.Hooks)
+	require.Equal(t, "lint ok\n", result.HookOutput)
+	require.Len(t, result.Parents, 1)
+	require.Equal(t, []git.FileStat{
+		{Path: "main.go", Additions: 2},
+	}, result.Files)
+
+	require.NoError(t, os.WriteFile(
+		filepath.Join(hooks, "commit-msg"),
+		[]byte("#!/bin/sh\necho bad message >&2\nexit 3\n"), 0o755,
+	))
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	_, err = executor.Commit(ctx, git.CommitOptions{Message: "three"})
+
+	var hookErr *git.HookError
+	require.ErrorAs(t, err, &hookErr)
+	require.Equal(t, "commit-msg", hookErr.Hook)
+	require.Equal(t, 3, hookErr.ExitCode)
+	require.Equal(t, "lint ok\nbad message\n", hookErr.Output)
+}
+
+// TestShellExecutorCommitUnreadableTrace verifies that a commit that was
+// made is returned even if the hook trace can't be read, while a failed
+// commit is still reported as an error.
+func TestShellExecutorCommitUnreadableTrace(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	// Hooks inherit the trace path, so a hook can corrupt the trace.
+	hooks := filepath.Join(dir, ".git", "hooks")
+	require.NoError(t, os.MkdirAll(hooks, 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(hooks, "post-commit"),
+		[]byte("#!/bin/sh\necho garbage >> \"$GIT_TRACE2_EVENT\"\n"),
+		0o755,
+	))
+
+	executor := git.NewShellExecutor(dir)
+	ctx := context.Background()
+
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	result, err := executor.Commit(ctx, git.CommitOptions{Message: "two"})
+	require.NoError(t, err)
+	require.Equal(t, "two", result.Subject)
+	require.Empty(t, result.Hooks)
+	require.Equal(t, strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD")),
+		result.Hash)
+
+	require.NoError(t, os.WriteFile(
+		filepath.Join(hooks, "pre-commit"),
+		[]byte("#!/bin/sh\necho garbage >> \"$GIT_TRACE2_EVENT\"\n"+
+			"exit 1\n"), 0o755,
+	))
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "main.go")
+
+	_, err = executor.Commit(ctx, git.CommitOptions{Message: "three"})
+	require.ErrorContains(t, err, "failed to read hook results")
+	require.Contains(t, gitCmd(t, dir, "log", "-1", "--format=%s"), "two")
+}
+
+// TestShellExecutorCommitOptions verifies that commit options reach git.
+func TestShellExecutorCommitOptions(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	executor := git.NewShellExecutor(dir)
+	ctx := context.Background()
+
+	// Nothing is staged, so this needs AllowEmpty.
+	writeFile(t, dir, "msg.txt", "subject line\n\nbody text\n")
+	_, err := executor.Commit(ctx, git.CommitOptions{
+		MessageFile: "msg.txt",
+		Author:      "Other Person <other@test.com>",
+		Date:        "2020-01-02T03:04:05Z",
+		Signoff:     true,
+		Trailers:    []string{"Refs=123", "Reviewed-by: Someone"},
+		AllowEmpty:  true,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "Other Person <other@test.com> 2020-01-02T03:04:05+00:00\n",
+		gitCmd(t, dir, "log", "-1", "--format=%an <%ae> %aI"))
+
+	body := gitCmd(t, dir, "log", "-1", "--format=%B")
+	require.Contains(t, body, "subject line\n\nbody text\n")
+	require.Contains(t, body, "Signed-off-by: Test User <test@test.com>")
+	require.Contains(t, body, "Refs: 123")
+	require.Contains(t, body, "Reviewed-by: Someone")
+
+	// Amending without a message keeps the old one.
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitCmd(t, dir, "add", "main.go")
+	_, err = executor.Commit(ctx, git.CommitOptions{Amend: true})
+	require.NoError(t, err)
+	require.Equal(t, "subject line\n",
+		gitCmd(t, dir, "log", "-1", "--format=%s"))
+	require.Equal(t, "2\n", gitCmd(t, dir, "rev-list", "--count", "HEAD"))
+
+	// A fixup gets a generated subject.
+	writeFile(t, dir, "main.go",
+		"package main\n\nfunc main() {}\n\n// x\n")
+	gitCmd(t, dir, "add", "main.go")
+	_, err = executor.Commit(ctx, git.CommitOptions{Fixup: "HEAD~1"})
+	require.NoError(t, err)
+	require.Equal(t, "fixup! initial\n",
+		gitCmd(t, dir, "log", "-1", "--format=%s"))
+
+	// Without AllowEmpty, an empty commit fails.
+	_, err = executor.Commit(ctx, git.CommitOptions{Message: "empty"})
+	require.Error(t, err)
+}
+
+// TestShellExecutorIndexFile verifies that an executor with IndexFile set
+// builds commits from that index, leaving the repository's own alone.
+func TestShellExecutorIndexFile(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.txt", "a\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "a.txt", "a\nb\n")
+	writeFile(t, dir, "c.txt", "c\n")
+	gitCmd(t, dir, "add", "c.txt")
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+	executor.IndexFile = filepath.Join(t.TempDir(), "index")
+
+	head, err := executor.RevParse(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Len(t, head, 40)
+
+	_, err = executor.RevParse(ctx, "no-such-branch")
+	require.ErrorContains(t, err, "unknown commit")
+
+	path, err := executor.IndexPath(ctx)
+	require.NoError(t, err)
+	require.Equal(t, executor.IndexFile, path)
+
+	path, err = git.NewShellExecutor(dir).IndexPath(ctx)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, ".git", "index"), path)
+
+	require.NoError(t, executor.ReadTree(ctx, "HEAD"))
+
+	// The temporary index starts at HEAD, so c.txt isn't staged in it.
+	staged, err := executor.DiffCached(ctx)
+	require.NoError(t, err)
+	require.Empty(t, staged)
+
+	diffText, err := executor.Diff(ctx)
+	require.NoError(t, err)
+	require.NoError(t, executor.ApplyPatch(ctx, strings.NewReader(diffText)))
+	_, err = executor.Commit(ctx, git.CommitOptions{
+		Message: "from temp index",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "a.txt\n",
+		gitCmd(t, dir, "show", "--name-only", "--format=", "HEAD"))
+
+	// The real index still holds c.txt and the old a.txt.
+	require.Equal(t, "c\n", gitCmd(t, dir, "show", ":c.txt"))
+	require.Equal(t, "a\n", gitCmd(t, dir, "show", ":a.txt"))
+
+	require.NoError(t, executor.ReadTree(ctx, ""))
+	staged, err = executor.DiffCached(ctx)
+	require.NoError(t, err)
+	require.Contains(t, staged, "deleted file mode")
+}
+
+// TestShellExecutorBlame verifies that lines are attributed to the commits
+// that last changed them, and that lines older than the base are marked as
+// boundary lines.
+func TestShellExecutorBlame(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "one\ntwo\nthree\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+	base := strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD"))
+
+	writeFile(t, dir, "main.go", "one\nTWO\nthree\nfour\n")
+	gitCmd(t, dir, "commit", "-am", "second")
+	second := strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD"))
+
+	executor := git.NewShellExecutor(dir)
+	ctx := context.Background()
+
+	lines, err := executor.Blame(ctx, base, "HEAD", "main.go")
+	require.NoError(t, err)
+	require.Equal(t, []git.BlameLine{
+		{Commit: base, Boundary: true},
+		{Commit: second},
+		{Commit: base, Boundary: true},
+		{Commit: second},
+	}, lines)
+
+	lines, err = executor.Blame(ctx, "", "HEAD~1", "main.go")
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, git.BlameLine{Commit: base}, lines[1])
 }
 
 func TestShellExecutorReset(t *testing.T) {
@@ -296,3 +715,210 @@ func gitCmd(t *testing.T, dir string, args ...string) string {
 
 	return string(out)
 }
+
+func TestShellExecutorDiffStream(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "main.go", "package main\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial")
+
+	writeFile(t, dir, "main.go", "package main\n// staged\n")
+	gitCmd(t, dir, "add", "main.go")
+	writeFile(t, dir, "main.go", "package main\n// staged\n// unstaged\n")
+
+	executor := git.NewShellExecutor(dir)
+	ctx := context.Background()
+
+	read := func(cached bool) string {
+		stream, err := executor.DiffStream(ctx, cached)
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		return string(data)
+	}
+
+	require.Contains(t, read(false), "+// unstaged")
+	require.Contains(t, read(true), "+// staged")
+
+	// Git failures surface when the stream is closed.
+	stream, err := git.NewShellExecutor(t.TempDir()).DiffStream(ctx, false)
+	require.NoError(t, err)
+	require.Error(t, stream.Close())
+}
+
+// TestShellExecutorCommitTree verifies that a commit object can be built
+// from a tree without moving HEAD, keeping a given author and date.
+func TestShellExecutorCommitTree(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.txt", "a\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "initial\n\nBody.")
+
+	writeFile(t, dir, "a.txt", "a\nb\n")
+	gitCmd(t, dir, "add", "-A")
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+
+	head, err := executor.RevParse(ctx, "HEAD")
+	require.NoError(t, err)
+
+	message, err := executor.CommitMessage(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "initial\n\nBody.\n", message)
+
+	diffText, err := executor.DiffCommits(ctx, "HEAD", "HEAD")
+	require.NoError(t, err)
+	require.Empty(t, diffText)
+
+	tree, err := executor.WriteTree(ctx)
+	require.NoError(t, err)
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	hash, err := executor.CommitTree(ctx, git.CommitTreeOptions{
+		Tree:    tree,
+		Parents: []string{head},
+		Message: "second\n",
+		Author:  "Other Author <other@example.com>",
+		Date:    date,
+	})
+	require.NoError(t, err)
+
+	// HEAD hasn't moved.
+	current, err := executor.RevParse(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, head, current)
+
+	require.Equal(t,
+		"second|Other Author|other@example.com|2020-01-02T03:04:05+00:00|"+
+			head+"\n",
+		gitCmd(t, dir, "log", "-1", "--format=%s|%an|%ae|%aI|%P", hash))
+
+	diffText, err = executor.DiffCommits(ctx, head, hash)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "+b")
+
+	diffText, err = executor.DiffCommits(ctx, head, hash, "other.txt")
+	require.NoError(t, err)
+	require.Empty(t, diffText)
+
+	// Without a second commit, the diff runs to the working tree.
+	diffText, err = executor.DiffCommits(ctx, head, "")
+	require.NoError(t, err)
+	require.Contains(t, diffText, "+b")
+
+	// A soft reset moves HEAD onto the new commit, whose tree matches the
+	// index, so nothing is left staged.
+	require.NoError(t, executor.ResetSoft(ctx, hash))
+	current, err = executor.RevParse(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, hash, current)
+
+	staged, err := executor.DiffCached(ctx)
+	require.NoError(t, err)
+	require.Empty(t, staged)
+
+	_, err = executor.CommitTree(ctx, git.CommitTreeOptions{
+		Tree:    tree,
+		Message: "bad",
+		Author:  "nobody",
+	})
+	require.ErrorContains(t, err, "invalid author")
+}
+
+func TestShellExecutorCommitDetails(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.txt", "a\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "root\n\nBody line.")
+	gitCmd(t, dir, "branch", "side")
+
+	writeFile(t, dir, "a.txt", "a\nb\n")
+	gitCmd(t, dir, "commit", "-am", "second")
+
+	gitCmd(t, dir, "checkout", "-q", "side")
+	writeFile(t, dir, "c.txt", "c\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "side")
+	gitCmd(t, dir, "merge", "-q", "--no-ff", "-m", "merge", "-")
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+
+	root, err := executor.CommitDetails(ctx, "HEAD^1~1")
+	require.NoError(t, err)
+	require.Equal(t, "root", root.Subject)
+	require.Equal(t, "root\n\nBody line.\n", root.Message)
+	require.Empty(t, root.Parents)
+	require.Equal(t, "Test User <test@test.com>", root.Author)
+	require.Equal(t, "Test User <test@test.com>", root.Committer)
+	require.False(t, root.CommitDate.IsZero())
+
+	merge, err := executor.CommitDetails(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "merge", merge.Subject)
+	require.Len(t, merge.Parents, 2)
+
+	side, err := executor.RevParse(ctx, "side~1")
+	require.NoError(t, err)
+	require.Equal(t, side, merge.Parents[0])
+
+	_, err = executor.CommitDetails(ctx, "nope")
+	require.ErrorContains(t, err, "unknown commit")
+
+	// A root commit's changes are taken against the empty tree.
+	empty, err := executor.EmptyTree(ctx)
+	require.NoError(t, err)
+
+	diffText, err := executor.DiffCommits(ctx, empty, root.Hash)
+	require.NoError(t, err)
+	require.Contains(t, diffText, "new file mode")
+
+	content, err := executor.FileContent(ctx, root.Hash, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a\n", content)
+
+	_, err = executor.FileContent(ctx, root.Hash, "c.txt")
+	require.Error(t, err)
+}
+
+func TestShellExecutorWorktree(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeFile(t, dir, "a.txt", "a\n")
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-m", "first")
+
+	// A checkout hook must not run for the new worktree.
+	hook := filepath.Join(dir, ".git", "hooks", "post-checkout")
+	require.NoError(t, os.WriteFile(
+		hook, []byte("#!/bin/sh\ntouch hook-ran\n"), 0755,
+	))
+
+	ctx := context.Background()
+	executor := git.NewShellExecutor(dir)
+
+	wt := filepath.Join(t.TempDir(), "wt")
+	require.NoError(t, executor.AddWorktree(ctx, wt, "HEAD"))
+
+	content, err := os.ReadFile(filepath.Join(wt, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a\n", string(content))
+	require.NoFileExists(t, filepath.Join(wt, "hook-ran"))
+	require.Empty(t, gitCmd(t, wt, "status", "--porcelain"))
+
+	writeFile(t, wt, "b.txt", "b\n")
+	require.NoError(t, executor.RemoveWorktree(ctx, wt))
+	require.NoDirExists(t, wt)
+	require.NotContains(t, gitCmd(t, dir, "worktree", "list"), wt)
+}
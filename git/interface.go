@@ -19,16 +19,79 @@ type Executor interface {
 	// DiffCached returns the unified diff for staged changes.
 	DiffCached(ctx context.Context, paths ...string) (string, error)
 
+	// DiffStream returns the unstaged (or, if cached, staged) diff as a
+	// stream so large diffs can be processed before git finishes. The
+	// caller must close the stream, which reports any git failure.
+	DiffStream(
+		ctx context.Context, cached bool, paths ...string,
+	) (io.ReadCloser, error)
+
+	// DiffCommits returns the unified diff between two commits, or
+	// between from and the working tree when to is empty.
+	DiffCommits(
+		ctx context.Context, from, to string, paths ...string,
+	) (string, error)
+
+	// IndexBlobs returns the full object id staged in the index for each
+	// of the given paths. Paths missing from the index are omitted.
+	IndexBlobs(ctx context.Context, paths ...string) (map[string]string, error)
+
+	// ObjectSizes returns the size in bytes of each of the given objects.
+	// Ids missing from the object database are omitted.
+	ObjectSizes(ctx context.Context, ids ...string) (map[string]int64, error)
+
 	// ApplyPatch applies a patch to the staging area.
 	// The patch is read from the provided reader.
 	ApplyPatch(ctx context.Context, patch io.Reader) error
 
-	// Commit creates a commit with the given message.
-	Commit(ctx context.Context, message string) error
+	// Commit creates a commit from the staged changes. A commit rejected
+	// by a hook fails with a *HookError.
+	Commit(ctx context.Context, opts CommitOptions) (*CommitResult, error)
+
+	// ReadTree replaces the index with the tree of treeish, or empties it
+	// if treeish is empty.
+	ReadTree(ctx context.Context, treeish string) error
+
+	// IndexPath returns the path of the index file the executor uses.
+	IndexPath(ctx context.Context) (string, error)
+
+	// WriteTree writes the index out as a tree and returns its hash.
+	WriteTree(ctx context.Context) (string, error)
+
+	// CommitTree creates a commit object without touching HEAD, the index
+	// or the working tree, and returns its hash.
+	CommitTree(ctx context.Context, opts CommitTreeOptions) (string, error)
+
+	// CommitMessage returns the full message of the commit rev names.
+	CommitMessage(ctx context.Context, rev string) (string, error)
+
+	// CommitDetails returns the commit rev names with its full message,
+	// parents and committer.
+	CommitDetails(ctx context.Context, rev string) (*CommitInfo, error)
+
+	// FileContent returns the content of path, relative to the repository
+	// root, in the tree of rev.
+	FileContent(ctx context.Context, rev, path string) (string, error)
+
+	// EmptyTree returns the hash of the empty tree, which a root commit's
+	// changes are taken against.
+	EmptyTree(ctx context.Context) (string, error)
+
+	// RevParse returns the full hash of the commit rev names.
+	RevParse(ctx context.Context, rev string) (string, error)
+
+	// Blame attributes each line of path, as of rev, to the commit that
+	// last changed it. With a base, lines older than base are attributed
+	// to boundary commits instead. Line n is at index n-1.
+	Blame(ctx context.Context, base, rev, path string) ([]BlameLine, error)
 
 	// Reset unstages all staged changes.
 	Reset(ctx context.Context) error
 
+	// ResetSoft moves HEAD to rev, leaving the index and working tree as
+	// they are.
+	ResetSoft(ctx context.Context, rev string) error
+
 	// ResetPath unstages changes for a specific path.
 	ResetPath(ctx context.Context, path string) error
 
@@ -38,6 +101,14 @@ type Executor interface {
 	// Root returns the repository root directory.
 	Root(ctx context.Context) (string, error)
 
+	// AddWorktree checks commit out, detached, into a new linked worktree
+	// at dir.
+	AddWorktree(ctx context.Context, dir, commit string) error
+
+	// RemoveWorktree deletes the linked worktree at dir, along with any
+	// changes made in it.
+	RemoveWorktree(ctx context.Context, dir string) error
+
 	// RebaseList returns commits that would be rebased onto the given base.
 	RebaseList(ctx context.Context, base string) ([]CommitInfo, error)
 
@@ -58,6 +129,154 @@ type Executor interface {
 	RebaseSkip(ctx context.Context) error
 }
 
+// DefaultRenameThreshold is the similarity percentage git uses by default to
+// pair a deleted file with an added one as a rename.
+const DefaultRenameThreshold = 50
+
+// RenameOptions controls rename and copy detection in diffs. The zero value
+// detects renames at git's default threshold.
+type RenameOptions struct {
+	// Disabled turns off rename and copy detection, so renamed files show
+	// up as a deletion plus an addition.
+	Disabled bool
+
+	// Threshold is the minimum similarity percentage (1-100) for a pair of
+	// files to be treated as a rename or copy. Zero uses
+	// DefaultRenameThreshold.
+	Threshold int
+
+	// Copies also detects files copied from another file modified in the
+	// same diff.
+	Copies bool
+}
+
+// WhitespaceOptions hides whitespace changes from diffs. A diff taken with
+// any of these set is for reading only: its context lines no longer match
+// the index, so it can't be used to build a patch.
+type WhitespaceOptions struct {
+	// IgnoreAll ignores whitespace when comparing lines.
+	IgnoreAll bool
+
+	// IgnoreBlankLines ignores changes whose lines are all blank.
+	IgnoreBlankLines bool
+}
+
+// CommitOptions controls how a commit is created.
+type CommitOptions struct {
+	// Message is the commit message.
+	Message string
+
+	// MessageFile is a file to read the message from instead, relative to
+	// the working directory.
+	MessageFile string
+
+	// Amend replaces the tip of the current branch rather than adding a
+	// commit on top of it. Without a message, the old message is kept.
+	Amend bool
+
+	// Fixup is a commit to create a "fixup!" commit for, to be folded into
+	// it by an autosquash rebase. Any message is added below the generated
+	// subject.
+	Fixup string
+
+	// Squash is a commit to create a "squash!" commit for, like Fixup but
+	// keeping this commit's message when the two are combined.
+	Squash string
+
+	// Author overrides the commit author, in "Name <email>" form.
+	Author string
+
+	// Date overrides the author date, in any format git accepts.
+	Date string
+
+	// Signoff adds a Signed-off-by trailer for the committer.
+	Signoff bool
+
+	// Trailers are added to the message, each as "key=value" or
+	// "key: value".
+	Trailers []string
+
+	// AllowEmpty permits a commit that doesn't change the tree.
+	AllowEmpty bool
+}
+
+// CommitTreeOptions describes a commit object to create.
+type CommitTreeOptions struct {
+	// Tree is the hash of the commit's tree.
+	Tree string
+
+	// Parents are the hashes of the commit's parents.
+	Parents []string
+
+	// Message is the commit message.
+	Message string
+
+	// Author overrides the author, in "Name <email>" form.
+	Author string
+
+	// Date overrides the author date.
+	Date time.Time
+}
+
+// CommitResult describes a newly created commit.
+type CommitResult struct {
+	// Hash is the full commit hash.
+	Hash string
+
+	// Tree is the hash of the commit's tree.
+	Tree string
+
+	// Parents are the hashes of the commit's parents, none for a root
+	// commit.
+	Parents []string
+
+	// Subject is the first line of the commit message.
+	Subject string
+
+	// Files lists the files the commit changed relative to its first
+	// parent.
+	Files []FileStat
+
+	// Hooks lists the hooks git ran while committing, in order.
+	Hooks []HookRun
+
+	// HookOutput is everything the hooks printed.
+	HookOutput string
+}
+
+// FileStat counts the lines changed in one file.
+type FileStat struct {
+	// Path is the file path relative to repo root.
+	Path string
+
+	// Additions and Deletions count the changed lines. Both are zero for
+	// a binary file.
+	Additions int
+	Deletions int
+
+	// Binary indicates git saw the file as binary.
+	Binary bool
+}
+
+// HookRun records a hook that git ran.
+type HookRun struct {
+	// Name is the hook's name, e.g. "pre-commit".
+	Name string
+
+	// ExitCode is the hook's exit status.
+	ExitCode int
+}
+
+// BlameLine attributes a line to the commit that last changed it.
+type BlameLine struct {
+	// Commit is the full hash of the commit.
+	Commit string
+
+	// Boundary is true if the line predates the blame's base, so Commit
+	// is only where the search stopped.
+	Boundary bool
+}
+
 // RepoStatus represents the current state of the repository.
 type RepoStatus struct {
 	// StagedFiles lists files with staged changes.
@@ -101,6 +320,20 @@ type CommitInfo struct {
 
 	// Date is when the commit was authored.
 	Date time.Time
+
+	// Message is the full commit message. It is only set by
+	// CommitDetails, as are the fields below.
+	Message string
+
+	// Parents are the full hashes of the commit's parents, first parent
+	// first. A root commit has none.
+	Parents []string
+
+	// Committer is the committer in "Name <email>" format.
+	Committer string
+
+	// CommitDate is when the commit was committed.
+	CommitDate time.Time
 }
 
 // RebaseStateType indicates the current state of a rebase operation.
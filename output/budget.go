@@ -0,0 +1,324 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// Budget limits how much of a diff is shown in full, so that a large diff
+// fits in an agent's context. Files are shown in order until a limit is
+// reached; the rest are listed as one-line summaries. Zero limits are
+// unlimited.
+type Budget struct {
+	// MaxLines is the number of diff lines that may be shown.
+	MaxLines int
+
+	// MaxFiles is the number of files that may be shown.
+	MaxFiles int
+
+	// Offset skips this many files, to page through a diff.
+	Offset int
+
+	// HunkOffset skips this many hunks of the first file after Offset, to
+	// page through a file that doesn't fit on one page.
+	HunkOffset int
+
+	// HideContext leaves context lines out of the line count, for output
+	// that doesn't show them.
+	HideContext bool
+}
+
+// Enabled reports whether the budget limits the output at all.
+func (b Budget) Enabled() bool {
+	return b.MaxLines > 0 || b.MaxFiles > 0 || b.Offset > 0 ||
+		b.HunkOffset > 0
+}
+
+// Truncation reports what a budget left out of the output.
+type Truncation struct {
+	// Offset is the number of files skipped before the first one shown.
+	Offset int `json:"offset"`
+
+	// HunkOffset is the number of hunks of the first file skipped before
+	// the first one shown.
+	HunkOffset int `json:"hunk_offset,omitempty"`
+
+	// TotalFiles is the number of files in the whole diff.
+	TotalFiles int `json:"total_files"`
+
+	// ShownFiles is the number of files shown, fully or in part.
+	ShownFiles int `json:"shown_files"`
+
+	// ShownLines is the number of diff lines shown.
+	ShownLines int `json:"shown_lines"`
+
+	// Files summarizes each file after the offset whose hunks were left
+	// out, in diff order.
+	Files []TruncatedFile `json:"files"`
+
+	// NextOffset and NextHunkOffset are the --offset and --hunk-offset
+	// that continue with the first hunk not shown. Both are zero if none
+	// are left.
+	NextOffset     int `json:"next_offset,omitempty"`
+	NextHunkOffset int `json:"next_hunk_offset,omitempty"`
+}
+
+// TruncatedFile summarizes the hunks of a file that a budget left out.
+type TruncatedFile struct {
+	Path string `json:"path"`
+
+	// ShownHunks is the number of leading hunks shown in full on this
+	// page, after any skipped by the hunk offset. Zero means the file is
+	// only summarized.
+	ShownHunks int `json:"shown_hunks"`
+
+	// Hunks is the number of hunks left out.
+	Hunks int `json:"hunks"`
+
+	// Additions and Deletions count the changes in the hunks left out.
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+
+	// Ranges are the line ranges of the hunks left out, in the syntax
+	// 'hunk stage' accepts.
+	Ranges []string `json:"ranges,omitempty"`
+
+	Binary bool `json:"binary,omitempty"`
+}
+
+// Apply returns the part of parsed that fits the budget, along with a
+// report of what was left out. The report is nil if the whole diff is
+// shown.
+//
+// A file that doesn't fit in the lines left is summarized, as is every file
+// after it. Only a file that opens the page is shown in part: as many of its
+// hunks as fit, but at least one, and the next page picks up at the first
+// hunk left out. Paging with NextOffset and NextHunkOffset thus reaches
+// every hunk and always moves forward.
+func (b Budget) Apply(
+	parsed *diff.ParsedDiff,
+) (*diff.ParsedDiff, *Truncation) {
+	if !b.Enabled() {
+		return parsed, nil
+	}
+
+	all := parsed.AllFiles()
+
+	trunc := &Truncation{
+		Offset:     b.Offset,
+		HunkOffset: b.HunkOffset,
+		TotalFiles: len(all),
+		Files:      make([]TruncatedFile, 0),
+	}
+
+	var (
+		shown []*diff.FileDiff
+		done  bool
+
+		// partHunks is the number of hunks shown of a first file
+		// that is only shown in part.
+		partHunks int
+	)
+
+	for i, file := range all[min(b.Offset, len(all)):] {
+		if i == 0 && b.HunkOffset > 0 {
+			rest := *file
+			rest.Hunks = file.Hunks[min(b.HunkOffset, len(file.Hunks)):]
+			file = &rest
+		}
+
+		if done || b.MaxFiles > 0 && len(shown) == b.MaxFiles {
+			done = true
+			trunc.Files = append(trunc.Files, truncatedFile(file, 0))
+
+			continue
+		}
+
+		n := b.fitHunks(file, trunc.ShownLines)
+		if n < len(file.Hunks) {
+			done = true
+
+			// Leave a file that doesn't fit for the next page, unless
+			// it opens this one.
+			if len(shown) > 0 {
+				trunc.Files = append(trunc.Files, truncatedFile(file, 0))
+
+				continue
+			}
+
+			// Show at least one hunk, however long, so that the
+			// next page starts further on.
+			n = max(n, 1)
+			if n < len(file.Hunks) {
+				partHunks = n
+				trunc.Files = append(
+					trunc.Files, truncatedFile(file, n),
+				)
+
+				part := *file
+				part.Hunks = file.Hunks[:n]
+				file = &part
+			}
+		}
+
+		shown = append(shown, file)
+		for _, hunk := range file.Hunks {
+			trunc.ShownLines += b.hunkLines(hunk)
+		}
+	}
+
+	trunc.ShownFiles = len(shown)
+
+	// The next page picks up the rest of a file shown in part, or else
+	// the first file not shown.
+	switch next := trunc.Offset + len(shown); {
+	case partHunks > 0:
+		trunc.NextOffset = trunc.Offset
+		trunc.NextHunkOffset = trunc.HunkOffset + partHunks
+
+	case next < len(all):
+		trunc.NextOffset = next
+	}
+
+	if trunc.Offset == 0 && trunc.HunkOffset == 0 &&
+		len(trunc.Files) == 0 {
+
+		return parsed, nil
+	}
+
+	return parsed.WithFiles(shown), trunc
+}
+
+// fitHunks returns how many leading hunks of file fit in the lines left
+// after used lines have been shown.
+func (b Budget) fitHunks(file *diff.FileDiff, used int) int {
+	if b.MaxLines <= 0 {
+		return len(file.Hunks)
+	}
+
+	for i, hunk := range file.Hunks {
+		used += b.hunkLines(hunk)
+		if used > b.MaxLines {
+			return i
+		}
+	}
+
+	return len(file.Hunks)
+}
+
+// hunkLines returns the number of lines of hunk that are shown.
+func (b Budget) hunkLines(hunk *diff.Hunk) int {
+	if !b.HideContext {
+		return len(hunk.Lines)
+	}
+
+	added, deleted := hunk.Stats()
+
+	return added + deleted
+}
+
+// truncatedFile summarizes the hunks of file after the first shown.
+func truncatedFile(file *diff.FileDiff, shown int) TruncatedFile {
+	rest := file.Hunks[shown:]
+
+	tf := TruncatedFile{
+		Path:       file.Path(),
+		ShownHunks: shown,
+		Hunks:      len(rest),
+		Ranges:     hunkRanges(rest),
+		Binary:     file.IsBinary,
+	}
+
+	for _, hunk := range rest {
+		added, deleted := hunk.Stats()
+		tf.Additions += added
+		tf.Deletions += deleted
+	}
+
+	return tf
+}
+
+// FormatTruncation writes a footer listing what a budget left out and how
+// to page on. Nothing is written for a nil report.
+func FormatTruncation(w io.Writer, trunc *Truncation, opts TextOptions) {
+	if trunc == nil {
+		return
+	}
+
+	var lines []string
+
+	switch {
+	case trunc.ShownFiles == 0:
+		lines = append(lines, fmt.Sprintf(
+			"[offset %d is past the last of %d files]",
+			trunc.Offset, trunc.TotalFiles,
+		))
+
+	default:
+		var prefix string
+		if len(trunc.Files) > 0 {
+			prefix = "truncated: "
+		}
+
+		var from string
+		if trunc.HunkOffset > 0 {
+			from = fmt.Sprintf(" from hunk %d", trunc.HunkOffset+1)
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"[%sshowing files %d-%d of %d%s, %d lines]", prefix,
+			trunc.Offset+1, trunc.Offset+trunc.ShownFiles,
+			trunc.TotalFiles, from, trunc.ShownLines,
+		))
+	}
+
+	for _, tf := range trunc.Files {
+		path := RelativePath(opts.RelativeTo, tf.Path)
+
+		var what string
+
+		switch {
+		case tf.Binary:
+			what = "binary file"
+		case tf.Hunks == 0:
+			what = "no line changes"
+		case tf.ShownHunks > 0:
+			what = fmt.Sprintf("%d more hunk(s)", tf.Hunks)
+		default:
+			what = fmt.Sprintf("%d hunk(s)", tf.Hunks)
+		}
+
+		line := fmt.Sprintf("  %s: %s", path, what)
+		if tf.Hunks > 0 {
+			line += fmt.Sprintf(", +%d -%d, lines %s", tf.Additions,
+				tf.Deletions, strings.Join(tf.Ranges, ","))
+		}
+
+		lines = append(lines, line)
+	}
+
+	switch {
+	case trunc.NextHunkOffset > 0:
+		lines = append(lines, fmt.Sprintf(
+			"[next page: --offset %d --hunk-offset %d]",
+			trunc.NextOffset, trunc.NextHunkOffset,
+		))
+
+	case trunc.NextOffset > 0:
+		lines = append(lines, fmt.Sprintf(
+			"[next page: --offset %d]", trunc.NextOffset,
+		))
+	}
+
+	fmt.Fprintln(w)
+	for _, line := range lines {
+		if opts.Color {
+			fmt.Fprintf(w, "%s%s%s\n", colorDim, line, colorReset)
+		} else {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetTestDiff has a file with two hunks of four lines each, followed by
+// two files with one such hunk.
+const budgetTestDiff = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+diff --git a/c.go b/c.go
+--- a/c.go
++++ b/c.go
+@@ -5,3 +5,3 @@
+ five
+-six
++SIX
+ seven
+`
+
+func shownPaths(parsed *diff.ParsedDiff) []string {
+	var paths []string
+	for file := range parsed.Files() {
+		paths = append(paths, file.Path())
+	}
+
+	return paths
+}
+
+func TestBudgetApply(t *testing.T) {
+	parsed, err := diff.Parse(budgetTestDiff)
+	require.NoError(t, err)
+
+	t.Run("unlimited", func(t *testing.T) {
+		shown, trunc := output.Budget{}.Apply(parsed)
+		require.Same(t, parsed, shown)
+		require.Nil(t, trunc)
+	})
+
+	t.Run("everything fits", func(t *testing.T) {
+		shown, trunc := output.Budget{MaxLines: 100}.Apply(parsed)
+		require.Same(t, parsed, shown)
+		require.Nil(t, trunc)
+	})
+
+	t.Run("max lines", func(t *testing.T) {
+		shown, trunc := output.Budget{MaxLines: 10}.Apply(parsed)
+		require.Equal(t, []string{"a.go"}, shownPaths(shown))
+		require.Equal(t, 8, trunc.ShownLines)
+		require.Equal(t, 1, trunc.NextOffset)
+		require.Equal(t, []output.TruncatedFile{
+			{Path: "b.go", Hunks: 1, Additions: 1, Deletions: 1,
+				Ranges: []string{"2"}},
+			{Path: "c.go", Hunks: 1, Additions: 1, Deletions: 1,
+				Ranges: []string{"6"}},
+		}, trunc.Files)
+	})
+
+	t.Run("first file shown in part", func(t *testing.T) {
+		shown, trunc := output.Budget{MaxLines: 6}.Apply(parsed)
+		require.Equal(t, []string{"a.go"}, shownPaths(shown))
+		require.Len(t, shown.AllFiles()[0].Hunks, 1)
+		require.Len(t, parsed.AllFiles()[0].Hunks, 2)
+		require.Equal(t, 1, trunc.Files[0].ShownHunks)
+		require.Equal(t, []string{"11"}, trunc.Files[0].Ranges)
+
+		// The next page picks up the hunk left out, not the next
+		// file.
+		require.Zero(t, trunc.NextOffset)
+		require.Equal(t, 1, trunc.NextHunkOffset)
+
+		shown, trunc = output.Budget{
+			MaxLines: 6, HunkOffset: trunc.NextHunkOffset,
+		}.Apply(parsed)
+		require.Equal(t, []string{"a.go"}, shownPaths(shown))
+		require.Len(t, shown.AllFiles()[0].Hunks, 1)
+		require.Equal(t, 10, shown.AllFiles()[0].Hunks[0].NewStart)
+		require.Equal(t, 1, trunc.HunkOffset)
+		require.Equal(t, 1, trunc.NextOffset)
+		require.Zero(t, trunc.NextHunkOffset)
+		require.Equal(t, "b.go", trunc.Files[0].Path)
+	})
+
+	t.Run("hunk longer than the budget", func(t *testing.T) {
+		// A hunk is never split, so one that doesn't fit at all is
+		// shown anyway rather than paging stalling on it.
+		shown, trunc := output.Budget{MaxLines: 2}.Apply(parsed)
+		require.Len(t, shown.AllFiles()[0].Hunks, 1)
+		require.Equal(t, 4, trunc.ShownLines)
+		require.Equal(t, 1, trunc.NextHunkOffset)
+
+		shown, trunc = output.Budget{
+			MaxLines: 2, Offset: 1,
+		}.Apply(parsed)
+		require.Equal(t, []string{"b.go"}, shownPaths(shown))
+		require.Equal(t, 2, trunc.NextOffset)
+		require.Zero(t, trunc.NextHunkOffset)
+	})
+
+	t.Run("hidden context", func(t *testing.T) {
+		shown, trunc := output.Budget{
+			MaxLines: 4, HideContext: true,
+		}.Apply(parsed)
+		require.Equal(t, []string{"a.go"}, shownPaths(shown))
+		require.Equal(t, 4, trunc.ShownLines)
+	})
+
+	t.Run("max files and offset", func(t *testing.T) {
+		shown, trunc := output.Budget{MaxFiles: 1, Offset: 1}.Apply(parsed)
+		require.Equal(t, []string{"b.go"}, shownPaths(shown))
+		require.Equal(t, 1, trunc.Offset)
+		require.Equal(t, 2, trunc.NextOffset)
+		require.Len(t, trunc.Files, 1)
+
+		// The last page reports its position but nothing left out.
+		shown, trunc = output.Budget{MaxFiles: 1, Offset: 2}.Apply(parsed)
+		require.Equal(t, []string{"c.go"}, shownPaths(shown))
+		require.Empty(t, trunc.Files)
+		require.Zero(t, trunc.NextOffset)
+	})
+}
+
+func TestFormatTruncation(t *testing.T) {
+	parsed, err := diff.Parse(budgetTestDiff)
+	require.NoError(t, err)
+
+	_, trunc := output.Budget{MaxLines: 6}.Apply(parsed)
+
+	var buf bytes.Buffer
+	output.FormatTruncation(&buf, trunc, output.TextOptions{})
+
+	require.Equal(t, strings.Join([]string{
+		"",
+		"[truncated: showing files 1-1 of 3, 4 lines]",
+		"  a.go: 1 more hunk(s), +1 -1, lines 11",
+		"  b.go: 1 hunk(s), +1 -1, lines 2",
+		"  c.go: 1 hunk(s), +1 -1, lines 6",
+		"[next page: --offset 0 --hunk-offset 1]",
+		"",
+	}, "\n"), buf.String())
+
+	_, trunc = output.Budget{MaxLines: 6, HunkOffset: 1}.Apply(parsed)
+
+	buf.Reset()
+	output.FormatTruncation(&buf, trunc, output.TextOptions{})
+	require.Equal(t, strings.Join([]string{
+		"",
+		"[truncated: showing files 1-1 of 3 from hunk 2, 4 lines]",
+		"  b.go: 1 hunk(s), +1 -1, lines 2",
+		"  c.go: 1 hunk(s), +1 -1, lines 6",
+		"[next page: --offset 1]",
+		"",
+	}, "\n"), buf.String())
+
+	_, trunc = output.Budget{Offset: 5}.Apply(parsed)
+
+	buf.Reset()
+	output.FormatTruncation(&buf, trunc, output.TextOptions{})
+	require.Equal(t, "\n[offset 5 is past the last of 3 files]\n",
+		buf.String())
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ColorMode selects when ANSI colors are used in text output.
+type ColorMode string
+
+const (
+	// ColorAuto enables color only when writing to a terminal.
+	ColorAuto ColorMode = "auto"
+
+	// ColorAlways forces color on.
+	ColorAlways ColorMode = "always"
+
+	// ColorNever forces color off.
+	ColorNever ColorMode = "never"
+)
+
+// ParseColorMode parses a --color flag value.
+func ParseColorMode(s string) (ColorMode, error) {
+	switch mode := ColorMode(s); mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		return mode, nil
+	case "":
+		return ColorAuto, nil
+	default:
+		return "", fmt.Errorf(
+			"invalid color mode %q: expected auto, always or never", s,
+		)
+	}
+}
+
+// Enabled reports whether color should be used when writing to w. In auto
+// mode, color is used only if w is a terminal and neither NO_COLOR nor
+// TERM=dumb is set.
+func (m ColorMode) Enabled(w io.Writer) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return IsTerminal(w)
+}
+
+// IsTerminal reports whether w is a character device such as a TTY.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,47 @@
+package output_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColorMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    output.ColorMode
+		wantErr bool
+	}{
+		{input: "auto", want: output.ColorAuto},
+		{input: "always", want: output.ColorAlways},
+		{input: "never", want: output.ColorNever},
+		{input: "", want: output.ColorAuto},
+		{input: "sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := output.ParseColorMode(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, mode)
+		})
+	}
+}
+
+func TestColorModeEnabled(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.True(t, output.ColorAlways.Enabled(&buf))
+	require.False(t, output.ColorNever.Enabled(&buf))
+
+	// A buffer is never a terminal, so auto stays plain.
+	require.False(t, output.ColorAuto.Enabled(&buf))
+	require.False(t, output.IsTerminal(&buf))
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// FormatCompact writes the parsed diff in a terse layout for agents. Each
+// file's path is printed once, followed by its change blocks: a FILE:LINES
+// selector that stages the block, then only its changed lines, without line
+// numbers or hunk headers. opts.CompactContext adds one line of context
+// around each block.
+func FormatCompact(
+	w io.Writer, parsed *diff.ParsedDiff, opts TextOptions,
+) error {
+	first := true
+	for file := range parsed.Files() {
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+
+		writeColored(w, fileHeader(file, opts.RelativeTo), colorCyan, opts)
+		writeModeChange(w, file, opts)
+
+		path := diff.QuoteSelectionPath(
+			RelativePath(opts.RelativeTo, file.Path()),
+		)
+
+		if file.IsBinary {
+			fmt.Fprintln(w, binarySummary(file))
+			writeColored(w, path, colorBlue, opts)
+
+			continue
+		}
+
+		for block := range file.Blocks() {
+			hunk := file.Hunks[block.Ref.Hunk-1]
+			selector := path + ":" + file.BlockSpec(block)
+			formatCompactBlock(w, selector, hunk, block, opts)
+		}
+	}
+
+	if opts.Stats {
+		formatStats(w, parsed, opts)
+	}
+
+	return nil
+}
+
+// formatCompactBlock writes a change block under its line selector.
+func formatCompactBlock(
+	w io.Writer, selector string, hunk *diff.Hunk, block diff.ChangeBlock,
+	opts TextOptions,
+) {
+	writeColored(w, selector, colorBlue, opts)
+
+	if opts.CompactContext && block.Start > 0 {
+		formatCompactLine(w, hunk.Lines[block.Start-1], opts)
+	}
+
+	for _, line := range block.Lines {
+		formatCompactLine(w, line, opts)
+	}
+
+	end := block.Start + len(block.Lines)
+	if opts.CompactContext && end < len(hunk.Lines) {
+		formatCompactLine(w, hunk.Lines[end], opts)
+	}
+}
+
+// formatCompactLine writes a line with its diff prefix and no line number.
+func formatCompactLine(w io.Writer, line diff.DiffLine, opts TextOptions) {
+	var color string
+
+	switch line.Op {
+	case diff.OpAdd:
+		color = colorGreen
+	case diff.OpDelete:
+		color = colorRed
+	}
+
+	text := string(line.Op.Prefix()) + line.Content
+	if color == "" {
+		fmt.Fprintln(w, text)
+
+		return
+	}
+
+	writeColored(w, text, color, opts)
+}
+
+// writeColored writes s on its own line, in color if enabled.
+func writeColored(w io.Writer, s, color string, opts TextOptions) {
+	if opts.Color {
+		fmt.Fprintf(w, "%s%s%s\n", color, s, colorReset)
+	} else {
+		fmt.Fprintln(w, s)
+	}
+}
@@ -0,0 +1,114 @@
+package output_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatCompact(t *testing.T) {
+	parsed := parseTestDiff(t)
+
+	var buf bytes.Buffer
+	err := output.FormatCompact(&buf, parsed, output.TextOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, `main.go
+main.go:2-3
++// Added line 1.
++// Added line 2.
+-// Removed.
+`, buf.String())
+
+	buf.Reset()
+	err = output.FormatCompact(&buf, parsed, output.TextOptions{
+		CompactContext: true,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, `main.go
+main.go:2-3
+ package main
++// Added line 1.
++// Added line 2.
+-// Removed.
+ func main() {}
+`, buf.String())
+}
+
+func TestFormatCompact_Blocks(t *testing.T) {
+	diffText := `diff --git a/a:b.go b/a:b.go
+--- a/a:b.go
++++ b/a:b.go
+@@ -1,7 +1,6 @@
+ one
+-two
++TWO
+ three
+ four
+-five
+-six
++FIVE
+ seven
+`
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatCompact(&buf, parsed, output.TextOptions{})
+	require.NoError(t, err)
+
+	// Each run of changes is its own block, and the path is quoted so
+	// the selector splits at the right colon.
+	require.Equal(t, `a:b.go
+"a:b.go":2
+-two
++TWO
+"a:b.go":5-6
+-five
+-six
++FIVE
+`, buf.String())
+}
+
+func TestFormatCompact_CrossedNumbers(t *testing.T) {
+	// The deletion of old line 5 and the addition of new line 5, c, are
+	// in different blocks, so their selectors name the sides.
+	parsed, err := diff.Parse(`diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,6 +1,8 @@
+ 1
+ 2
++a
++b
++c
+ 3
+ 4
+-5
+ 6
+`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatCompact(&buf, parsed, output.TextOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, `f.txt
+f.txt:+3-5
++a
++b
++c
+f.txt:-5
+-5
+`, buf.String())
+
+	hints := output.StageHints(parsed, "")
+	require.Len(t, hints, 2)
+	require.Equal(t, "f.txt:+3-5", hints[0].Lines)
+	require.Equal(t, "f.txt:-5", hints[1].Lines)
+}
@@ -0,0 +1,169 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/schema"
+)
+
+// Kinds of stage hint.
+const (
+	// HintBlock stages one change block of a file.
+	HintBlock = "block"
+
+	// HintMode stages a file's mode change.
+	HintMode = "mode"
+
+	// HintFile stages a file whole, as binary files and symlinks must be.
+	HintFile = "file"
+)
+
+// maxPreviewLen is the longest a hint's preview of its first line gets,
+// in runes.
+const maxPreviewLen = 60
+
+// StageHintsOutput is the JSON form of 'hunk diff --stage-hints'.
+type StageHintsOutput struct {
+	SchemaVersion int         `json:"schema_version"`
+	Hints         []StageHint `json:"hints"`
+}
+
+// StageHint is a suggested stage command for one part of a diff.
+type StageHint struct {
+	// Kind is HintBlock, HintMode or HintFile.
+	Kind string `json:"kind"`
+
+	// Path is the file path relative to the repository root.
+	Path string `json:"path"`
+
+	// Selector is the argument that stages this part, e.g. "main.go:@2.1".
+	// Paths are relative to the working directory.
+	Selector string `json:"selector"`
+
+	// Command is the full shell command.
+	Command string `json:"command"`
+
+	// Lines is the block's FILE:LINES selector.
+	Lines string `json:"lines,omitempty"`
+
+	// Additions and Deletions count the lines this part stages. Both are
+	// always present, even when zero.
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+
+	// Preview is the block's first changed line, trimmed.
+	Preview string `json:"preview,omitempty"`
+
+	// Section is the enclosing section of the block's hunk.
+	Section string `json:"section,omitempty"`
+}
+
+// StageHints returns a hint for each mode change and change block in the
+// diff, and one per binary file or symlink. relativeTo is the repo-relative
+// working directory that selectors are written relative to.
+func StageHints(parsed *diff.ParsedDiff, relativeTo string) []StageHint {
+	hints := make([]StageHint, 0)
+
+	for file := range parsed.Files() {
+		rel := RelativePath(relativeTo, file.Path())
+		path := diff.QuoteSelectionPath(rel)
+
+		if file.ModeChanged() {
+			hints = append(hints, StageHint{
+				Kind:     HintMode,
+				Path:     file.Path(),
+				Selector: rel,
+				Command:  "hunk stage --mode " + shellQuote(rel),
+			})
+		}
+
+		if file.IsBinary || file.IsSymlink() {
+			if file.IsBinary || len(file.Hunks) > 0 {
+				added, deleted := file.Stats()
+
+				hints = append(hints, StageHint{
+					Kind:      HintFile,
+					Path:      file.Path(),
+					Selector:  path,
+					Command:   "hunk stage " + shellQuote(path),
+					Additions: added,
+					Deletions: deleted,
+				})
+			}
+
+			continue
+		}
+
+		for block := range file.Blocks() {
+			selector := path + ":" + block.Ref.String()
+			added, deleted := block.Stats()
+
+			hints = append(hints, StageHint{
+				Kind:      HintBlock,
+				Path:      file.Path(),
+				Selector:  selector,
+				Command:   "hunk stage " + shellQuote(selector),
+				Lines:     path + ":" + file.BlockSpec(block),
+				Additions: added,
+				Deletions: deleted,
+				Preview:   linePreview(block.Lines[0].Content),
+				Section:   block.Section,
+			})
+		}
+	}
+
+	return hints
+}
+
+// linePreview trims a line for display, shortening it to maxPreviewLen.
+func linePreview(content string) string {
+	content = strings.TrimSpace(content)
+	if utf8.RuneCountInString(content) <= maxPreviewLen {
+		return content
+	}
+
+	runes := []rune(content)
+
+	return string(runes[:maxPreviewLen-3]) + "..."
+}
+
+// FormatStagingCommands writes a suggested stage command for each hint,
+// with a comment describing the block it stages.
+func FormatStagingCommands(
+	w io.Writer, parsed *diff.ParsedDiff, relativeTo string,
+) error {
+	for _, hint := range StageHints(parsed, relativeTo) {
+		if hint.Kind != HintBlock {
+			fmt.Fprintln(w, hint.Command)
+
+			continue
+		}
+
+		comment := fmt.Sprintf("+%d -%d", hint.Additions, hint.Deletions)
+		if hint.Section != "" {
+			comment += " in " + hint.Section
+		}
+
+		fmt.Fprintf(w, "%s  # %s: %s\n", hint.Command, comment, hint.Preview)
+	}
+
+	return nil
+}
+
+// FormatStageHintsJSON writes the stage hints as JSON.
+func FormatStageHintsJSON(
+	w io.Writer, parsed *diff.ParsedDiff, relativeTo string,
+) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(StageHintsOutput{
+		SchemaVersion: schema.Version,
+		Hints:         StageHints(parsed, relativeTo),
+	})
+}
@@ -0,0 +1,119 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatStagingCommands(t *testing.T) {
+	parsed := parseTestDiff(t)
+
+	var buf bytes.Buffer
+	err := output.FormatStagingCommands(&buf, parsed, "")
+	require.NoError(t, err)
+
+	require.Equal(t, "hunk stage main.go:@1.1  # +2 -1: // Added line 1.\n",
+		buf.String())
+}
+
+func TestFormatStagingCommands_ModeChange(t *testing.T) {
+	parsed, err := diff.Parse(`diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1 +1,2 @@
+ #!/bin/sh
++echo hi
+`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatStagingCommands(&buf, parsed, "")
+	require.NoError(t, err)
+
+	require.Equal(t, "hunk stage --mode run.sh\n"+
+		"hunk stage run.sh:@1.1  # +1 -0: echo hi\n", buf.String())
+}
+
+func TestFormatStagingCommands_QuotedPaths(t *testing.T) {
+	parsed, err := diff.Parse("diff --git a/a:b.go b/a:b.go\n" +
+		"--- a/a:b.go\n" +
+		"+++ b/a:b.go\n" +
+		"@@ -1 +1,2 @@\n" +
+		" a\n" +
+		"+b\n" +
+		"diff --git a/it's.go b/it's.go\n" +
+		"--- a/it's.go\n" +
+		"+++ b/it's.go\n" +
+		"@@ -1 +1,2 @@\n" +
+		" a\n" +
+		"+b\n")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatStagingCommands(&buf, parsed, "")
+	require.NoError(t, err)
+
+	require.Equal(t, `hunk stage '"a:b.go":@1.1'  # +1 -0: b`+"\n"+
+		`hunk stage 'it'\''s.go:@1.1'  # +1 -0: b`+"\n", buf.String())
+}
+
+func TestStageHints(t *testing.T) {
+	parsed, err := diff.Parse(`diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,7 +1,7 @@ package main
+ one
+-two
++TWO
+ three
+ four
+-five
++FIVE
+ six
+@@ -20,3 +20,4 @@ func main() {
+ twenty
++	return errors.New("a message long enough that the preview gets cut short")
+ twentyone
+`)
+	require.NoError(t, err)
+
+	hints := output.StageHints(parsed, "pkg")
+	require.Len(t, hints, 3)
+
+	require.Equal(t, output.StageHint{
+		Kind:      output.HintBlock,
+		Path:      "main.go",
+		Selector:  "../main.go:@1.2",
+		Command:   "hunk stage ../main.go:@1.2",
+		Lines:     "../main.go:5",
+		Additions: 1,
+		Deletions: 1,
+		Preview:   "five",
+		Section:   "package main",
+	}, hints[1])
+
+	require.Equal(t, "../main.go:@2.1", hints[2].Selector)
+	require.Equal(t, "../main.go:21", hints[2].Lines)
+	require.Len(t, []rune(hints[2].Preview), 60)
+	require.Contains(t, hints[2].Preview, "...")
+
+	var buf bytes.Buffer
+	require.NoError(t, output.FormatStageHintsJSON(&buf, parsed, ""))
+
+	var out output.StageHintsOutput
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.Len(t, out.Hints, 3)
+	require.Equal(t, "main.go:@1.1", out.Hints[0].Selector)
+
+	// A block that only adds lines still reports its deletions.
+	require.Contains(t, buf.String(), `"additions": 1,
+      "deletions": 0,`)
+}
@@ -3,24 +3,82 @@ package output
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 
 	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/schema"
 )
 
 // DiffOutput is the top-level JSON output structure.
 type DiffOutput struct {
-	Files     []FileOutput `json:"files"`
-	Untracked []string     `json:"untracked,omitempty"`
+	SchemaVersion int          `json:"schema_version"`
+	Files         []FileOutput `json:"files"`
+	Moves         []MoveOutput `json:"moves,omitempty"`
+	Untracked     []string     `json:"untracked,omitempty"`
+
+	// Truncated reports what an output budget left out, if anything.
+	Truncated *Truncation `json:"truncated,omitempty"`
+}
+
+// MoveOutput represents a block of code moved within or between files.
+type MoveOutput struct {
+	ID   int           `json:"id"`
+	From MoveLocOutput `json:"from"`
+	To   MoveLocOutput `json:"to"`
+}
+
+// MoveLocOutput is one side of a moved block.
+type MoveLocOutput struct {
+	Path  string `json:"path"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
 }
 
 // FileOutput represents a file in JSON output.
 type FileOutput struct {
-	Path    string       `json:"path"`
-	OldPath string       `json:"old_path,omitempty"`
-	Status  string       `json:"status"` // "modified", "new", "deleted", "renamed"
-	Binary  bool         `json:"binary,omitempty"`
-	Hunks   []HunkOutput `json:"hunks,omitempty"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Status  string `json:"status"` // "modified", "new", "deleted", "renamed", "copied"
+
+	// RelativePath is Path relative to the working directory, set when
+	// hunk runs in a subdirectory. Path stays relative to the repository
+	// root.
+	RelativePath string `json:"relative_path,omitempty"`
+
+	// Similarity is the rename or copy similarity percentage.
+	Similarity int `json:"similarity,omitempty"`
+
+	// OldMode and NewMode are the git file modes (e.g. "100644").
+	OldMode string `json:"old_mode,omitempty"`
+	NewMode string `json:"new_mode,omitempty"`
+
+	// OldBlob and NewBlob are the object ids from the index line.
+	OldBlob string `json:"old_blob,omitempty"`
+	NewBlob string `json:"new_blob,omitempty"`
+
+	// ModeChange is set when an existing file's mode changed. It is
+	// staged on its own with 'hunk stage --mode PATH'.
+	ModeChange *ModeChangeOutput `json:"mode_change,omitempty"`
+
+	// Symlink is true when either side is a symbolic link. Symlinks are
+	// staged as a whole.
+	Symlink bool `json:"symlink,omitempty"`
+
+	Binary bool `json:"binary,omitempty"`
+
+	// OldSize and NewSize are a binary file's sizes in bytes, when the
+	// diff carries its binary patch.
+	OldSize *int64 `json:"old_size,omitempty"`
+	NewSize *int64 `json:"new_size,omitempty"`
+
+	Hunks []HunkOutput `json:"hunks,omitempty"`
+}
+
+// ModeChangeOutput is a file mode change listed as its own entry.
+type ModeChangeOutput struct {
+	Old string `json:"old"`
+	New string `json:"new"`
 }
 
 // HunkOutput represents a hunk in JSON output.
@@ -36,6 +94,15 @@ type LineOutput struct {
 	Content    string `json:"content"`
 	OldLineNum int    `json:"old_line,omitempty"`
 	NewLineNum int    `json:"new_line,omitempty"`
+
+	// MoveGroup is the ID of the moved block this line belongs to.
+	MoveGroup int `json:"move_group,omitempty"`
+
+	// MovedFrom is the "PATH:LINE" a moved-in line was deleted from.
+	MovedFrom string `json:"moved_from,omitempty"`
+
+	// MovedTo is the "PATH:LINE" a moved-out line was added at.
+	MovedTo string `json:"moved_to,omitempty"`
 }
 
 // FormatJSON writes the parsed diff as JSON.
@@ -45,39 +112,50 @@ func FormatJSON(w io.Writer, parsed *diff.ParsedDiff) error {
 
 // FormatJSONWithUntracked writes the parsed diff as JSON, including untracked files.
 func FormatJSONWithUntracked(w io.Writer, parsed *diff.ParsedDiff, untracked []string) error {
+	return FormatJSONWithOptions(w, parsed, JSONOptions{Untracked: untracked})
+}
+
+// JSONOptions controls optional parts of the JSON diff output.
+type JSONOptions struct {
+	// Untracked lists untracked files to report alongside the diff.
+	Untracked []string
+
+	// RelativeTo is the repo-relative working directory. When set, each
+	// file also reports its path relative to it.
+	RelativeTo string
+
+	// Truncation is reported when the diff was cut down to a budget.
+	Truncation *Truncation
+}
+
+// FormatJSONWithOptions writes the parsed diff as JSON.
+func FormatJSONWithOptions(
+	w io.Writer, parsed *diff.ParsedDiff, opts JSONOptions,
+) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(NewDiffOutput(parsed, opts))
+}
+
+// NewDiffOutput converts the parsed diff to its JSON form, for commands
+// that report a diff alongside other fields.
+func NewDiffOutput(parsed *diff.ParsedDiff, opts JSONOptions) DiffOutput {
 	output := DiffOutput{
-		Files:     make([]FileOutput, 0),
-		Untracked: untracked,
+		SchemaVersion: schema.Version,
+		Files:         make([]FileOutput, 0),
+		Untracked:     opts.Untracked,
+		Truncated:     opts.Truncation,
 	}
 
 	for file := range parsed.Files() {
-		fo := FileOutput{
-			Path:    file.Path(),
-			OldPath: file.OldName,
-			Status:  fileStatus(file),
-			Binary:  file.IsBinary,
-			Hunks:   make([]HunkOutput, 0, len(file.Hunks)),
-		}
-
-		if fo.OldPath == fo.Path {
-			fo.OldPath = ""
-		}
+		fo := newFileOutput(file, opts.RelativeTo)
+		fo.Hunks = make([]HunkOutput, 0, len(file.Hunks))
 
 		for _, hunk := range file.Hunks {
-			ho := HunkOutput{
-				Header:  hunk.Header(),
-				Section: hunk.Section,
-				Hunks:   make([]LineOutput, 0, len(hunk.Lines)),
-			}
-
-			for _, line := range hunk.Lines {
-				lo := LineOutput{
-					Op:         line.Op.String(),
-					Content:    line.Content,
-					OldLineNum: line.OldLineNum,
-					NewLineNum: line.NewLineNum,
-				}
-				ho.Hunks = append(ho.Hunks, lo)
+			ho := newHunkOutput(hunk)
+			for i, line := range hunk.Lines {
+				annotateMove(&ho.Hunks[i], parsed, file, line)
 			}
 
 			fo.Hunks = append(fo.Hunks, ho)
@@ -86,10 +164,103 @@ func FormatJSONWithUntracked(w io.Writer, parsed *diff.ParsedDiff, untracked []s
 		output.Files = append(output.Files, fo)
 	}
 
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
+	for _, m := range parsed.Moves() {
+		output.Moves = append(output.Moves, MoveOutput{
+			ID: m.ID,
+			From: MoveLocOutput{
+				Path: m.OldPath, Start: m.OldStart, End: m.OldEnd,
+			},
+			To: MoveLocOutput{
+				Path: m.NewPath, Start: m.NewStart, End: m.NewEnd,
+			},
+		})
+	}
 
-	return enc.Encode(output)
+	return output
+}
+
+// newFileOutput converts a file diff to its JSON form without hunks.
+// relativeTo is the repo-relative working directory, or "" at the root.
+func newFileOutput(file *diff.FileDiff, relativeTo string) FileOutput {
+	fo := FileOutput{
+		Path:       file.Path(),
+		OldPath:    file.OldName,
+		Status:     fileStatus(file),
+		Binary:     file.IsBinary,
+		Similarity: file.Similarity,
+		OldMode:    file.OldMode,
+		NewMode:    file.NewMode,
+		OldBlob:    file.OldBlob,
+		NewBlob:    file.NewBlob,
+		Symlink:    file.IsSymlink(),
+	}
+
+	if relativeTo != "" {
+		fo.RelativePath = RelativePath(relativeTo, fo.Path)
+	}
+
+	if oldSize, newSize, ok := file.BinarySizes(); ok {
+		fo.OldSize, fo.NewSize = &oldSize, &newSize
+	}
+
+	if file.ModeChanged() {
+		fo.ModeChange = &ModeChangeOutput{
+			Old: file.OldMode, New: file.NewMode,
+		}
+	}
+
+	if fo.OldPath == fo.Path {
+		fo.OldPath = ""
+	}
+
+	return fo
+}
+
+// newHunkOutput converts a hunk and its lines to JSON form.
+func newHunkOutput(hunk *diff.Hunk) HunkOutput {
+	ho := HunkOutput{
+		Header:  hunk.Header(),
+		Section: hunk.Section,
+		Hunks:   make([]LineOutput, 0, len(hunk.Lines)),
+	}
+
+	for _, line := range hunk.Lines {
+		ho.Hunks = append(ho.Hunks, LineOutput{
+			Op:         line.Op.String(),
+			Content:    line.Content,
+			OldLineNum: line.OldLineNum,
+			NewLineNum: line.NewLineNum,
+		})
+	}
+
+	return ho
+}
+
+// annotateMove fills in the move fields of a line that is part of a moved
+// block. The matching location is the line at the same offset on the other
+// side of the move.
+func annotateMove(
+	lo *LineOutput, parsed *diff.ParsedDiff, file *diff.FileDiff,
+	line diff.DiffLine,
+) {
+	m, ok := parsed.MoveForLine(file, line)
+	if !ok {
+		return
+	}
+
+	lo.MoveGroup = m.ID
+
+	switch line.Op {
+	case diff.OpDelete:
+		lo.MovedTo = fmt.Sprintf(
+			"%s:%d", m.NewPath, m.NewStart+line.OldLineNum-m.OldStart,
+		)
+
+	case diff.OpAdd:
+		lo.MovedFrom = fmt.Sprintf(
+			"%s:%d", m.OldPath, m.OldStart+line.NewLineNum-m.NewStart,
+		)
+	}
 }
 
 // fileStatus returns the status string for a file.
@@ -101,6 +272,8 @@ func fileStatus(f *diff.FileDiff) string {
 		return "deleted"
 	case f.IsRenamed:
 		return "renamed"
+	case f.IsCopied:
+		return "copied"
 	default:
 		return "modified"
 	}
@@ -114,8 +287,9 @@ func FormatJSONEmpty(w io.Writer) error {
 // FormatJSONEmptyWithUntracked writes an empty JSON response with untracked files.
 func FormatJSONEmptyWithUntracked(w io.Writer, untracked []string) error {
 	output := DiffOutput{
-		Files:     []FileOutput{},
-		Untracked: untracked,
+		SchemaVersion: schema.Version,
+		Files:         []FileOutput{},
+		Untracked:     untracked,
 	}
 
 	enc := json.NewEncoder(w)
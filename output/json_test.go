@@ -120,6 +120,94 @@ rename to new.go
 	require.Equal(t, "renamed", result.Files[0].Status)
 	require.Equal(t, "new.go", result.Files[0].Path)
 	require.Equal(t, "old.go", result.Files[0].OldPath)
+	require.Equal(t, 90, result.Files[0].Similarity)
+}
+
+func TestFormatJSON_ExtendedHeaders(t *testing.T) {
+	diffText := `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 0ff3bbb..fb3ced1
+--- a/run.sh
++++ b/run.sh
+@@ -1 +1,2 @@
+ #!/bin/sh
++echo hi
+`
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatJSON(&buf, parsed)
+	require.NoError(t, err)
+
+	var result output.DiffOutput
+	err = json.Unmarshal(buf.Bytes(), &result)
+	require.NoError(t, err)
+
+	require.Len(t, result.Files, 1)
+	require.Equal(t, "100644", result.Files[0].OldMode)
+	require.Equal(t, "100755", result.Files[0].NewMode)
+	require.Equal(t, "0ff3bbb", result.Files[0].OldBlob)
+	require.Equal(t, "fb3ced1", result.Files[0].NewBlob)
+	require.Equal(t, &output.ModeChangeOutput{
+		Old: "100644", New: "100755",
+	}, result.Files[0].ModeChange)
+	require.False(t, result.Files[0].Symlink)
+}
+
+func TestFormatJSON_BinaryFile(t *testing.T) {
+	parsed, err := diff.Parse(binaryTestDiff)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatJSON(&buf, parsed)
+	require.NoError(t, err)
+
+	var result output.DiffOutput
+	err = json.Unmarshal(buf.Bytes(), &result)
+	require.NoError(t, err)
+
+	require.Len(t, result.Files, 1)
+	file := result.Files[0]
+	require.True(t, file.Binary)
+	require.Equal(t, "96db3e1c616a9650209b6a2491a6a663261c7edf", file.OldBlob)
+	require.NotNil(t, file.OldSize)
+	require.NotNil(t, file.NewSize)
+	require.EqualValues(t, 7, *file.OldSize)
+	require.EqualValues(t, 8, *file.NewSize)
+}
+
+func TestFormatJSON_CopiedFile(t *testing.T) {
+	diffText := `diff --git a/a.go b/b.go
+similarity index 95%
+copy from a.go
+copy to b.go
+--- a/a.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+ package main
++// Copied.
+ func main() {}
+`
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatJSON(&buf, parsed)
+	require.NoError(t, err)
+
+	var result output.DiffOutput
+	err = json.Unmarshal(buf.Bytes(), &result)
+	require.NoError(t, err)
+
+	require.Len(t, result.Files, 1)
+	require.Equal(t, "copied", result.Files[0].Status)
+	require.Equal(t, "b.go", result.Files[0].Path)
+	require.Equal(t, "a.go", result.Files[0].OldPath)
+	require.Equal(t, 95, result.Files[0].Similarity)
 }
 
 func TestFormatJSON_MultipleFiles(t *testing.T) {
@@ -208,3 +296,46 @@ func TestFormatJSON_LineNumbers(t *testing.T) {
 	require.Equal(t, "    // added", addLine.Content)
 	require.Greater(t, addLine.NewLineNum, 0)
 }
+
+func TestFormatJSON_MovedBlock(t *testing.T) {
+	diffText := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,4 +1,1 @@
+ package a
+-func helper(value int) int {
+-	return value * multiplier
+-}
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1 +1,4 @@
+ package b
++func helper(value int) int {
++	return value * multiplier
++}
+`
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = output.FormatJSON(&buf, parsed)
+	require.NoError(t, err)
+
+	var result output.DiffOutput
+	err = json.Unmarshal(buf.Bytes(), &result)
+	require.NoError(t, err)
+
+	require.Len(t, result.Moves, 1)
+	require.Equal(t, "a.go", result.Moves[0].From.Path)
+	require.Equal(t, "b.go", result.Moves[0].To.Path)
+
+	deleted := result.Files[0].Hunks[0].Hunks[1]
+	require.Equal(t, 1, deleted.MoveGroup)
+	require.Equal(t, "b.go:2", deleted.MovedTo)
+
+	added := result.Files[1].Hunks[0].Hunks[3]
+	require.Equal(t, 1, added.MoveGroup)
+	require.Equal(t, "a.go:4", added.MovedFrom)
+}
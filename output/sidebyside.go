@@ -0,0 +1,282 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+const (
+	// defaultWidth is the line width used when the terminal size is
+	// unknown.
+	defaultWidth = 80
+
+	// minSideWidth is the narrowest a single side-by-side column may get.
+	// Below this, content wraps after every few characters.
+	minSideWidth = 20
+
+	// sideSeparator divides the old and new columns.
+	sideSeparator = " | "
+
+	// tabWidth is the number of spaces a tab expands to.
+	tabWidth = 4
+)
+
+// TerminalWidth returns the width to wrap output to when writing to w. It
+// uses the terminal size if w is a terminal, then $COLUMNS, and finally a
+// default of 80 columns.
+func TerminalWidth(w io.Writer) int {
+	if width := terminalWidth(w); width > 0 {
+		return width
+	}
+
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil &&
+		cols > 0 {
+
+		return cols
+	}
+
+	return defaultWidth
+}
+
+// sideCell is one side of a side-by-side row.
+type sideCell struct {
+	op      diff.LineOp
+	lineNum int
+	content string
+	present bool
+}
+
+// sideRow pairs an old-file line with a new-file line.
+type sideRow struct {
+	left, right sideCell
+}
+
+// FormatSideBySide writes the parsed diff with old and new content in two
+// columns. Within each change group, deleted lines are paired with added
+// lines in order so replacements line up. Long lines wrap within their
+// column to fit opts.Width.
+func FormatSideBySide(
+	w io.Writer, parsed *diff.ParsedDiff, opts TextOptions,
+) error {
+	width := opts.Width
+	if width <= 0 {
+		width = defaultWidth
+	}
+
+	side := (width - len(sideSeparator)) / 2
+	if side < minSideWidth {
+		side = minSideWidth
+	}
+
+	for file := range parsed.Files() {
+		header := fileHeader(file, opts.RelativeTo)
+
+		if opts.Color {
+			fmt.Fprintf(w, "%s%s%s\n", colorCyan, header, colorReset)
+		} else {
+			fmt.Fprintln(w, header)
+		}
+
+		writeModeChange(w, file, opts)
+
+		if file.IsBinary {
+			fmt.Fprintln(w, binarySummary(file))
+
+			continue
+		}
+
+		for i, hunk := range file.Hunks {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			formatSideHunk(w, hunk, side, opts)
+		}
+	}
+
+	if opts.Stats {
+		formatStats(w, parsed, opts)
+	}
+
+	return nil
+}
+
+// formatSideHunk writes a single hunk as side-by-side rows.
+func formatSideHunk(w io.Writer, hunk *diff.Hunk, side int, opts TextOptions) {
+	header := fmt.Sprintf(
+		"@@ -%d,%d +%d,%d @@",
+		hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines,
+	)
+	if opts.Section && hunk.Section != "" {
+		header += " " + hunk.Section
+	}
+
+	if opts.Color {
+		fmt.Fprintf(w, "%s%s%s\n", colorBlue, header, colorReset)
+	} else {
+		fmt.Fprintln(w, header)
+	}
+
+	for _, row := range pairHunkLines(hunk) {
+		if opts.HideContext && row.left.op == diff.OpContext &&
+			row.left.present {
+
+			continue
+		}
+
+		writeSideRow(w, row, side, opts)
+	}
+}
+
+// pairHunkLines arranges a hunk's lines into rows. Context lines appear on
+// both sides. Each change group pairs its i-th deletion with its i-th
+// addition, leaving the shorter side blank.
+func pairHunkLines(hunk *diff.Hunk) []sideRow {
+	var (
+		rows     []sideRow
+		dels     []diff.DiffLine
+		adds     []diff.DiffLine
+		flushGrp = func() {
+			for i := 0; i < len(dels) || i < len(adds); i++ {
+				var row sideRow
+				if i < len(dels) {
+					row.left = cellFor(dels[i], dels[i].OldLineNum)
+				}
+				if i < len(adds) {
+					row.right = cellFor(adds[i], adds[i].NewLineNum)
+				}
+
+				rows = append(rows, row)
+			}
+
+			dels, adds = nil, nil
+		}
+	)
+
+	for _, line := range hunk.Lines {
+		switch line.Op {
+		case diff.OpDelete:
+			dels = append(dels, line)
+
+		case diff.OpAdd:
+			adds = append(adds, line)
+
+		default:
+			flushGrp()
+			rows = append(rows, sideRow{
+				left:  cellFor(line, line.OldLineNum),
+				right: cellFor(line, line.NewLineNum),
+			})
+		}
+	}
+
+	flushGrp()
+
+	return rows
+}
+
+// cellFor builds a present cell for a diff line.
+func cellFor(line diff.DiffLine, lineNum int) sideCell {
+	return sideCell{
+		op:      line.Op,
+		lineNum: lineNum,
+		content: strings.ReplaceAll(
+			line.Content, "\t", strings.Repeat(" ", tabWidth),
+		),
+		present: true,
+	}
+}
+
+// writeSideRow writes a row, wrapping content that doesn't fit in a column
+// onto continuation rows without line numbers.
+func writeSideRow(w io.Writer, row sideRow, side int, opts TextOptions) {
+	// Each column is "NNNN X content" where X is the op marker.
+	contentWidth := side - 7
+
+	left := wrapRunes(row.left.content, contentWidth)
+	right := wrapRunes(row.right.content, contentWidth)
+
+	n := max(len(left), len(right))
+	for i := range n {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+
+		fmt.Fprint(w, formatSideCell(row.left, l, i == 0, side, opts))
+		fmt.Fprint(w, sideSeparator)
+		fmt.Fprint(w, strings.TrimRight(
+			formatSideCell(row.right, r, i == 0, side, opts), " ",
+		))
+		fmt.Fprintln(w)
+	}
+}
+
+// formatSideCell renders one physical line of a cell padded to the column
+// width. Only the first physical line of a cell carries its line number.
+func formatSideCell(
+	cell sideCell, text string, first bool, side int, opts TextOptions,
+) string {
+	if !cell.present {
+		return strings.Repeat(" ", side)
+	}
+
+	num := "    "
+	if first && opts.LineNumbers {
+		num = formatLineNum(cell.lineNum)
+	}
+
+	marker := " "
+	if first {
+		marker = string(cell.op.Prefix())
+	}
+
+	body := fmt.Sprintf("%s %s ", num, marker) + text
+	pad := side - utf8.RuneCountInString(body)
+	if pad > 0 {
+		body += strings.Repeat(" ", pad)
+	}
+
+	if !opts.Color {
+		return body
+	}
+
+	switch cell.op {
+	case diff.OpAdd:
+		return colorGreen + body + colorReset
+	case diff.OpDelete:
+		return colorRed + body + colorReset
+	default:
+		return body
+	}
+}
+
+// wrapRunes splits s into chunks of at most width runes. An empty string
+// yields a single empty chunk so the row is still printed.
+func wrapRunes(s string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var chunks []string
+	for len(runes) > width {
+		chunks = append(chunks, string(runes[:width]))
+		runes = runes[width:]
+	}
+
+	return append(chunks, string(runes))
+}
@@ -0,0 +1,77 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSideBySide(t *testing.T) {
+	parsed := parseTestDiff(t)
+
+	var buf bytes.Buffer
+	opts := output.TextOptions{LineNumbers: true, Width: 60}
+	err := output.FormatSideBySide(&buf, parsed, opts)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	require.Equal(t, "main.go", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "@@ "))
+
+	// Context appears on both sides with both line numbers.
+	require.Equal(t,
+		"   1   package main          |    1   package main", lines[2])
+
+	// The deletion is paired with the first addition of its group.
+	require.Contains(t, lines[3], "   2 - // Removed.")
+	require.Contains(t, lines[3], "|    2 + // Added line 1.")
+
+	// The unpaired addition has an empty left column.
+	require.True(t, strings.HasPrefix(lines[4], strings.Repeat(" ", 28)+" | "))
+	require.Contains(t, lines[4], "   3 + // Added line 2.")
+}
+
+func TestFormatSideBySide_Wrap(t *testing.T) {
+	diffText := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-short
++` + strings.Repeat("x", 50) + `
+`
+
+	parsed, err := diff.Parse(diffText)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	opts := output.TextOptions{LineNumbers: true, Width: 60}
+	err = output.FormatSideBySide(&buf, parsed, opts)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	// Columns are 28 wide, leaving 21 characters for content, so the
+	// 50-character line wraps onto two continuation rows.
+	require.Len(t, lines, 5)
+	for _, line := range lines[2:] {
+		require.LessOrEqual(t, len(line), 60)
+	}
+	require.Contains(t, lines[2], "   1 - short")
+	require.Contains(t, lines[3], "|        "+strings.Repeat("x", 21))
+}
+
+func TestFormatSideBySide_Color(t *testing.T) {
+	parsed := parseTestDiff(t)
+
+	var buf bytes.Buffer
+	opts := output.TextOptions{Color: true, LineNumbers: true, Width: 60}
+	err := output.FormatSideBySide(&buf, parsed, opts)
+	require.NoError(t, err)
+
+	require.Contains(t, buf.String(), "\033[31m   2 - // Removed.")
+	require.Contains(t, buf.String(), "\033[32m   2 + // Added line 1.")
+}
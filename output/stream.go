@@ -0,0 +1,111 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/schema"
+)
+
+// Record types emitted by JSONStream.
+const (
+	RecordFile    = "file"
+	RecordHunk    = "hunk"
+	RecordSummary = "summary"
+)
+
+// StreamFileRecord announces a file. It is followed by one StreamHunkRecord
+// per hunk in the file.
+type StreamFileRecord struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	FileOutput
+	HunkCount int `json:"hunk_count"`
+}
+
+// StreamHunkRecord carries a single hunk of the preceding file.
+type StreamHunkRecord struct {
+	SchemaVersion int    `json:"schema_version"`
+	Type          string `json:"type"`
+	Path          string `json:"path"`
+	Index         int    `json:"index"`
+	HunkOutput
+}
+
+// StreamSummaryRecord is the final record of a stream.
+type StreamSummaryRecord struct {
+	SchemaVersion int      `json:"schema_version"`
+	Type          string   `json:"type"`
+	Files         int      `json:"files"`
+	Hunks         int      `json:"hunks"`
+	Additions     int      `json:"additions"`
+	Deletions     int      `json:"deletions"`
+	Untracked     []string `json:"untracked,omitempty"`
+}
+
+// JSONStream writes a diff as newline-delimited JSON. Each record is
+// written as soon as its file is available, letting consumers start work
+// before the whole diff has been read.
+type JSONStream struct {
+	// RelativeTo is the repo-relative working directory. When set, file
+	// records also report their path relative to it.
+	RelativeTo string
+
+	enc     *json.Encoder
+	summary StreamSummaryRecord
+}
+
+// NewJSONStream creates a stream writing NDJSON records to w.
+func NewJSONStream(w io.Writer) *JSONStream {
+	return &JSONStream{
+		enc: json.NewEncoder(w),
+		summary: StreamSummaryRecord{
+			SchemaVersion: schema.Version,
+			Type:          RecordSummary,
+		},
+	}
+}
+
+// WriteFile writes a file record followed by a record for each hunk.
+func (s *JSONStream) WriteFile(file *diff.FileDiff) error {
+	fo := newFileOutput(file, s.RelativeTo)
+
+	err := s.enc.Encode(StreamFileRecord{
+		SchemaVersion: schema.Version,
+		Type:          RecordFile,
+		FileOutput:    fo,
+		HunkCount:     len(file.Hunks),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, hunk := range file.Hunks {
+		err := s.enc.Encode(StreamHunkRecord{
+			SchemaVersion: schema.Version,
+			Type:          RecordHunk,
+			Path:          fo.Path,
+			Index:         i,
+			HunkOutput:    newHunkOutput(hunk),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	added, deleted := file.Stats()
+	s.summary.Files++
+	s.summary.Hunks += len(file.Hunks)
+	s.summary.Additions += added
+	s.summary.Deletions += deleted
+
+	return nil
+}
+
+// Close writes the summary record. No records may be written afterwards.
+func (s *JSONStream) Close(untracked []string) error {
+	s.summary.Untracked = untracked
+
+	return s.enc.Encode(s.summary)
+}
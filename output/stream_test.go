@@ -0,0 +1,53 @@
+package output_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/roasbeef/hunk/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONStream(t *testing.T) {
+	parsed := parseTestDiff(t)
+
+	var buf bytes.Buffer
+	stream := output.NewJSONStream(&buf)
+
+	for file := range parsed.Files() {
+		require.NoError(t, stream.WriteFile(file))
+	}
+	require.NoError(t, stream.Close([]string{"new.go"}))
+
+	var types []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+
+		types = append(types, rec["type"].(string))
+
+		switch rec["type"] {
+		case output.RecordFile:
+			require.Equal(t, "main.go", rec["path"])
+			require.EqualValues(t, 1, rec["hunk_count"])
+
+		case output.RecordHunk:
+			require.Equal(t, "main.go", rec["path"])
+			require.EqualValues(t, 0, rec["index"])
+			require.NotEmpty(t, rec["lines"])
+
+		case output.RecordSummary:
+			require.EqualValues(t, 1, rec["files"])
+			require.EqualValues(t, 2, rec["additions"])
+			require.EqualValues(t, 1, rec["deletions"])
+			require.Equal(t, []any{"new.go"}, rec["untracked"])
+		}
+	}
+
+	require.Equal(t, []string{
+		output.RecordFile, output.RecordHunk, output.RecordSummary,
+	}, types)
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package output
+
+import "io"
+
+// terminalWidth is not supported on this platform and always returns zero,
+// leaving callers to fall back to $COLUMNS or the default width.
+func terminalWidth(_ io.Writer) int {
+	return 0
+}
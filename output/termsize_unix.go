@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+package output
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize mirrors the kernel's struct winsize.
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+// terminalWidth returns the column count of the terminal behind w, or zero
+// if w is not a terminal.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+
+	var ws winsize
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ),
+		uintptr(unsafe.Pointer(&ws)),
+	)
+	if errno != 0 {
+		return 0
+	}
+
+	return int(ws.cols)
+}
@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 
 	"github.com/roasbeef/hunk/diff"
@@ -27,16 +28,46 @@ type TextOptions struct {
 	// LineNumbers shows line numbers.
 	LineNumbers bool
 
+	// OldLineNumbers shows the old-file line number column next to the
+	// new-file one. Ignored unless LineNumbers is set.
+	OldLineNumbers bool
+
+	// HideContext omits unchanged context lines.
+	HideContext bool
+
+	// Section shows the enclosing section (e.g., function name) after
+	// each hunk header.
+	Section bool
+
 	// Stats shows +/- statistics.
 	Stats bool
+
+	// Truncated marks a diff that a budget cut short, so that the
+	// statistics say they only count what is shown.
+	Truncated bool
+
+	// CompactContext shows one line of context around each change block
+	// in the compact layout.
+	CompactContext bool
+
+	// Width is the total line width for layouts that wrap, such as
+	// side-by-side. Zero means 80 columns.
+	Width int
+
+	// RelativeTo is a repo-relative directory that file paths are shown
+	// relative to, e.g. "pkg" when running in the pkg subdirectory. Empty
+	// shows paths relative to the repository root.
+	RelativeTo string
 }
 
 // DefaultTextOptions returns default text formatting options.
 func DefaultTextOptions() TextOptions {
 	return TextOptions{
-		Color:       true,
-		LineNumbers: true,
-		Stats:       true,
+		Color:          true,
+		LineNumbers:    true,
+		OldLineNumbers: true,
+		Section:        true,
+		Stats:          true,
 	}
 }
 
@@ -51,19 +82,99 @@ func FormatText(
 	}
 
 	if opts.Stats {
-		added, deleted := parsed.Stats()
-		fmt.Fprintf(w, "\n%d insertions(+), %d deletions(-)\n", added, deleted)
+		formatStats(w, parsed, opts)
 	}
 
 	return nil
 }
 
+// formatStats writes the +/- statistics footer for parsed.
+func formatStats(w io.Writer, parsed *diff.ParsedDiff, opts TextOptions) {
+	added, deleted := parsed.Stats()
+	fmt.Fprintf(w, "\n%d insertions(+), %d deletions(-)", added, deleted)
+
+	if opts.Truncated {
+		fmt.Fprint(w, " in the lines shown")
+	}
+
+	fmt.Fprintln(w)
+}
+
+// fileHeader returns the name a file is listed under. Renames and copies
+// show both paths along with git's similarity score, and symlinks are
+// marked since they stage as a whole.
+func fileHeader(file *diff.FileDiff, relativeTo string) string {
+	var suffix string
+	if file.IsSymlink() {
+		suffix = " (symlink)"
+	}
+
+	var kind string
+
+	switch {
+	case file.IsRenamed:
+		kind = "renamed"
+	case file.IsCopied:
+		kind = "copied"
+	default:
+		return RelativePath(relativeTo, file.Path()) + suffix
+	}
+
+	header := fmt.Sprintf("%s -> %s (%s",
+		RelativePath(relativeTo, file.OldName),
+		RelativePath(relativeTo, file.NewName), kind)
+	if file.Similarity > 0 {
+		header += fmt.Sprintf(", %d%% similar", file.Similarity)
+	}
+
+	return header + ")" + suffix
+}
+
+// binarySummary describes a binary change by its blob ids and sizes, as far
+// as the diff gives them.
+func binarySummary(file *diff.FileDiff) string {
+	summary := "Binary file"
+
+	if file.OldBlob != "" {
+		summary += fmt.Sprintf(" %s..%s",
+			abbrevBlob(file.OldBlob), abbrevBlob(file.NewBlob))
+	}
+
+	if oldSize, newSize, ok := file.BinarySizes(); ok {
+		summary += fmt.Sprintf(" (%d -> %d bytes)", oldSize, newSize)
+	}
+
+	return summary
+}
+
+// abbrevBlob shortens an object id to git's default abbreviation.
+func abbrevBlob(id string) string {
+	const abbrevLen = 7
+	if len(id) > abbrevLen {
+		return id[:abbrevLen]
+	}
+
+	return id
+}
+
+// writeModeChange lists a file's mode change as its own entry below the
+// header, so it can be staged separately with 'hunk stage --mode'.
+func writeModeChange(w io.Writer, file *diff.FileDiff, opts TextOptions) {
+	if !file.ModeChanged() {
+		return
+	}
+
+	line := fmt.Sprintf("mode %s -> %s", file.OldMode, file.NewMode)
+	if opts.Color {
+		fmt.Fprintf(w, "%s%s%s\n", colorYellow, line, colorReset)
+	} else {
+		fmt.Fprintln(w, line)
+	}
+}
+
 func formatFile(w io.Writer, file *diff.FileDiff, opts TextOptions) error {
 	// File header.
-	header := file.Path()
-	if file.IsRenamed {
-		header = fmt.Sprintf("%s -> %s", file.OldName, file.NewName)
-	}
+	header := fileHeader(file, opts.RelativeTo)
 
 	if opts.Color {
 		fmt.Fprintf(w, "%s%s%s\n", colorCyan, header, colorReset)
@@ -71,8 +182,10 @@ func formatFile(w io.Writer, file *diff.FileDiff, opts TextOptions) error {
 		fmt.Fprintln(w, header)
 	}
 
+	writeModeChange(w, file, opts)
+
 	if file.IsBinary {
-		fmt.Fprintln(w, "Binary file")
+		fmt.Fprintln(w, binarySummary(file))
 
 		return nil
 	}
@@ -92,14 +205,28 @@ func formatFile(w io.Writer, file *diff.FileDiff, opts TextOptions) error {
 
 func formatHunk(w io.Writer, hunk *diff.Hunk, opts TextOptions) error {
 	// Hunk header.
-	header := hunk.Header()
-	if opts.Color {
+	header := fmt.Sprintf(
+		"@@ -%d,%d +%d,%d @@",
+		hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines,
+	)
+
+	switch {
+	case opts.Section && hunk.Section != "" && opts.Color:
+		fmt.Fprintf(w, "%s%s%s %s%s%s\n", colorBlue, header, colorReset,
+			colorYellow, hunk.Section, colorReset)
+	case opts.Section && hunk.Section != "":
+		fmt.Fprintf(w, "%s %s\n", header, hunk.Section)
+	case opts.Color:
 		fmt.Fprintf(w, "%s%s%s\n", colorBlue, header, colorReset)
-	} else {
+	default:
 		fmt.Fprintln(w, header)
 	}
 
 	for _, line := range hunk.Lines {
+		if opts.HideContext && line.Op == diff.OpContext {
+			continue
+		}
+
 		if err := formatLine(w, line, opts); err != nil {
 			return err
 		}
@@ -109,29 +236,44 @@ func formatHunk(w io.Writer, hunk *diff.Hunk, opts TextOptions) error {
 }
 
 func formatLine(w io.Writer, line diff.DiffLine, opts TextOptions) error {
-	var prefix, color, reset string
+	var color, numColor, reset string
 
 	if opts.Color {
 		reset = colorReset
+		numColor = colorDim
 		switch line.Op {
 		case diff.OpAdd:
 			color = colorGreen
 		case diff.OpDelete:
 			color = colorRed
-		default:
-			color = colorDim
 		}
 	}
 
-	prefix = string(line.Op.Prefix())
+	prefix := string(line.Op.Prefix())
 
 	if opts.LineNumbers {
-		oldNum := formatLineNum(line.OldLineNum)
-		newNum := formatLineNum(line.NewLineNum)
-		fmt.Fprintf(w, "%s%s %s %s%s%s\n",
-			color, oldNum, newNum, prefix, line.Content, reset)
-	} else {
+		var nums string
+		switch {
+		case opts.OldLineNumbers:
+			nums = formatLineNum(line.OldLineNum) + " " +
+				formatLineNum(line.NewLineNum)
+
+		// With a single column, deletions show their old line number
+		// since that is what a selection uses to address them.
+		case line.Op == diff.OpDelete:
+			nums = formatLineNum(line.OldLineNum)
+
+		default:
+			nums = formatLineNum(line.NewLineNum)
+		}
+
+		fmt.Fprintf(w, "%s%s%s ", numColor, nums, reset)
+	}
+
+	if color != "" {
 		fmt.Fprintf(w, "%s%s%s%s\n", color, prefix, line.Content, reset)
+	} else {
+		fmt.Fprintf(w, "%s%s\n", prefix, line.Content)
 	}
 
 	return nil
@@ -224,48 +366,71 @@ func FormatStageableLines(w io.Writer, parsed *diff.ParsedDiff) error {
 	return nil
 }
 
-// FormatStagingCommands writes suggested stage commands.
-func FormatStagingCommands(w io.Writer, parsed *diff.ParsedDiff) error {
-	for file := range parsed.Files() {
-		var ranges []string
-
-		for _, hunk := range file.Hunks {
-			// Collect line numbers of changes.
-			var start, end int
-
-			for _, line := range hunk.Lines {
-				if !line.IsChange() {
-					continue
-				}
+// hunkRanges returns the line range of each hunk's changes in selection
+// syntax, e.g. "10-20". Deletions count by their old line number, as in a
+// selection.
+func hunkRanges(hunks []*diff.Hunk) []string {
+	var ranges []string
 
-				lineNum := line.NewLineNum
-				if line.Op == diff.OpDelete {
-					lineNum = line.OldLineNum
-				}
+	for _, hunk := range hunks {
+		var start, end int
 
-				if start == 0 || lineNum < start {
-					start = lineNum
-				}
+		for _, line := range hunk.Lines {
+			if !line.IsChange() {
+				continue
+			}
 
-				if lineNum > end {
-					end = lineNum
-				}
+			lineNum := line.EffectiveLineNum()
+			if start == 0 || lineNum < start {
+				start = lineNum
 			}
 
-			if start > 0 {
-				if start == end {
-					ranges = append(ranges, fmt.Sprintf("%d", start))
-				} else {
-					ranges = append(ranges, fmt.Sprintf("%d-%d", start, end))
-				}
+			if lineNum > end {
+				end = lineNum
 			}
 		}
 
-		if len(ranges) > 0 {
-			fmt.Fprintf(w, "hunk stage %s:%s\n",
-				file.Path(), strings.Join(ranges, ","))
+		switch {
+		case start == 0:
+		case start == end:
+			ranges = append(ranges, fmt.Sprintf("%d", start))
+		default:
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, end))
 		}
 	}
 
-	return nil
+	return ranges
+}
+
+// RelativePath returns a repo-relative path relative to the repo-relative
+// directory relativeTo, e.g. "../main.go" for "main.go" seen from "pkg".
+// An empty relativeTo returns the path unchanged.
+func RelativePath(relativeTo, path string) string {
+	if relativeTo == "" {
+		return path
+	}
+
+	rel, err := filepath.Rel(
+		filepath.FromSlash(relativeTo), filepath.FromSlash(path),
+	)
+	if err != nil {
+		return path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// shellSafe holds the characters that need no quoting in a shell word.
+const shellSafe = "abcdefghijklmnopqrstuvwxyz" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"0123456789_-./:,@%+="
+
+// shellQuote single-quotes an argument for a POSIX shell unless it only
+// contains safe characters.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, shellSafe) == "" {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
 }
@@ -4,18 +4,79 @@ package patch
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/roasbeef/hunk/diff"
 )
 
+// Options controls how selections are turned into a patch.
+type Options struct {
+	// ContentOnly leaves a file's mode change out of the patch when some
+	// of its lines are selected, so the mode can be staged separately.
+	ContentOnly bool
+
+	// ModePaths lists files whose mode change is staged on its own,
+	// whether or not any of their lines are selected.
+	ModePaths []string
+
+	// Files lists files staged with all of their changes. Binary files
+	// can only be staged this way.
+	Files []string
+
+	// SubstantiveOnly leaves out selected changes that only touch
+	// whitespace, such as reindented lines and added blank lines.
+	SubstantiveOnly bool
+}
+
 // Generate creates a patch containing only the selected lines.
 // The patch can be applied with `git apply --cached`.
 func Generate(
 	parsed *diff.ParsedDiff, selections []*diff.FileSelection,
+) ([]byte, error) {
+	return GenerateWithOptions(parsed, selections, Options{})
+}
+
+// GenerateWithOptions creates a patch containing the selected lines, mode
+// changes and whole files. Selecting content of a file whose mode changed
+// stages the mode too unless opts.ContentOnly is set. Symlinks can't be
+// staged line by line: any selection naming one stages its whole change.
+// Binary files must be named in opts.Files, and need a diff taken with
+// --binary.
+func GenerateWithOptions(
+	parsed *diff.ParsedDiff, selections []*diff.FileSelection, opts Options,
 ) ([]byte, error) {
 	// Build a map for fast lookup.
 	selMap := diff.NewSelectionMap(selections)
 
+	modePaths := make(map[string]bool, len(opts.ModePaths))
+	for _, path := range opts.ModePaths {
+		file := parsed.FileByPath(path)
+		if file == nil || !file.ModeChanged() {
+			return nil, fmt.Errorf("no mode change for %s", path)
+		}
+
+		modePaths[file.Path()] = true
+	}
+
+	wholeFiles := make(map[string]bool, len(opts.Files))
+	for _, path := range opts.Files {
+		file := parsed.FileByPath(path)
+		if file == nil {
+			return nil, fmt.Errorf("no changes for %s", path)
+		}
+
+		wholeFiles[file.Path()] = true
+	}
+
+	// A change between a regular file and a symlink shows up as a
+	// deletion and an addition of the same path. Both halves are staged
+	// together.
+	for file := range parsed.Files() {
+		if file.IsSymlink() && selMap.Get(file.Path()) != nil {
+			wholeFiles[file.Path()] = true
+		}
+	}
+
 	var buf bytes.Buffer
 
 	for file := range parsed.Files() {
@@ -28,29 +89,80 @@ func Generate(
 			}
 		}
 
-		if sel == nil {
+		stageMode := modePaths[file.Path()]
+		whole := wholeFiles[file.Path()]
+
+		if file.IsBinary && sel != nil && !whole {
+			return nil, fmt.Errorf("%s is a binary file, lines can't "+
+				"be selected; stage it whole with 'hunk stage %s'",
+				file.Path(), file.Path())
+		}
+
+		var filteredHunks []*diff.Hunk
+
+		switch {
+		case opts.SubstantiveOnly && (whole || sel != nil) &&
+			!file.IsBinary && !file.IsSymlink():
+
+			if whole {
+				sel = nil
+			}
+			filteredHunks = filterSubstantive(file.Hunks, sel)
+
+			// Nothing to stage if every change was whitespace.
+			if len(filteredHunks) == 0 && len(file.Hunks) > 0 &&
+				!stageMode {
+
+				continue
+			}
+
+		case whole:
+			filteredHunks = file.Hunks
+
+		case sel == nil && !stageMode:
 			continue
+
+		case sel == nil:
+			// Mode change only.
+
+		default:
+			filteredHunks = filterHunks(file.Hunks, sel)
 		}
 
-		// Filter hunks to only include selected lines.
-		filteredHunks := filterHunks(file.Hunks, sel)
-		if len(filteredHunks) == 0 {
+		// A pure rename or copy has no lines, so naming it in a
+		// selection stages the rename itself.
+		if len(filteredHunks) == 0 && !stageMode && !whole &&
+			!(sel != nil && isPureRenameOrCopy(file)) {
+
 			continue
 		}
 
-		// Write file header.
-		fmt.Fprintf(&buf, "--- a/%s\n", file.OldName)
-		fmt.Fprintf(&buf, "+++ b/%s\n", file.NewName)
+		if whole && file.IsBinary {
+			if file.BinaryPatch == "" {
+				return nil, fmt.Errorf("no binary patch data for %s, "+
+					"diff with --binary", file.Path())
+			}
+
+			parts := allParts
+			parts.mode = !opts.ContentOnly || stageMode
+			writeFileHeader(&buf, file, parts)
+			writeBinaryPatch(&buf, file)
+
+			continue
+		}
+
+		parts := headerParts{
+			whole: countChanges(filteredHunks) ==
+				countChanges(file.Hunks),
+			mode: stageMode || ((len(filteredHunks) > 0 || whole) &&
+				!opts.ContentOnly),
+			index: len(filteredHunks) > 0 || sel != nil || whole,
+		}
+		writeFileHeader(&buf, file, parts)
 
 		// Write hunks.
 		for _, hunk := range filteredHunks {
-			buf.WriteString(hunk.Header())
-			buf.WriteByte('\n')
-
-			for _, line := range hunk.Lines {
-				buf.WriteString(line.String())
-				buf.WriteByte('\n')
-			}
+			buf.WriteString(hunk.Format())
 		}
 	}
 
@@ -78,10 +190,15 @@ type changeBlock struct {
 	endIdx   int // Index where this block ends (exclusive).
 }
 
+// maxContext is the number of context lines kept on each side of a block
+// of selected changes.
+const maxContext = 3
+
 // filterHunk filters a single hunk based on selection. When non-contiguous
 // changes are selected, the hunk is split into multiple hunks, one for each
-// contiguous block of selected changes. Each resulting hunk is independently
-// valid for git apply.
+// contiguous block of selected changes. Blocks whose context would overlap
+// share a hunk instead, since git apply rejects overlapping hunks. Each
+// resulting hunk is independently valid for git apply.
 func filterHunk(hunk *diff.Hunk, sel *diff.FileSelection) []*diff.Hunk {
 	// Find contiguous blocks of selected changes.
 	blocks := findChangeBlocks(hunk, sel)
@@ -89,10 +206,31 @@ func filterHunk(hunk *diff.Hunk, sel *diff.FileSelection) []*diff.Hunk {
 		return nil
 	}
 
+	// Mark the selected changes, which bound each block's context.
+	selected := make([]bool, len(hunk.Lines))
+	for _, block := range blocks {
+		for i := block.startIdx; i < block.endIdx; i++ {
+			selected[i] = hunk.Lines[i].IsChange()
+		}
+	}
+
+	// Join blocks too close together to get their own context.
+	joined := blocks[:1]
+	for _, block := range blocks[1:] {
+		last := &joined[len(joined)-1]
+		if contextLines(hunk, last.endIdx, block.startIdx) <= 2*maxContext {
+			last.endIdx = block.endIdx
+
+			continue
+		}
+
+		joined = append(joined, block)
+	}
+
 	// Build a separate hunk for each block.
 	var result []*diff.Hunk
-	for _, block := range blocks {
-		h := buildHunkFromBlock(hunk, block)
+	for _, block := range joined {
+		h := buildHunkFromBlock(hunk, block, selected)
 		if h != nil {
 			result = append(result, h)
 		}
@@ -101,6 +239,20 @@ func filterHunk(hunk *diff.Hunk, sel *diff.FileSelection) []*diff.Hunk {
 	return result
 }
 
+// contextLines returns the number of lines of hunk from index start up to
+// end that stay in the file when none of their changes are applied: the
+// context lines and the deletions.
+func contextLines(hunk *diff.Hunk, start, end int) int {
+	var n int
+	for _, line := range hunk.Lines[start:end] {
+		if line.Op != diff.OpAdd {
+			n++
+		}
+	}
+
+	return n
+}
+
 // findChangeBlocks identifies contiguous blocks of selected changes within a
 // hunk. Context lines do not break contiguity - only unselected change lines
 // create block boundaries.
@@ -151,8 +303,7 @@ func findChangeBlocks(hunk *diff.Hunk, sel *diff.FileSelection) []changeBlock {
 			cur.hasDel = true
 		}
 
-		lineNum := effectiveLineNum(line)
-		if sel.Contains(lineNum) {
+		if sel.Selects(line) {
 			selected[i] = true
 			cur.anySelected = true
 		}
@@ -200,40 +351,70 @@ func findChangeBlocks(hunk *diff.Hunk, sel *diff.FileSelection) []changeBlock {
 }
 
 // buildHunkFromBlock creates a valid hunk from a change block. It includes
-// up to maxContext (3) lines of context before and after the block, stopping
-// at unselected change lines.
-func buildHunkFromBlock(original *diff.Hunk, block changeBlock) *diff.Hunk {
-	const maxContext = 3
+// up to maxContext lines of context before and after the block, stopping
+// at the selected changes of other blocks. Unselected changes around and
+// within the block are left out of the patch: their deletions stay in the
+// file and so serve as context, and their additions are skipped. Without
+// this a block between unselected changes would have no context, and git
+// apply anchors a hunk without trailing context to the end of the file.
+func buildHunkFromBlock(
+	original *diff.Hunk, block changeBlock, selected []bool,
+) *diff.Hunk {
+	// asContext returns the line as it appears around the block, and
+	// false for a line the block's hunk must not include.
+	asContext := func(line diff.DiffLine) (diff.DiffLine, bool) {
+		if line.Op == diff.OpDelete {
+			line.Op = diff.OpContext
+		}
+
+		return line, line.Op == diff.OpContext
+	}
 
 	// Expand backward to include context lines.
+	var before []diff.DiffLine
 	startIdx := block.startIdx
-	contextBefore := 0
-	for i := block.startIdx - 1; i >= 0 && contextBefore < maxContext; i-- {
-		if original.Lines[i].Op == diff.OpContext {
-			startIdx = i
-			contextBefore++
-		} else {
-			// Hit an unselected change line, stop expanding.
+	for i := block.startIdx - 1; i >= 0 && len(before) < maxContext; i-- {
+		if selected[i] {
 			break
 		}
+
+		if ctx, ok := asContext(original.Lines[i]); ok {
+			before = append([]diff.DiffLine{ctx}, before...)
+			startIdx = i
+		}
 	}
 
 	// Expand forward to include context lines.
-	endIdx := block.endIdx
-	contextAfter := 0
-	for i := block.endIdx; i < len(original.Lines) && contextAfter < maxContext; i++ {
-		if original.Lines[i].Op == diff.OpContext {
-			endIdx = i + 1
-			contextAfter++
-		} else {
-			// Hit an unselected change line, stop expanding.
+	var after []diff.DiffLine
+	for i := block.endIdx; i < len(original.Lines) &&
+		len(after) < maxContext; i++ {
+
+		if selected[i] {
 			break
 		}
+
+		if ctx, ok := asContext(original.Lines[i]); ok {
+			after = append(after, ctx)
+		}
 	}
 
-	// Copy the selected lines.
-	lines := make([]diff.DiffLine, endIdx-startIdx)
-	copy(lines, original.Lines[startIdx:endIdx])
+	// Copy the block between the context. A joined block may hold
+	// unselected changes too.
+	lines := make([]diff.DiffLine, 0,
+		len(before)+block.endIdx-block.startIdx+len(after))
+	lines = append(lines, before...)
+	for i := block.startIdx; i < block.endIdx; i++ {
+		line := original.Lines[i]
+		if !selected[i] {
+			var ok bool
+			if line, ok = asContext(line); !ok {
+				continue
+			}
+		}
+
+		lines = append(lines, line)
+	}
+	lines = append(lines, after...)
 
 	result := &diff.Hunk{
 		Section: original.Section,
@@ -276,34 +457,167 @@ func buildHunkFromBlock(original *diff.Hunk, block changeBlock) *diff.Hunk {
 
 	result.RecalculateLineCounts()
 
+	// A deletion kept as context has no new line number, so a hunk
+	// starting with one in a deleted file finds none.
+	if result.NewStart == 0 && result.NewLines > 0 {
+		result.NewStart = 1
+	}
+
 	return result
 }
 
-// effectiveLineNum returns the line number to use for selection matching.
-// For additions, uses NewLineNum. For deletions, uses OldLineNum.
-func effectiveLineNum(line diff.DiffLine) int {
-	if line.Op == diff.OpAdd {
-		return line.NewLineNum
+// headerParts selects what a file header describes.
+type headerParts struct {
+	// whole is true when the patch carries all of the file's content
+	// changes. A partially staged deletion patches the file rather than
+	// removing it.
+	whole bool
+
+	// mode includes the file's mode change.
+	mode bool
+
+	// index includes the index line naming the preimage blob. A patch
+	// that only changes the mode doesn't depend on the content.
+	index bool
+}
+
+// allParts describes a patch carrying every change to a file.
+var allParts = headerParts{whole: true, mode: true, index: true}
+
+// writeFileHeader writes the patch header for a file. Files parsed from a
+// git diff get a git-style header carrying their mode, rename/copy and index
+// lines, so git apply moves, copies, creates or deletes the file in the
+// index as needed and the preimage blob can be checked against the index
+// with VerifyPreimage.
+func writeFileHeader(buf *bytes.Buffer, file *diff.FileDiff, parts headerParts) {
+	if !hasGitHeader(file) {
+		fmt.Fprintf(buf, "--- %s\n", headerPath("a/", file.OldName))
+		fmt.Fprintf(buf, "+++ %s\n", headerPath("b/", file.NewName))
+
+		return
+	}
+
+	oldPath, newPath := file.OldName, file.NewName
+	if file.IsNew {
+		oldPath = newPath
+	}
+	if file.IsDeleted {
+		newPath = oldPath
+	}
+
+	deleted := file.IsDeleted && parts.whole
+	modeChanged := parts.mode && file.ModeChanged()
+
+	fmt.Fprintf(buf, "diff --git %s %s\n",
+		diff.GitQuote("a/"+oldPath), diff.GitQuote("b/"+newPath))
+
+	switch {
+	case file.IsNew:
+		fmt.Fprintf(buf, "new file mode %s\n", file.NewMode)
+	case deleted:
+		fmt.Fprintf(buf, "deleted file mode %s\n", file.OldMode)
+	case modeChanged:
+		fmt.Fprintf(buf, "old mode %s\n", file.OldMode)
+		fmt.Fprintf(buf, "new mode %s\n", file.NewMode)
+	}
+
+	if file.IsRenamed || file.IsCopied {
+		kind := "rename"
+		if file.IsCopied {
+			kind = "copy"
+		}
+
+		if file.Similarity > 0 {
+			fmt.Fprintf(buf, "similarity index %d%%\n", file.Similarity)
+		}
+		fmt.Fprintf(buf, "%s from %s\n", kind, diff.GitQuote(oldPath))
+		fmt.Fprintf(buf, "%s to %s\n", kind, diff.GitQuote(newPath))
+	}
+
+	if parts.index && file.OldBlob != "" {
+		fmt.Fprintf(buf, "index %s..%s", file.OldBlob, file.NewBlob)
+		if !file.IsNew && !deleted && !modeChanged && file.OldMode != "" {
+			fmt.Fprintf(buf, " %s", file.OldMode)
+		}
+		buf.WriteByte('\n')
+	}
+
+	// Renames, copies and mode changes without content changes have no
+	// hunks and so no file markers.
+	if len(file.Hunks) == 0 || !parts.index {
+		return
+	}
+
+	if file.IsNew {
+		buf.WriteString("--- /dev/null\n")
+	} else {
+		fmt.Fprintf(buf, "--- %s\n", headerPath("a/", oldPath))
+	}
+
+	if deleted {
+		buf.WriteString("+++ /dev/null\n")
+	} else {
+		fmt.Fprintf(buf, "+++ %s\n", headerPath("b/", newPath))
+	}
+}
+
+// headerPath formats a path for a ---/+++ line. Like git, a trailing tab
+// ends names containing spaces, which would otherwise be read as the start
+// of a timestamp.
+func headerPath(prefix, path string) string {
+	quoted := diff.GitQuote(prefix + path)
+	if strings.Contains(quoted, " ") && !strings.HasPrefix(quoted, `"`) {
+		quoted += "\t"
 	}
 
-	return line.OldLineNum
+	return quoted
+}
+
+// writeBinaryPatch writes the body of a binary file's patch. Binary patches
+// have no file markers or hunks.
+func writeBinaryPatch(buf *bytes.Buffer, file *diff.FileDiff) {
+	buf.WriteString("GIT binary patch\n")
+	buf.WriteString(file.BinaryPatch)
+}
+
+// hasGitHeader reports whether a file carries any of git's extended header
+// information. Plain unified diffs get a plain header.
+func hasGitHeader(file *diff.FileDiff) bool {
+	return file.OldBlob != "" || file.OldMode != "" || file.NewMode != "" ||
+		file.IsRenamed || file.IsCopied
+}
+
+// countChanges returns the number of added and deleted lines in hunks.
+func countChanges(hunks []*diff.Hunk) int {
+	n := 0
+	for _, hunk := range hunks {
+		added, deleted := hunk.Stats()
+		n += added + deleted
+	}
+
+	return n
+}
+
+// isPureRenameOrCopy reports whether a file was renamed or copied without
+// any content changes.
+func isPureRenameOrCopy(file *diff.FileDiff) bool {
+	return (file.IsRenamed || file.IsCopied) && len(file.Hunks) == 0
 }
 
 // GenerateForFile creates a patch for a single file with all its changes.
 func GenerateForFile(file *diff.FileDiff) []byte {
 	var buf bytes.Buffer
 
-	fmt.Fprintf(&buf, "--- a/%s\n", file.OldName)
-	fmt.Fprintf(&buf, "+++ b/%s\n", file.NewName)
+	writeFileHeader(&buf, file, allParts)
 
-	for _, hunk := range file.Hunks {
-		buf.WriteString(hunk.Header())
-		buf.WriteByte('\n')
+	if file.BinaryPatch != "" {
+		writeBinaryPatch(&buf, file)
 
-		for _, line := range hunk.Lines {
-			buf.WriteString(line.String())
-			buf.WriteByte('\n')
-		}
+		return buf.Bytes()
+	}
+
+	for _, hunk := range file.Hunks {
+		buf.WriteString(hunk.Format())
 	}
 
 	return buf.Bytes()
@@ -313,16 +627,11 @@ func GenerateForFile(file *diff.FileDiff) []byte {
 func GenerateForHunk(file *diff.FileDiff, hunk *diff.Hunk) []byte {
 	var buf bytes.Buffer
 
-	fmt.Fprintf(&buf, "--- a/%s\n", file.OldName)
-	fmt.Fprintf(&buf, "+++ b/%s\n", file.NewName)
-
-	buf.WriteString(hunk.Header())
-	buf.WriteByte('\n')
+	parts := allParts
+	parts.whole = len(file.Hunks) == 1
+	writeFileHeader(&buf, file, parts)
 
-	for _, line := range hunk.Lines {
-		buf.WriteString(line.String())
-		buf.WriteByte('\n')
-	}
+	buf.WriteString(hunk.Format())
 
 	return buf.Bytes()
 }
@@ -242,24 +242,28 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 			diffText: `diff --git a/main.go b/main.go
 --- a/main.go
 +++ b/main.go
-@@ -1,7 +1,10 @@
+@@ -1,10 +1,13 @@
  package main
 +// Line 2 - SELECTED.
  func foo() {}
  func bar() {}
  func baz() {}
-+// Line 6 - NOT selected.
+ func quux() {}
+ func corge() {}
++// Line 8 - NOT selected.
+ func grault() {}
+ func garply() {}
  func qux() {}
-+// Line 8 - SELECTED.
++// Line 12 - SELECTED.
  func main() {}
 `,
-			selections: []string{"main.go:2,8"},
+			selections: []string{"main.go:2,12"},
 			wantHunks:  2,
 			validate: func(t *testing.T, result []byte) {
 				s := string(result)
 				require.Contains(t, s, "+// Line 2 - SELECTED.")
-				require.Contains(t, s, "+// Line 8 - SELECTED.")
-				require.NotContains(t, s, "+// Line 6 - NOT selected.")
+				require.Contains(t, s, "+// Line 12 - SELECTED.")
+				require.NotContains(t, s, "+// Line 8 - NOT selected.")
 			},
 		},
 		{
@@ -267,16 +271,21 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 			diffText: `diff --git a/main.go b/main.go
 --- a/main.go
 +++ b/main.go
-@@ -1,7 +1,10 @@
+@@ -1,9 +1,12 @@
  package main
 +// FIRST.
  func a() {}
-+// MIDDLE.
  func b() {}
-+// LAST.
  func c() {}
+ func d() {}
++// MIDDLE.
+ func e() {}
+ func f() {}
+ func g() {}
++// LAST.
+ func h() {}
 `,
-			selections: []string{"main.go:2,6"},
+			selections: []string{"main.go:2,11"},
 			wantHunks:  2,
 			validate: func(t *testing.T, result []byte) {
 				s := string(result)
@@ -334,16 +343,22 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 			diffText: `diff --git a/main.go b/main.go
 --- a/main.go
 +++ b/main.go
-@@ -1,7 +1,4 @@
+@@ -1,12 +1,9 @@
  package main
 -// DELETE 1.
  func foo() {}
--// DELETE 2.
  func bar() {}
+ func baz() {}
+ func quux() {}
+-// DELETE 2.
+ func corge() {}
+ func grault() {}
+ func garply() {}
 -// DELETE 3.
  func main() {}
 `,
-			selections: []string{"main.go:2,6"}, // Old file line numbers.
+			// Old file line numbers.
+			selections: []string{"main.go:2,11"},
 			wantHunks:  2,
 			validate: func(t *testing.T, result []byte) {
 				s := string(result)
@@ -378,28 +393,60 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 			diffText: `diff --git a/main.go b/main.go
 --- a/main.go
 +++ b/main.go
-@@ -1,9 +1,14 @@
+@@ -1,16 +1,21 @@
  package main
 +// 2 SELECTED.
  func a() {}
-+// 4 skip.
  func b() {}
-+// 6 SELECTED.
  func c() {}
-+// 8 skip.
++// 6 skip.
  func d() {}
-+// 10 SELECTED.
+ func e() {}
+ func f() {}
+ func g() {}
++// 11 SELECTED.
+ func h() {}
+ func i() {}
+ func j() {}
++// 15 skip.
+ func k() {}
+ func l() {}
+ func m() {}
+ func n() {}
++// 20 SELECTED.
  func main() {}
 `,
-			selections: []string{"main.go:2,6,10"},
+			selections: []string{"main.go:2,11,20"},
 			wantHunks:  3,
 			validate: func(t *testing.T, result []byte) {
 				s := string(result)
 				require.Contains(t, s, "+// 2 SELECTED.")
-				require.Contains(t, s, "+// 6 SELECTED.")
-				require.Contains(t, s, "+// 10 SELECTED.")
-				require.NotContains(t, s, "+// 4 skip.")
-				require.NotContains(t, s, "+// 8 skip.")
+				require.Contains(t, s, "+// 11 SELECTED.")
+				require.Contains(t, s, "+// 20 SELECTED.")
+				require.NotContains(t, s, "+// 6 skip.")
+				require.NotContains(t, s, "+// 15 skip.")
+			},
+		},
+		{
+			// Blocks whose context would overlap share a hunk,
+			// since git apply rejects overlapping hunks. The
+			// unselected deletion between them stays as context.
+			name: "nearby blocks share a hunk",
+			diffText: `--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,4 @@
+ l1
+-X
+-Y
+ l2
++l3
+ l4
+`,
+			selections: []string{"f.txt:-2,+3"},
+			wantHunks:  1,
+			validate: func(t *testing.T, result []byte) {
+				require.Contains(t, string(result), "@@ -1,5 +1,5 @@\n"+
+					" l1\n-X\n Y\n l2\n+l3\n l4\n")
 			},
 		},
 		{
@@ -482,6 +529,49 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 				require.NotContains(t, s, "+// line C.")
 			},
 		},
+		{
+			// Unselected deletions around the selected one stay
+			// in the file, so they are its context. Without any,
+			// git apply would anchor the hunk to the file's end.
+			name: "deletion between unselected deletions",
+			diffText: `--- a/main.go
++++ b/main.go
+@@ -1,6 +1,2 @@
+ package main
+-// del1.
+-// del2.
+-// del3.
+-// del4.
+ func main() {}
+`,
+			selections: []string{"main.go:3"},
+			wantHunks:  1,
+			validate: func(t *testing.T, result []byte) {
+				require.Contains(t, string(result), "@@ -1,6 +1,5 @@\n"+
+					" package main\n // del1.\n-// del2.\n"+
+					" // del3.\n // del4.\n func main() {}\n")
+			},
+		},
+		{
+			// Unselected additions aren't in the file yet, so the
+			// context around the selected one skips them.
+			name: "addition between unselected additions",
+			diffText: `--- a/main.go
++++ b/main.go
+@@ -1,2 +1,5 @@
+ package main
++// line A.
++// line B.
++// line C.
+ func main() {}
+`,
+			selections: []string{"main.go:3"},
+			wantHunks:  1,
+			validate: func(t *testing.T, result []byte) {
+				require.Contains(t, string(result), "@@ -1,2 +1,3 @@\n"+
+					" package main\n+// line B.\n func main() {}\n")
+			},
+		},
 		{
 			// When a range boundary splits a mixed replacement
 			// group, the entire group is included. This is the
@@ -549,3 +639,540 @@ func TestGenerate_NonContiguousSelections(t *testing.T) {
 		})
 	}
 }
+
+// TestGenerate_Rename tests that patches for renamed and copied files carry
+// the extended headers git apply needs to move the file in the index.
+func TestGenerate_Rename(t *testing.T) {
+	tests := []struct {
+		name     string
+		diffText string
+		args     []string
+		want     string
+	}{
+		{
+			name: "rename with partial selection",
+			diffText: `diff --git a/old.go b/new.go
+similarity index 80%
+rename from old.go
+rename to new.go
+index 0ff3bbb..fb3ced1 100644
+--- a/old.go
++++ b/new.go
+@@ -1,6 +1,6 @@
+ package main
+-var a = 1
++var a = 2
+ func main() {}
+ func helper() {}
+-var b = 1
++var b = 2
+`,
+			args: []string{"new.go:2"},
+			want: `diff --git a/old.go b/new.go
+similarity index 80%
+rename from old.go
+rename to new.go
+index 0ff3bbb..fb3ced1 100644
+--- a/old.go
++++ b/new.go
+@@ -1,5 +1,5 @@
+ package main
+-var a = 1
++var a = 2
+ func main() {}
+ func helper() {}
+ var b = 1
+`,
+		},
+		{
+			name: "pure rename",
+			diffText: `diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+`,
+			args: []string{"new.go:1"},
+			want: `diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+`,
+		},
+		{
+			name: "copy",
+			diffText: `diff --git a/a.go b/b.go
+similarity index 95%
+copy from a.go
+copy to b.go
+--- a/a.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+ package main
++// Copied.
+ func main() {}
+`,
+			args: []string{"b.go:2"},
+			want: `diff --git a/a.go b/b.go
+similarity index 95%
+copy from a.go
+copy to b.go
+--- a/a.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+ package main
++// Copied.
+ func main() {}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := diff.Parse(tt.diffText)
+			require.NoError(t, err)
+
+			sels, err := diff.ParseSelections(tt.args)
+			require.NoError(t, err)
+
+			result, err := patch.Generate(parsed, sels)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+// TestGenerate_ExtendedHeaders tests that mode and index headers are carried
+// into generated patches, and that a partially staged deletion patches the
+// file instead of removing it.
+func TestGenerate_ExtendedHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		diffText string
+		args     []string
+		want     string
+	}{
+		{
+			name: "index line with mode",
+			diffText: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+`,
+			args: []string{"main.go:2"},
+			want: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+`,
+		},
+		{
+			name: "mode change",
+			diffText: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1 +1,2 @@
+ #!/bin/sh
++echo hi
+`,
+			args: []string{"run.sh:2"},
+			want: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1,1 +1,2 @@
+ #!/bin/sh
++echo hi
+`,
+		},
+		{
+			name: "new file",
+			diffText: `diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..2222222
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++func main() {}
+`,
+			args: []string{"new.go:1"},
+			want: `diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..2222222
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,1 @@
++package main
+`,
+		},
+		{
+			name: "whole deletion",
+			diffText: `diff --git a/old.go b/old.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-func main() {}
+`,
+			args: []string{"old.go:1-2"},
+			want: `diff --git a/old.go b/old.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-func main() {}
+`,
+		},
+		{
+			name: "partial deletion",
+			diffText: `diff --git a/old.go b/old.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-func main() {}
+`,
+			args: []string{"old.go:2"},
+			want: `diff --git a/old.go b/old.go
+index 1111111..0000000 100644
+--- a/old.go
++++ b/old.go
+@@ -1,2 +1,1 @@
+ package main
+-func main() {}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := diff.Parse(tt.diffText)
+			require.NoError(t, err)
+
+			sels, err := diff.ParseSelections(tt.args)
+			require.NoError(t, err)
+
+			result, err := patch.Generate(parsed, sels)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+func TestGenerateWithOptions(t *testing.T) {
+	const modeDiff = `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1 +1,2 @@
+ #!/bin/sh
++echo hi
+`
+
+	const symlinkDiff = `diff --git a/link b/link
+index 1111111..2222222 120000
+--- a/link
++++ b/link
+@@ -1 +1 @@
+-old-target
+\ No newline at end of file
++new-target
+\ No newline at end of file
+`
+
+	tests := []struct {
+		name     string
+		diffText string
+		args     []string
+		opts     patch.Options
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "mode only",
+			diffText: modeDiff,
+			opts:     patch.Options{ModePaths: []string{"run.sh"}},
+			want: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+		},
+		{
+			name:     "content only",
+			diffText: modeDiff,
+			args:     []string{"run.sh:2"},
+			opts:     patch.Options{ContentOnly: true},
+			want: `diff --git a/run.sh b/run.sh
+index 1111111..2222222 100644
+--- a/run.sh
++++ b/run.sh
+@@ -1,1 +1,2 @@
+ #!/bin/sh
++echo hi
+`,
+		},
+		{
+			name:     "mode and content",
+			diffText: modeDiff,
+			args:     []string{"run.sh:2"},
+			opts: patch.Options{
+				ModePaths: []string{"run.sh"}, ContentOnly: true,
+			},
+			want: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+index 1111111..2222222
+--- a/run.sh
++++ b/run.sh
+@@ -1,1 +1,2 @@
+ #!/bin/sh
++echo hi
+`,
+		},
+		{
+			name:     "no mode change",
+			diffText: symlinkDiff,
+			opts:     patch.Options{ModePaths: []string{"link"}},
+			wantErr:  "no mode change for link",
+		},
+		{
+			name:     "symlink staged whole",
+			diffText: symlinkDiff,
+			args:     []string{"link:1"},
+			want: `diff --git a/link b/link
+index 1111111..2222222 120000
+--- a/link
++++ b/link
+@@ -1,1 +1,1 @@
+-old-target
+\ No newline at end of file
++new-target
+\ No newline at end of file
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := diff.Parse(tt.diffText)
+			require.NoError(t, err)
+
+			sels, err := diff.ParseSelections(tt.args)
+			require.NoError(t, err)
+
+			result, err := patch.GenerateWithOptions(parsed, sels, tt.opts)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+func TestGenerate_Binary(t *testing.T) {
+	const binaryDiff = `diff --git a/a.bin b/a.bin
+index 96db3e1c616a9650209b6a2491a6a663261c7edf..9f515e09a5927af627893671206eade3db75cce0 100644
+GIT binary patch
+literal 8
+PcmYdHN@hq&O-ly=3<3hZ
+
+literal 7
+OcmYdHN@hq&O#=W4MFLg;
+
+`
+
+	parsed, err := diff.Parse(binaryDiff)
+	require.NoError(t, err)
+
+	// The whole file reproduces git's patch.
+	result, err := patch.GenerateWithOptions(
+		parsed, nil, patch.Options{Files: []string{"a.bin"}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, binaryDiff, string(result))
+
+	// Lines of a binary file can't be selected.
+	sels, err := diff.ParseSelections([]string{"a.bin:1"})
+	require.NoError(t, err)
+
+	_, err = patch.Generate(parsed, sels)
+	require.ErrorContains(t, err, "a.bin is a binary file")
+
+	// Without the binary patch there's nothing to stage.
+	parsed, err = diff.Parse(`diff --git a/a.bin b/a.bin
+index 96db3e1..9f515e0 100644
+Binary files a/a.bin and b/a.bin differ
+`)
+	require.NoError(t, err)
+
+	_, err = patch.GenerateWithOptions(
+		parsed, nil, patch.Options{Files: []string{"a.bin"}},
+	)
+	require.ErrorContains(t, err, "no binary patch data for a.bin")
+}
+
+func TestGenerate_WholeFile(t *testing.T) {
+	parsed, err := diff.Parse(`diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,4 @@
+ package main
++// One.
++// Two.
+ func main() {}
+`)
+	require.NoError(t, err)
+
+	result, err := patch.GenerateWithOptions(
+		parsed, nil, patch.Options{Files: []string{"main.go"}},
+	)
+	require.NoError(t, err)
+	require.Contains(t, string(result), "+// One.\n+// Two.\n")
+
+	_, err = patch.GenerateWithOptions(
+		parsed, nil, patch.Options{Files: []string{"other.go"}},
+	)
+	require.ErrorContains(t, err, "no changes for other.go")
+}
+
+func TestGenerate_SubstantiveOnly(t *testing.T) {
+	parsed, err := diff.Parse(`diff --git a/m.go b/m.go
+index 1111111..2222222 100644
+--- a/m.go
++++ b/m.go
+@@ -1,3 +1,4 @@
+ func a() {
+-x := 1
++	x := 1
++
+ }
+@@ -10,4 +11,4 @@ func b() {
+ func b() {
+-	y := 2
+-z  :=  3
++	y := 3
++	z := 3
+ }
+`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts patch.Options
+		args []string
+		want string
+	}{
+		{
+			name: "whole file",
+			opts: patch.Options{
+				Files: []string{"m.go"}, SubstantiveOnly: true,
+			},
+			want: `diff --git a/m.go b/m.go
+index 1111111..2222222 100644
+--- a/m.go
++++ b/m.go
+@@ -10,4 +10,4 @@ func b() {
+ func b() {
+-	y := 2
++	y := 3
+ z  :=  3
+ }
+`,
+		},
+		{
+			name: "whitespace-only selection",
+			opts: patch.Options{SubstantiveOnly: true},
+			args: []string{"m.go:2-3"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sels, err := diff.ParseSelections(tt.args)
+			require.NoError(t, err)
+
+			result, err := patch.GenerateWithOptions(parsed, sels, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+func TestGenerate_QuotedPaths(t *testing.T) {
+	parsed, err := diff.Parse(`diff --git a/old.go "b/n\303\253w:file.go"
+similarity index 90%
+rename from old.go
+rename to "n\303\253w:file.go"
+index 1111111..2222222 100644
+--- a/old.go
++++ "b/n\303\253w:file.go"
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+diff --git a/my file.go b/my file.go
+index 1111111..2222222 100644
+--- a/my file.go	
++++ b/my file.go	
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+`)
+	require.NoError(t, err)
+
+	sels, err := diff.ParseSelections([]string{
+		`"nëw:file.go":2`, "my file.go:2",
+	})
+	require.NoError(t, err)
+
+	result, err := patch.Generate(parsed, sels)
+	require.NoError(t, err)
+	require.Equal(t, `diff --git a/old.go "b/n\303\253w:file.go"
+similarity index 90%
+rename from old.go
+rename to "n\303\253w:file.go"
+index 1111111..2222222 100644
+--- a/old.go
++++ "b/n\303\253w:file.go"
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+diff --git a/my file.go b/my file.go
+index 1111111..2222222 100644
+--- a/my file.go	
++++ b/my file.go	
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+`, string(result))
+}
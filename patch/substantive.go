@@ -0,0 +1,158 @@
+package patch
+
+import (
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// filterSubstantive returns hunks staging only the selected change lines
+// that differ by more than whitespace. A nil selection selects every change.
+// Changes left out stay in the working tree: an unstaged deletion becomes a
+// context line and an unstaged addition is dropped, so each hunk still
+// applies to the index content.
+func filterSubstantive(
+	hunks []*diff.Hunk, sel *diff.FileSelection,
+) []*diff.Hunk {
+	var (
+		result []*diff.Hunk
+
+		// skipped is the net line count of changes left out of earlier
+		// hunks, by which later hunks move up in the new file.
+		skipped int
+	)
+
+	for _, hunk := range hunks {
+		h := &diff.Hunk{
+			OldStart: hunk.OldStart,
+			NewStart: hunk.NewStart - skipped,
+			Section:  hunk.Section,
+		}
+
+		var staged bool
+		for start := 0; start < len(hunk.Lines); {
+			line := hunk.Lines[start]
+			if !line.IsChange() {
+				h.Lines = append(h.Lines, line)
+				start++
+
+				continue
+			}
+
+			end := start
+			for end < len(hunk.Lines) && hunk.Lines[end].IsChange() {
+				end++
+			}
+
+			run := substantiveRun(hunk.Lines[start:end], sel)
+			for _, l := range run {
+				if l.IsChange() {
+					staged = true
+				}
+			}
+
+			h.Lines = append(h.Lines, run...)
+			start = end
+		}
+
+		h.RecalculateLineCounts()
+		skipped += hunk.NewLines - h.NewLines
+
+		if staged {
+			result = append(result, h)
+		}
+	}
+
+	return result
+}
+
+// substantiveRun rewrites a run of change lines to stage only its selected
+// substantive changes. Deletions are paired in order with additions that
+// match them ignoring whitespace; paired lines and blank lines are
+// whitespace-only and stay unstaged. Each pair is kept as a context line
+// holding the old content, with staged additions placed around the pairs
+// in new-file order.
+func substantiveRun(
+	lines []diff.DiffLine, sel *diff.FileSelection,
+) []diff.DiffLine {
+	var (
+		deletes, adds []int
+		keep          = make([]bool, len(lines))
+		partner       = make(map[int]int)
+	)
+
+	for i, line := range lines {
+		keep[i] = strings.TrimSpace(line.Content) != ""
+
+		if line.Op == diff.OpDelete {
+			deletes = append(deletes, i)
+		} else {
+			adds = append(adds, i)
+		}
+	}
+
+	next := 0
+	for _, d := range deletes {
+		if !keep[d] {
+			continue
+		}
+
+		for j := next; j < len(adds); j++ {
+			a := adds[j]
+			if keep[a] && squashSpace(lines[d].Content) ==
+				squashSpace(lines[a].Content) {
+
+				keep[d], keep[a] = false, false
+				partner[d] = a
+				next = j + 1
+
+				break
+			}
+		}
+	}
+
+	if sel != nil {
+		for i, line := range lines {
+			if !sel.Selects(line) {
+				keep[i] = false
+			}
+		}
+	}
+
+	var (
+		result  []diff.DiffLine
+		nextAdd int
+	)
+
+	// emitAdds stages the kept additions before position limit.
+	emitAdds := func(limit int) {
+		for ; nextAdd < len(adds) && adds[nextAdd] < limit; nextAdd++ {
+			if keep[adds[nextAdd]] {
+				result = append(result, lines[adds[nextAdd]])
+			}
+		}
+	}
+
+	for _, d := range deletes {
+		line := lines[d]
+
+		if a, ok := partner[d]; ok {
+			emitAdds(a)
+			nextAdd++ // Skip the partner itself.
+		}
+
+		if !keep[d] {
+			line.Op = diff.OpContext
+		}
+		result = append(result, line)
+	}
+	emitAdds(len(lines))
+
+	return result
+}
+
+// squashSpace removes all whitespace from s, matching git diff
+// --ignore-all-space.
+func squashSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
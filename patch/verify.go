@@ -0,0 +1,82 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// PreimagePaths returns the index paths whose content a patch's files
+// expect, for files that carry an old blob id. New files have no preimage
+// and are skipped.
+func PreimagePaths(parsed *diff.ParsedDiff) []string {
+	var paths []string
+
+	for file := range parsed.Files() {
+		if !hasPreimage(file) {
+			continue
+		}
+
+		paths = append(paths, file.OldName)
+	}
+
+	return paths
+}
+
+// VerifyPreimage checks that every file in a patch was generated against
+// the blob currently in the index. The index map gives the full object id
+// staged for each path, as returned by git.Executor.IndexBlobs. A mismatch
+// means the index changed since the diff was taken; applying anyway could
+// land hunks on shifted lines, so the patch is rejected as stale.
+func VerifyPreimage(parsed *diff.ParsedDiff, index map[string]string) error {
+	var stale []string
+
+	for file := range parsed.Files() {
+		if !hasPreimage(file) {
+			continue
+		}
+
+		current, ok := index[file.OldName]
+
+		switch {
+		case !ok:
+			stale = append(stale, fmt.Sprintf(
+				"%s: not in the index (patch expects %s)",
+				file.OldName, file.OldBlob,
+			))
+
+		case !strings.HasPrefix(current, file.OldBlob):
+			stale = append(stale, fmt.Sprintf(
+				"%s: index has %s, patch expects %s",
+				file.OldName, abbrev(current, len(file.OldBlob)),
+				file.OldBlob,
+			))
+		}
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf(
+			"stale patch, re-run hunk diff and reselect lines:\n  %s",
+			strings.Join(stale, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// hasPreimage reports whether a file's old blob id names real content that
+// must be present in the index. All-zero ids mark new files.
+func hasPreimage(file *diff.FileDiff) bool {
+	return file.OldBlob != "" && !file.IsNew &&
+		strings.Trim(file.OldBlob, "0") != ""
+}
+
+// abbrev shortens an object id to n characters.
+func abbrev(id string, n int) string {
+	if len(id) > n {
+		return id[:n]
+	}
+
+	return id
+}
@@ -0,0 +1,77 @@
+package patch_test
+
+import (
+	"testing"
+
+	"github.com/roasbeef/hunk/diff"
+	"github.com/roasbeef/hunk/patch"
+	"github.com/stretchr/testify/require"
+)
+
+const verifyDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,3 @@
+ package main
++// Added.
+ func main() {}
+diff --git a/new.go b/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package main
+`
+
+func TestPreimagePaths(t *testing.T) {
+	parsed, err := diff.Parse(verifyDiff)
+	require.NoError(t, err)
+
+	// New files have no preimage to check.
+	require.Equal(t, []string{"main.go"}, patch.PreimagePaths(parsed))
+}
+
+func TestVerifyPreimage(t *testing.T) {
+	parsed, err := diff.Parse(verifyDiff)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		index   map[string]string
+		wantErr string
+	}{
+		{
+			name: "matching blob",
+			index: map[string]string{
+				"main.go": "1111111aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			},
+		},
+		{
+			name: "index moved on",
+			index: map[string]string{
+				"main.go": "4444444aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			},
+			wantErr: "main.go: index has 4444444, patch expects 1111111",
+		},
+		{
+			name:    "missing from index",
+			index:   map[string]string{},
+			wantErr: "main.go: not in the index",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := patch.VerifyPreimage(parsed, tt.index)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, "stale patch")
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package plan provides types and parsing for multi-commit plans. A plan
+// lets an agent describe a whole series of commits up front, as line
+// selections and messages, and have them created in one go.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// Commit is one commit of a plan.
+type Commit struct {
+	// Selections are FILE:LINES selections against the changes since
+	// HEAD. A bare path selects the whole file, and a Go symbol, such
+	// as main.go:runServer, the lines of its declaration.
+	Selections []string `json:"selections"`
+
+	// Message is the commit message.
+	Message string `json:"message"`
+
+	// Author overrides the author, in "Name <email>" form.
+	Author string `json:"author,omitempty"`
+
+	// Date overrides the author date.
+	Date string `json:"date,omitempty"`
+
+	// Signoff adds a Signed-off-by trailer.
+	Signoff bool `json:"signoff,omitempty"`
+
+	// Trailers are extra trailers, as key=value.
+	Trailers []string `json:"trailers,omitempty"`
+}
+
+// Validate checks that the commit is valid.
+func (c *Commit) Validate() error {
+	if len(c.Selections) == 0 {
+		return fmt.Errorf("no selections")
+	}
+
+	if strings.TrimSpace(c.Message) == "" {
+		return fmt.Errorf("message required")
+	}
+
+	if strings.ContainsRune(c.Message, '\x00') {
+		return fmt.Errorf("message cannot contain NUL bytes")
+	}
+
+	for _, trailer := range c.Trailers {
+		if !strings.ContainsAny(trailer, "=:") {
+			return fmt.Errorf("invalid trailer %q: expected key=value",
+				trailer)
+		}
+	}
+
+	return nil
+}
+
+// SymbolSelection reports whether sel names a Go symbol, as in
+// main.go:runServer or main.go:Server.Start, rather than lines, and if so
+// returns its path and symbol.
+func SymbolSelection(sel string) (path, symbol string, ok bool) {
+	path, spec, hasLines, err := diff.SplitSelection(sel)
+	if err != nil || !hasLines {
+		return "", "", false
+	}
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return "", "", false
+	}
+
+	first := []rune(spec)[0]
+	if !unicode.IsLetter(first) && first != '_' {
+		return "", "", false
+	}
+
+	return path, spec, true
+}
+
+// Plan is an ordered series of commits. Each commit holds its own
+// selections on top of the commits before it.
+type Plan struct {
+	// Commits are the commits to create, oldest first.
+	Commits []Commit `json:"commits"`
+}
+
+// Validate checks that the plan is valid.
+func (p *Plan) Validate() error {
+	if len(p.Commits) == 0 {
+		return fmt.Errorf("plan has no commits")
+	}
+
+	for i, commit := range p.Commits {
+		if err := commit.Validate(); err != nil {
+			return fmt.Errorf("commit %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Parse parses a Plan from JSON data.
+func Parse(data []byte) (*Plan, error) {
+	var plan Plan
+
+	if err := json.Unmarshal(data, &plan); err != nil {
+		snippet := string(data)
+		if len(snippet) > 100 {
+			snippet = snippet[:100] + "..."
+		}
+
+		return nil, fmt.Errorf(
+			"invalid JSON plan: %w\ninput: %s", err, snippet,
+		)
+	}
+
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    *Plan
+		wantErr string
+	}{
+		{
+			name: "commits with options",
+			json: `{
+				"commits": [
+					{"selections": ["a.go:1-5", "b.go"], "message": "First"},
+					{"selections": ["a.go:runServer"], "message": "Run"},
+					{
+						"selections": ["a.go:@2.1"],
+						"message": "Second",
+						"author": "A U Thor <a@example.com>",
+						"signoff": true,
+						"trailers": ["Refs=42"]
+					}
+				]
+			}`,
+			want: &Plan{Commits: []Commit{
+				{
+					Selections: []string{"a.go:1-5", "b.go"},
+					Message:    "First",
+				},
+				{
+					Selections: []string{"a.go:runServer"},
+					Message:    "Run",
+				},
+				{
+					Selections: []string{"a.go:@2.1"},
+					Message:    "Second",
+					Author:     "A U Thor <a@example.com>",
+					Signoff:    true,
+					Trailers:   []string{"Refs=42"},
+				},
+			}},
+		},
+		{
+			name:    "invalid json",
+			json:    `{not valid}`,
+			wantErr: "invalid JSON plan",
+		},
+		{
+			name:    "no commits",
+			json:    `{"commits":[]}`,
+			wantErr: "no commits",
+		},
+		{
+			name:    "no selections",
+			json:    `{"commits":[{"message":"m"}]}`,
+			wantErr: "commit 1: no selections",
+		},
+		{
+			name:    "no message",
+			json:    `{"commits":[{"selections":["a.go"]}]}`,
+			wantErr: "commit 1: message required",
+		},
+		{
+			name: "bad trailer",
+			json: `{"commits":[{"selections":["a.go"],"message":"m",` +
+				`"trailers":["nope"]}]}`,
+			wantErr: "invalid trailer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.json))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSymbolSelection(t *testing.T) {
+	tests := []struct {
+		sel    string
+		path   string
+		symbol string
+		ok     bool
+	}{
+		{sel: "a.go:runServer", path: "a.go", symbol: "runServer", ok: true},
+		{sel: "a.go:Server.Start", path: "a.go", symbol: "Server.Start",
+			ok: true},
+		{sel: `"a:b.go":_init`, path: "a:b.go", symbol: "_init", ok: true},
+		{sel: "a.go:10-20"},
+		{sel: "a.go:+3,-4"},
+		{sel: "a.go:@1.2"},
+		{sel: "a.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			path, symbol, ok := SymbolSelection(tt.sel)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.path, path)
+			require.Equal(t, tt.symbol, symbol)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package rebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/roasbeef/hunk/diff"
+)
+
+// SplitPart is one commit to carve out of a commit being split.
+type SplitPart struct {
+	// Selections are FILE:LINES selections against the commit's diff.
+	// A bare path selects the whole file.
+	Selections []string `json:"selections"`
+
+	// Message is the new commit's message.
+	Message string `json:"message"`
+}
+
+// SplitSpec describes how to split a commit into several.
+type SplitSpec struct {
+	// Parts are the new commits, oldest first. Each holds its own
+	// selections on top of the parts before it.
+	Parts []SplitPart `json:"parts"`
+
+	// RestMessage is the message for a final commit holding whatever no
+	// part selected. If empty, the original commit's message is used.
+	RestMessage string `json:"rest_message,omitempty"`
+}
+
+// Validate checks that the spec is valid.
+func (s *SplitSpec) Validate() error {
+	if len(s.Parts) == 0 {
+		return fmt.Errorf("split spec has no parts")
+	}
+
+	for i, part := range s.Parts {
+		if len(part.Selections) == 0 {
+			return fmt.Errorf("part %d: no selections", i+1)
+		}
+
+		if strings.TrimSpace(part.Message) == "" {
+			return fmt.Errorf("part %d: message required", i+1)
+		}
+
+		if strings.ContainsRune(part.Message, '\x00') {
+			return fmt.Errorf("part %d: message cannot contain "+
+				"NUL bytes", i+1)
+		}
+	}
+
+	if strings.ContainsRune(s.RestMessage, '\x00') {
+		return fmt.Errorf("rest message cannot contain NUL bytes")
+	}
+
+	return nil
+}
+
+// ParseSplitSpec parses a SplitSpec from JSON data.
+func ParseSplitSpec(data []byte) (*SplitSpec, error) {
+	var spec SplitSpec
+
+	if err := json.Unmarshal(data, &spec); err != nil {
+		snippet := string(data)
+		if len(snippet) > 100 {
+			snippet = snippet[:100] + "..."
+		}
+
+		return nil, fmt.Errorf(
+			"invalid JSON split spec: %w\ninput: %s", err, snippet,
+		)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// ParseCLISplitSpec builds a SplitSpec from paired --part and --message
+// flags. Each part is a space-separated list of selections, in which a
+// quoted path may contain spaces.
+func ParseCLISplitSpec(
+	parts, messages []string, restMessage string,
+) (*SplitSpec, error) {
+	if len(parts) != len(messages) {
+		return nil, fmt.Errorf("got %d part(s) but %d message(s): "+
+			"each part needs a message", len(parts), len(messages))
+	}
+
+	spec := &SplitSpec{RestMessage: restMessage}
+	for i, part := range parts {
+		selections, err := diff.SplitSelectionList(part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i+1, err)
+		}
+
+		spec.Parts = append(spec.Parts, SplitPart{
+			Selections: selections,
+			Message:    messages[i],
+		})
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,101 @@
+package rebase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSplitSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    *SplitSpec
+		wantErr string
+	}{
+		{
+			name: "parts and rest message",
+			json: `{
+				"parts": [
+					{"selections": ["a.go:1-5", "b.go"], "message": "First"},
+					{"selections": ["a.go:@2.1"], "message": "Second"}
+				],
+				"rest_message": "Rest"
+			}`,
+			want: &SplitSpec{
+				Parts: []SplitPart{
+					{
+						Selections: []string{"a.go:1-5", "b.go"},
+						Message:    "First",
+					},
+					{
+						Selections: []string{"a.go:@2.1"},
+						Message:    "Second",
+					},
+				},
+				RestMessage: "Rest",
+			},
+		},
+		{
+			name:    "invalid json",
+			json:    `{not valid}`,
+			wantErr: "invalid JSON split spec",
+		},
+		{
+			name:    "no parts",
+			json:    `{"parts":[]}`,
+			wantErr: "no parts",
+		},
+		{
+			name:    "no selections",
+			json:    `{"parts":[{"selections":[],"message":"m"}]}`,
+			wantErr: "part 1: no selections",
+		},
+		{
+			name:    "no message",
+			json:    `{"parts":[{"selections":["a.go"],"message":" "}]}`,
+			wantErr: "part 1: message required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSplitSpec([]byte(tt.json))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseCLISplitSpec(t *testing.T) {
+	spec, err := ParseCLISplitSpec(
+		[]string{"a.go:1-5 b.go", "c.go:@1.1"},
+		[]string{"First", "Second"}, "",
+	)
+	require.NoError(t, err)
+	require.Equal(t, &SplitSpec{Parts: []SplitPart{
+		{Selections: []string{"a.go:1-5", "b.go"}, Message: "First"},
+		{Selections: []string{"c.go:@1.1"}, Message: "Second"},
+	}}, spec)
+
+	// A quoted path keeps its spaces.
+	spec, err = ParseCLISplitSpec(
+		[]string{`"my file.go":3 b.go`}, []string{"First"}, "",
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{`"my file.go":3`, "b.go"},
+		spec.Parts[0].Selections)
+
+	_, err = ParseCLISplitSpec([]string{`"my file.go:3`},
+		[]string{"First"}, "")
+	require.ErrorContains(t, err, "part 1: unterminated quoted path")
+
+	_, err = ParseCLISplitSpec([]string{"a.go"}, nil, "")
+	require.ErrorContains(t, err, "each part needs a message")
+}
@@ -0,0 +1,143 @@
+// Package schema publishes the JSON Schema documents that describe hunk's
+// machine-readable output, and validates documents against them.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Version is the current version of every JSON output contract. It is
+// emitted as the schema_version field of each document and must be bumped
+// whenever a field is removed or changes meaning. Adding optional fields
+// does not require a bump.
+const Version = 1
+
+//go:embed schemas/*.json
+var files embed.FS
+
+// aliases maps command names that share an output format to the schema
+// that describes it.
+var aliases = map[string]string{
+	"preview":         "diff",
+	"rebase-continue": "rebase-control",
+	"rebase-abort":    "rebase-control",
+	"rebase-skip":     "rebase-control",
+}
+
+// commands maps schema names to the command line whose output they
+// describe, where that isn't the name with its dashes read as spaces.
+var commands = map[string]string{
+	"amend-into":         "amend-into",
+	"diff-stage-hints":   "diff --stage-hints",
+	"diff-stream":        "diff --json-stream",
+	"history-drop-lines": "history drop-lines",
+	"history-move-lines": "history move-lines",
+	"rebase-control":     "rebase continue",
+}
+
+// Command returns the command, with any flag, whose output the schema
+// name describes, e.g. "rebase list" for "rebase-list" or "diff
+// --stage-hints" for "diff-stage-hints". Resolve maps it back to name.
+func Command(name string) string {
+	if command, ok := commands[name]; ok {
+		return command
+	}
+
+	return strings.ReplaceAll(name, "-", " ")
+}
+
+// Commands returns every command line whose output the schema name
+// describes: Command(name) followed by the commands that share the schema,
+// such as "preview" for "diff".
+func Commands(name string) []string {
+	var shared []string
+	for alias, target := range aliases {
+		command := strings.ReplaceAll(alias, "-", " ")
+		if target == name && command != Command(name) {
+			shared = append(shared, command)
+		}
+	}
+
+	sort.Strings(shared)
+
+	return append([]string{Command(name)}, shared...)
+}
+
+// Names returns the names of all published schemas in sorted order.
+func Names() []string {
+	entries, err := files.ReadDir("schemas")
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolve maps a command name to its schema name. Multi-word commands may
+// be given with spaces or dashes (e.g. "rebase list" or "rebase-list"), and
+// outputs selected by a flag as the command and flag (e.g. "diff
+// --stage-hints").
+func Resolve(command string) string {
+	fields := strings.Fields(command)
+	for name, c := range commands {
+		if strings.Join(fields, " ") == c {
+			return name
+		}
+	}
+
+	name := strings.Join(fields, "-")
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+
+	return name
+}
+
+// Get returns the raw JSON Schema document for a command.
+func Get(command string) ([]byte, error) {
+	name := Resolve(command)
+
+	data, err := files.ReadFile(path.Join("schemas", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"no schema for %q (available: %s)",
+			command, strings.Join(Names(), ", "),
+		)
+	}
+
+	return data, nil
+}
+
+// Validate checks that a JSON document conforms to the schema for command.
+func Validate(command string, document []byte) error {
+	raw, err := Get(command)
+	if err != nil {
+		return err
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return fmt.Errorf("invalid schema %q: %w", command, err)
+	}
+
+	var doc any
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return fmt.Errorf("invalid JSON document: %w", err)
+	}
+
+	v := &validator{root: root}
+
+	return v.validate(root, doc, "$")
+}
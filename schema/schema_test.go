@@ -0,0 +1,136 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/roasbeef/hunk/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNames(t *testing.T) {
+	names := schema.Names()
+	require.Contains(t, names, "diff")
+	require.Contains(t, names, "diff-stream")
+	require.Contains(t, names, "diff-stage-hints")
+	require.Contains(t, names, "rebase-list")
+	require.IsIncreasing(t, names)
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "diff", want: "diff"},
+		{command: "preview", want: "diff"},
+		{command: "rebase list", want: "rebase-list"},
+		{command: "rebase-list", want: "rebase-list"},
+		{command: "rebase  continue", want: "rebase-control"},
+		{command: "rebase abort", want: "rebase-control"},
+		{command: "amend-into", want: "amend-into"},
+		{command: "history move-lines", want: "history-move-lines"},
+		{command: "diff --stage-hints", want: "diff-stage-hints"},
+		{command: "diff --json-stream", want: "diff-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			require.Equal(t, tt.want, schema.Resolve(tt.command))
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	require.Equal(t, []string{"diff", "preview"}, schema.Commands("diff"))
+	require.Equal(t, []string{"diff --stage-hints"},
+		schema.Commands("diff-stage-hints"))
+
+	// Every command a schema lists resolves back to it.
+	for _, name := range schema.Names() {
+		for _, command := range schema.Commands(name) {
+			require.Equal(t, name, schema.Resolve(command), command)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	// Every published schema must be well-formed JSON.
+	for _, name := range schema.Names() {
+		data, err := schema.Get(name)
+		require.NoError(t, err, name)
+		require.True(t, json.Valid(data), name)
+	}
+
+	_, err := schema.Get("nonexistent")
+	require.ErrorContains(t, err, "no schema")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "valid empty diff",
+			command: "diff",
+			doc:     `{"schema_version":1,"files":[]}`,
+		},
+		{
+			name:    "missing schema version",
+			command: "diff",
+			doc:     `{"files":[]}`,
+			wantErr: `missing required field "schema_version"`,
+		},
+		{
+			name:    "wrong schema version",
+			command: "diff",
+			doc:     `{"schema_version":2,"files":[]}`,
+			wantErr: "$.schema_version: expected 1",
+		},
+		{
+			name:    "unknown field",
+			command: "diff",
+			doc:     `{"schema_version":1,"files":[],"bogus":true}`,
+			wantErr: `unexpected field "bogus"`,
+		},
+		{
+			name:    "wrong type",
+			command: "diff",
+			doc:     `{"schema_version":1,"files":{}}`,
+			wantErr: "$.files: expected array, got object",
+		},
+		{
+			name:    "stream summary record",
+			command: "diff stream",
+			doc: `{"schema_version":1,"type":"summary","files":0,` +
+				`"hunks":0,"additions":0,"deletions":0}`,
+		},
+		{
+			name:    "stream record of unknown type",
+			command: "diff stream",
+			doc:     `{"schema_version":1,"type":"other"}`,
+			wantErr: "matched 0 of oneOf schemas",
+		},
+		{
+			name:    "invalid JSON",
+			command: "diff",
+			doc:     `{`,
+			wantErr: "invalid JSON document",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.command, []byte(tt.doc))
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// validator checks documents against the subset of JSON Schema used by the
+// published schemas: type, properties, required, additionalProperties,
+// items, enum, const, oneOf and local $ref pointers into $defs.
+type validator struct {
+	root map[string]any
+}
+
+// validate checks value against schema. The location is a JSONPath-like
+// string used in error messages.
+func (v *validator) validate(schema map[string]any, value any, loc string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+
+		return v.validate(resolved, value, loc)
+	}
+
+	if want, ok := schema["type"]; ok {
+		if err := checkType(want, value, loc); err != nil {
+			return err
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		return fmt.Errorf("%s: expected %v, got %v", loc, c, value)
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !containsValue(enum, value) {
+			return fmt.Errorf("%s: %v is not one of %v", loc, value, enum)
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if err := v.validateOneOf(oneOf, value, loc); err != nil {
+			return err
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		return v.validateObject(schema, val, loc)
+
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		for i, item := range val {
+			err := v.validate(items, item, fmt.Sprintf("%s[%d]", loc, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateObject checks required keys, known properties and, if
+// additionalProperties is false, that no unknown keys are present.
+func (v *validator) validateObject(
+	schema map[string]any, obj map[string]any, loc string,
+) error {
+	props, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			key, _ := r.(string)
+			if _, ok := obj[key]; !ok {
+				return fmt.Errorf("%s: missing required field %q", loc, key)
+			}
+		}
+	}
+
+	// Visit keys in order so errors are deterministic.
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propSchema, ok := props[key].(map[string]any)
+		if !ok {
+			if extra, ok := schema["additionalProperties"].(bool); ok &&
+				!extra {
+
+				return fmt.Errorf("%s: unexpected field %q", loc, key)
+			}
+
+			continue
+		}
+
+		if err := v.validate(propSchema, obj[key], loc+"."+key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOneOf checks that value matches exactly one of the subschemas.
+func (v *validator) validateOneOf(oneOf []any, value any, loc string) error {
+	matches := 0
+
+	var errs []string
+	for _, sub := range oneOf {
+		subSchema, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if err := v.validate(subSchema, value, loc); err != nil {
+			errs = append(errs, err.Error())
+
+			continue
+		}
+
+		matches++
+	}
+
+	if matches != 1 {
+		return fmt.Errorf("%s: matched %d of oneOf schemas: %s",
+			loc, matches, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// resolveRef resolves a local "#/$defs/name" reference.
+func (v *validator) resolveRef(ref string) (map[string]any, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	defs, _ := v.root["$defs"].(map[string]any)
+
+	def, ok := defs[name].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unknown $ref %q", ref)
+	}
+
+	return def, nil
+}
+
+// checkType checks value against a type keyword, which may be a single type
+// name or a list of them.
+func checkType(want any, value any, loc string) error {
+	var types []string
+
+	switch t := want.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+
+	for _, t := range types {
+		if hasType(t, value) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: expected %s, got %s",
+		loc, strings.Join(types, " or "), typeName(value))
+}
+
+// hasType reports whether a decoded JSON value has the named schema type.
+func hasType(t string, value any) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	default:
+		return false
+	}
+}
+
+// typeName returns the schema type name of a decoded JSON value.
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// containsValue reports whether value is deeply equal to any enum member.
+func containsValue(enum []any, value any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -36,6 +36,48 @@ func NewGitTestRepo(t *testing.T) *GitTestRepo {
 	return repo
 }
 
+// FileCommit is a commit for NewFeatureRepo to make: the files it writes
+// and its message, and optionally its author and date.
+type FileCommit struct {
+	Message string
+	Author  string
+	Date    string
+	Files   map[string]string
+}
+
+// NewFeatureRepo creates a test repo whose main branch holds a "Base
+// commit" adding base.txt with lines x1 to x3, and checks out a feature
+// branch from it holding the given commits, oldest first. This is the
+// branch history rewriting commands work on, with main as --onto.
+func NewFeatureRepo(t *testing.T, commits ...FileCommit) *GitTestRepo {
+	t.Helper()
+
+	repo := NewGitTestRepo(t)
+
+	repo.WriteFile("base.txt", "x1\nx2\nx3\n")
+	repo.CommitAll("Base commit")
+	repo.CreateBranch("feature")
+
+	for _, commit := range commits {
+		for path, content := range commit.Files {
+			repo.WriteFile(path, content)
+		}
+
+		args := []string{"commit", "-m", commit.Message}
+		if commit.Author != "" {
+			args = append(args, "--author", commit.Author)
+		}
+		if commit.Date != "" {
+			args = append(args, "--date", commit.Date)
+		}
+
+		repo.Git("add", "-A")
+		repo.Git(args...)
+	}
+
+	return repo
+}
+
 func (r *GitTestRepo) cleanup() {
 	os.RemoveAll(r.Dir)
 }
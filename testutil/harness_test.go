@@ -31,6 +31,28 @@ func TestGitTestRepo(t *testing.T) {
 	require.Contains(t, diffOutput, "+// Added comment.")
 }
 
+func TestNewFeatureRepo(t *testing.T) {
+	repo := testutil.NewFeatureRepo(t,
+		testutil.FileCommit{
+			Message: "Add a",
+			Files:   map[string]string{"a.txt": "a\n"},
+		},
+		testutil.FileCommit{
+			Message: "Edit a and base",
+			Files: map[string]string{
+				"a.txt": "A\n", "base.txt": "x1\n",
+			},
+		},
+	)
+
+	require.Equal(t, "feature\n", repo.Git("branch", "--show-current"))
+	require.Equal(t, "Edit a and base\nAdd a\n",
+		repo.Git("log", "--format=%s", "main.."))
+	require.Equal(t, "x1\nx2\nx3\n", repo.Git("show", "main:base.txt"))
+	require.Equal(t, "a\n", repo.Git("show", "HEAD~1:a.txt"))
+	require.Equal(t, "x1\n", repo.ReadFile("base.txt"))
+}
+
 func TestComparisonTest(t *testing.T) {
 	setup := func(r *testutil.GitTestRepo) {
 		r.WriteFile("main.go", "package main\n\nfunc main() {}\n")